@@ -33,6 +33,8 @@ func NewCertCmd() *cobra.Command {
 
 	cmd.AddCommand(
 		cert.NewGenerateCSRCmd(),
+		cert.NewCheckExpirationCmd(),
+		cert.NewRenewCmd(),
 	)
 
 	return cmd
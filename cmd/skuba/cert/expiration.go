@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cert
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/pkg/skuba/actions/cert"
+)
+
+// NewCheckExpirationCmd creates a `skuba cert check-expiration` cobra command
+// to print the expiration date of the certificates of the cluster
+func NewCheckExpirationCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check-expiration",
+		Short: "Prints the expiration date of the certificates of the cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, config, err := kubernetes.GetAdminClientSetWithConfig()
+			if err != nil {
+				klog.Fatalf("unable to get admin client set: %s", err)
+			}
+			if err := cert.CheckExpiration(clientSet, config, os.Stdout); err != nil {
+				klog.Fatalf("unable to check the expiration of the certificates: %s", err)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+}
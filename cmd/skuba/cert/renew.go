@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cert
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/pkg/skuba"
+	"github.com/SUSE/skuba/pkg/skuba/actions/cert"
+)
+
+type renewOptions struct {
+	inventory string
+	node      string
+	parallel  int
+}
+
+// NewRenewCmd creates a `skuba cert renew` cobra command to renew the
+// certificates of the cluster and restart the components using them
+func NewRenewCmd() *cobra.Command {
+	renewOptions := renewOptions{}
+	target := ssh.Target{}
+	cmd := &cobra.Command{
+		Use:       fmt.Sprintf("renew <%s|%s|%s>", cert.KubeadmCertificates, cert.KubeletCertificates, cert.OIDCCertificates),
+		Short:     "Renews the certificates of the cluster and restarts the components using them",
+		ValidArgs: []string{cert.KubeadmCertificates, cert.KubeletCertificates, cert.OIDCCertificates},
+		Run: func(cmd *cobra.Command, args []string) {
+			if args[0] == cert.OIDCCertificates {
+				clientSet, err := kubernetes.GetAdminClientSet()
+				if err != nil {
+					klog.Fatalf("unable to get admin client set: %s", err)
+				}
+				if err := cert.RenewOIDC(clientSet); err != nil {
+					klog.Fatalf("error renewing the %s certificates: %s", args[0], err)
+				}
+				return
+			}
+
+			clusterInventory, err := inventory.Load(renewOptions.inventory)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			// prompt for the credentials once, before connecting to the nodes concurrently
+			if err := target.ResolveCredentials(); err != nil {
+				klog.Fatal(err)
+			}
+			err = cert.Renew(clusterInventory, args[0], renewOptions.node, renewOptions.parallel, func(n inventory.Node) *deployments.Target {
+				role, _ := n.DeploymentRole()
+				connection := clusterInventory.ConnectionSSH(n)
+				nodeTarget := target.WithConnection(connection.User, connection.Port, connection.Sudo)
+				return nodeTarget.GetNodeDeployment(n.Target, n.Name, &role, flags.GetVerboseFlagLevel())
+			})
+			if err != nil {
+				klog.Fatalf("error renewing the %s certificates: %s", args[0], err)
+			}
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactValidArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("target") || cmd.Flags().Changed("local") {
+				return errors.New("--target and --local cannot be used, the nodes are read from the inventory")
+			}
+			if args[0] == cert.OIDCCertificates && (cmd.Flags().Changed("node") || cmd.Flags().Changed("dry-run")) {
+				return errors.New("--node and --dry-run cannot be used, the oidc certificates are renewed through the API server")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().AddFlagSet(target.GetFlags())
+	// the nodes to connect to are read from the inventory
+	_ = cmd.Flags().MarkHidden("target")
+	_ = cmd.Flags().MarkHidden("local")
+	cmd.Flags().StringVarP(&renewOptions.inventory, "inventory", "", skuba.ClusterInventoryFile(), "Path to the inventory file listing the nodes of the cluster")
+	cmd.Flags().StringVarP(&renewOptions.node, "node", "", "", "Name of the node of the inventory whose certificates are renewed (defaults to all the nodes of the inventory)")
+	cmd.Flags().IntVarP(&renewOptions.parallel, "parallel", "", 1, "Number of workers renewed concurrently, control planes are always renewed one at a time")
+
+	return cmd
+}
@@ -32,9 +32,11 @@ func NewClusterCmd() *cobra.Command {
 
 	cmd.AddCommand(
 		cluster.NewInitCmd(),
+		cluster.NewApplyCmd(),
 		cluster.NewStatusCmd(),
 		cluster.NewUpgradeCmd(),
 		cluster.NewImagesCmd(),
+		cluster.NewEtcdCmd(),
 	)
 
 	return cmd
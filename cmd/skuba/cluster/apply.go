@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/pkg/skuba"
+	"github.com/SUSE/skuba/pkg/skuba/actions"
+	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/apply"
+)
+
+type applyOptions struct {
+	inventory             string
+	parallel              int
+	removeExtra           bool
+	drainTimeout          time.Duration
+	skipEtcdBackup        bool
+	ignorePreflightErrors string
+}
+
+// NewApplyCmd creates a new `skuba cluster apply` cobra command
+func NewApplyCmd() *cobra.Command {
+	applyOptions := applyOptions{}
+	target := ssh.Target{}
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Reconciles the cluster with the nodes of its inventory",
+		Run: func(cmd *cobra.Command, args []string) {
+			clusterInventory, err := inventory.Load(applyOptions.inventory)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			// prompt for the credentials once, before connecting to the nodes concurrently
+			if err := target.ResolveCredentials(); err != nil {
+				klog.Fatal(err)
+			}
+			if applyOptions.drainTimeout < 0 {
+				klog.Infof("the passed duration was negative and will be ignored")
+				applyOptions.drainTimeout = 0
+			}
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				klog.Fatal(err)
+			}
+
+			options := cluster.Options{
+				Parallel:              applyOptions.parallel,
+				RemoveExtra:           applyOptions.removeExtra,
+				DrainTimeout:          applyOptions.drainTimeout,
+				SkipEtcdBackup:        applyOptions.skipEtcdBackup,
+				IgnorePreflightErrors: applyOptions.ignorePreflightErrors,
+				DryRun:                dryRun,
+			}
+			err = cluster.Apply(clusterInventory, options, func(n inventory.Node) *deployments.Target {
+				role, _ := n.DeploymentRole()
+				connection := clusterInventory.ConnectionSSH(n)
+				nodeTarget := target.WithConnection(connection.User, connection.Port, connection.Sudo)
+				return nodeTarget.GetNodeDeployment(n.Target, n.Name, &role, flags.GetVerboseFlagLevel())
+			})
+			if err != nil {
+				klog.Fatalf("error applying the cluster inventory: %s", err)
+			}
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("target") || cmd.Flags().Changed("local") {
+				return errors.New("--target and --local cannot be used, the nodes are read from the inventory")
+			}
+			return cobra.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.Flags().AddFlagSet(target.GetFlags())
+	// the nodes to connect to are read from the inventory
+	_ = cmd.Flags().MarkHidden("target")
+	_ = cmd.Flags().MarkHidden("local")
+	cmd.Flags().StringVarP(&applyOptions.inventory, "inventory", "", skuba.ClusterInventoryFile(), "Path to the inventory file listing the nodes of the cluster")
+	cmd.Flags().IntVarP(&applyOptions.parallel, "parallel", "", 1, "Number of workers joined concurrently, control planes are always joined one at a time")
+	cmd.Flags().BoolVarP(&applyOptions.removeExtra, "remove-extra", "", false, "Remove the nodes of the cluster that are not listed in the inventory")
+	cmd.Flags().DurationVar(&applyOptions.drainTimeout, "drain-timeout", 0, `Time to wait for each removed node to drain, before proceeding with node removal.
+The time can be specified using abbreviations for units: e.g. 1h15m15s (Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h").
+Will wait indefinitely by default.`)
+	cmd.Flags().BoolVarP(&applyOptions.skipEtcdBackup, "skip-etcd-backup", "", false, "Remove control plane nodes without taking an etcd snapshot first")
+
+	actions.AddCommonFlags(cmd, &applyOptions.ignorePreflightErrors)
+
+	return cmd
+}
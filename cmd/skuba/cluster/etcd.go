@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/pkg/skuba"
+	"github.com/SUSE/skuba/pkg/skuba/actions"
+	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/apply"
+	"github.com/SUSE/skuba/pkg/skuba/actions/cluster/etcd"
+)
+
+// NewEtcdCmd creates a new `skuba cluster etcd` cobra command
+func NewEtcdCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "etcd",
+		Short: "Backs up and restores the etcd data of the cluster",
+	}
+
+	cmd.AddCommand(
+		newEtcdSnapshotCmd(),
+		newEtcdRestoreCmd(),
+	)
+
+	return cmd
+}
+
+type etcdSnapshotOptions struct {
+	node   string
+	output string
+}
+
+func newEtcdSnapshotCmd() *cobra.Command {
+	snapshotOptions := etcdSnapshotOptions{}
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Takes an etcd snapshot and downloads it",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, config, err := kubernetes.GetAdminClientSetWithConfig()
+			if err != nil {
+				klog.Fatalf("unable to get admin client set: %s", err)
+			}
+			if snapshotOptions.output == "" {
+				snapshotOptions.output = skuba.EtcdSnapshotFile(time.Now())
+			}
+			if err := etcd.Snapshot(clientSet, config, snapshotOptions.node, snapshotOptions.output); err != nil {
+				klog.Fatalf("error taking the etcd snapshot: %s", err)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().StringVarP(&snapshotOptions.node, "node", "", "", "Name of the control plane node taking the snapshot (defaults to the first control plane node able to take it)")
+	cmd.Flags().StringVarP(&snapshotOptions.output, "output", "o", "", "Path of the downloaded snapshot (defaults to a file named after the current time in the etcd-snapshots folder)")
+	return cmd
+}
+
+type etcdRestoreOptions struct {
+	inventory             string
+	parallel              int
+	ignorePreflightErrors string
+}
+
+func newEtcdRestoreCmd() *cobra.Command {
+	restoreOptions := etcdRestoreOptions{}
+	target := ssh.Target{}
+	cmd := &cobra.Command{
+		Use:   "restore <snapshot-file> <node-name>",
+		Short: "Restores an etcd snapshot on a control plane node and joins the other control plane nodes again",
+		Run: func(cmd *cobra.Command, args []string) {
+			clusterInventory, err := inventory.Load(restoreOptions.inventory)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			// prompt for the credentials once, before connecting to the nodes concurrently
+			if err := target.ResolveCredentials(); err != nil {
+				klog.Fatal(err)
+			}
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				klog.Fatal(err)
+			}
+
+			options := cluster.Options{
+				Parallel:              restoreOptions.parallel,
+				IgnorePreflightErrors: restoreOptions.ignorePreflightErrors,
+				DryRun:                dryRun,
+			}
+			err = etcd.Restore(clusterInventory, args[1], args[0], options, func(n inventory.Node) *deployments.Target {
+				role, _ := n.DeploymentRole()
+				connection := clusterInventory.ConnectionSSH(n)
+				nodeTarget := target.WithConnection(connection.User, connection.Port, connection.Sudo)
+				return nodeTarget.GetNodeDeployment(n.Target, n.Name, &role, flags.GetVerboseFlagLevel())
+			})
+			if err != nil {
+				klog.Fatalf("error restoring the etcd snapshot: %s", err)
+			}
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("target") || cmd.Flags().Changed("local") {
+				return errors.New("--target and --local cannot be used, the nodes are read from the inventory")
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+	}
+
+	cmd.Flags().AddFlagSet(target.GetFlags())
+	// the nodes to connect to are read from the inventory
+	_ = cmd.Flags().MarkHidden("target")
+	_ = cmd.Flags().MarkHidden("local")
+	cmd.Flags().StringVarP(&restoreOptions.inventory, "inventory", "", skuba.ClusterInventoryFile(), "Path to the inventory file listing the nodes of the cluster")
+	cmd.Flags().IntVarP(&restoreOptions.parallel, "parallel", "", 1, "Number of workers joined concurrently, when some are missing from the cluster")
+
+	actions.AddCommonFlags(cmd, &restoreOptions.ignorePreflightErrors)
+
+	return cmd
+}
@@ -33,6 +33,8 @@ type initOptions struct {
 	CloudProvider     string
 	StrictCapDefaults bool
 	CniPlugin         string
+	ExternalCA        string
+	ExternalCAKey     string
 }
 
 // NewInitCmd creates a new `skuba cluster init` cobra command
@@ -53,6 +55,8 @@ func NewInitCmd() *cobra.Command {
 			if err != nil {
 				klog.Fatalf("init failed due to error: %s", err)
 			}
+			initConfig.ExternalCACertFile = initOptions.ExternalCA
+			initConfig.ExternalCAKeyFile = initOptions.ExternalCAKey
 
 			if err = cluster.Init(initConfig); err != nil {
 				klog.Fatalf("init failed due to error: %s", err)
@@ -79,5 +83,8 @@ func NewInitCmd() *cobra.Command {
 
 	cmd.Flags().StringVar(&initOptions.CniPlugin, "cni-plugin", "cilium", "Specify the CNI plugin to be used across the cluster. Valid values: cilium")
 
+	cmd.Flags().StringVar(&initOptions.ExternalCA, "external-ca", "", "Path to the PEM encoded CA chain used as the kubelet and OIDC CA instead of generated ones, starting with the CA certificate")
+	cmd.Flags().StringVar(&initOptions.ExternalCAKey, "external-ca-key", "", "Path to the key of the external CA. Without key, the kubelet and OIDC server certificates are signed by the external CA from the generated CSRs")
+
 	return cmd
 }
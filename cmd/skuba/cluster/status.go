@@ -27,9 +27,14 @@ import (
 	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/status"
 )
 
+type statusOptions struct {
+	etcd bool
+}
+
 // NewStatusCmd creates a new `skuba cluster status` cobra command
 func NewStatusCmd() *cobra.Command {
-	return &cobra.Command{
+	statusOptions := statusOptions{}
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show cluster status",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -39,11 +44,13 @@ func NewStatusCmd() *cobra.Command {
 				os.Exit(1)
 			}
 
-			if err := cluster.Status(clientSet); err != nil {
+			if err := cluster.Status(clientSet, statusOptions.etcd); err != nil {
 				klog.Errorf("unable to get cluster status: %s", err)
 				os.Exit(1)
 			}
 		},
 		Args: cobra.NoArgs,
 	}
+	cmd.Flags().BoolVarP(&statusOptions.etcd, "etcd", "", false, "Show the status of the etcd members, as reported by a job on a control plane node")
+	return cmd
 }
@@ -33,6 +33,7 @@ func NewNodeCmd() *cobra.Command {
 	cmd.AddCommand(
 		node.NewBootstrapCmd(),
 		node.NewJoinCmd(),
+		node.NewPreflightCmd(),
 		node.NewRemoveCmd(),
 		node.NewUpgradeCmd(),
 	)
@@ -45,6 +45,9 @@ func NewBootstrapCmd() *cobra.Command {
 			if err := validate.NodeName(nodenames[0]); err != nil {
 				klog.Fatal(err)
 			}
+			if err := target.Validate(); err != nil {
+				klog.Fatal(err)
+			}
 
 			bootstrapConfiguration := deployments.BootstrapConfiguration{
 				KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": bootstrapOptions.ignorePreflightErrors},
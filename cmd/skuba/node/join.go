@@ -18,14 +18,18 @@
 package node
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog"
 
 	"github.com/SUSE/skuba/cmd/skuba/flags"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/pkg/skuba/actions"
 	node "github.com/SUSE/skuba/pkg/skuba/actions/node/join"
@@ -35,6 +39,29 @@ import (
 type joinOptions struct {
 	role                  string
 	ignorePreflightErrors string
+	inventory             string
+	parallel              int
+	labels                []string
+	taints                []string
+	kubeletArgs           []string
+}
+
+// joinConfiguration returns the join configuration described by the flags
+func (o joinOptions) joinConfiguration() (deployments.JoinConfiguration, error) {
+	joinConfiguration := deployments.JoinConfiguration{
+		KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": o.ignorePreflightErrors},
+	}
+	var err error
+	if joinConfiguration.Labels, err = node.ParseLabels(o.labels); err != nil {
+		return joinConfiguration, err
+	}
+	if joinConfiguration.Taints, err = node.ParseTaints(o.taints); err != nil {
+		return joinConfiguration, err
+	}
+	if joinConfiguration.KubeletExtraArgs, err = node.ParseKubeletArgs(o.kubeletArgs); err != nil {
+		return joinConfiguration, err
+	}
+	return joinConfiguration, nil
 }
 
 // NewBootstrapCmd creates a new `skuba node join` cobra command
@@ -45,14 +72,24 @@ func NewJoinCmd() *cobra.Command {
 		Use:   "join <node-name>",
 		Short: "Joins a new node to the cluster",
 		Run: func(cmd *cobra.Command, nodenames []string) {
+			if joinOptions.inventory != "" {
+				joinInventory(joinOptions, &target)
+				return
+			}
 			if err := validate.NodeName(nodenames[0]); err != nil {
 				klog.Fatal(err)
 			}
-
-			joinConfiguration := deployments.JoinConfiguration{
-				KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": joinOptions.ignorePreflightErrors},
+			if err := target.Validate(); err != nil {
+				klog.Fatal(err)
+			}
+			if joinOptions.role == "" {
+				klog.Fatal(`required flag(s) "role" not set`)
 			}
 
+			joinConfiguration, err := joinOptions.joinConfiguration()
+			if err != nil {
+				klog.Fatal(err)
+			}
 			joinConfiguration.Role = deployments.MustGetRoleFromString(joinOptions.role)
 			clientSet, err := kubernetes.GetAdminClientSet()
 			if err != nil {
@@ -63,15 +100,89 @@ func NewJoinCmd() *cobra.Command {
 				klog.Fatalf("error joining node %s: %s", nodenames[0], err)
 			}
 		},
-		Args: cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if joinOptions.inventory != "" {
+				if cmd.Flags().Changed("target") || cmd.Flags().Changed("role") {
+					return errors.New("--target and --role cannot be used with --inventory")
+				}
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 	}
 
 	cmd.Flags().AddFlagSet(target.GetFlags())
-	cmd.Flags().StringVarP(&joinOptions.role, "role", "r", "", "Role that this node will have in the cluster (master|worker) (required)")
+	cmd.Flags().StringVarP(&joinOptions.role, "role", "r", "", "Role that this node will have in the cluster (master|worker) (required, unless --inventory is used)")
+	cmd.Flags().StringVarP(&joinOptions.inventory, "inventory", "", "", "Path to an inventory file listing the nodes to join, instead of the node given as argument")
+	cmd.Flags().IntVarP(&joinOptions.parallel, "parallel", "", 1, "Number of workers of the inventory joined concurrently, control planes are always joined one at a time")
+	cmd.Flags().StringArrayVarP(&joinOptions.labels, "label", "", nil, "Label the node registers with, as key=value (can be repeated)")
+	cmd.Flags().StringArrayVarP(&joinOptions.taints, "taint", "", nil, "Taint the node registers with, as key[=value]:effect (can be repeated)")
+	cmd.Flags().StringArrayVarP(&joinOptions.kubeletArgs, "kubelet-arg", "", nil, "Extra argument for the kubelet of the node, as key=value (can be repeated)")
 
 	actions.AddCommonFlags(cmd, &joinOptions.ignorePreflightErrors)
 
-	_ = cmd.MarkFlagRequired("role")
-
 	return cmd
 }
+
+// joinInventory joins the nodes of the inventory, printing a summary of the
+// outcome for each of them
+func joinInventory(joinOptions joinOptions, target *ssh.Target) {
+	nodes, err := inventory.Load(joinOptions.inventory)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	// prompt for the credentials once, before connecting to the nodes concurrently
+	if err := target.ResolveCredentials(); err != nil {
+		klog.Fatal(err)
+	}
+	baseJoinConfiguration, err := joinOptions.joinConfiguration()
+	if err != nil {
+		klog.Fatal(err)
+	}
+	clientSet, err := kubernetes.GetAdminClientSet()
+	if err != nil {
+		klog.Errorf("unable to get admin client set: %s", err)
+		os.Exit(1)
+	}
+	results := inventory.Run(nodes.Nodes, joinOptions.parallel, func(n inventory.Node) error {
+		role, err := n.DeploymentRole()
+		if err != nil {
+			return err
+		}
+		joinConfiguration, err := inventoryJoinConfiguration(baseJoinConfiguration, n)
+		if err != nil {
+			return err
+		}
+		joinConfiguration.Role = role
+		connection := nodes.ConnectionSSH(n)
+		nodeTarget := target.WithConnection(connection.User, connection.Port, connection.Sudo)
+		return node.Join(clientSet, joinConfiguration, nodeTarget.GetNodeDeployment(n.Target, n.Name, &role, flags.GetVerboseFlagLevel()))
+	})
+	fmt.Println()
+	inventory.PrintSummary(os.Stdout, results)
+	if err := inventory.Failed(results); err != nil {
+		klog.Fatalf("error joining nodes: %s", err)
+	}
+}
+
+// inventoryJoinConfiguration adds the labels and taints of the inventory node
+// to the ones given with the flags
+func inventoryJoinConfiguration(joinConfiguration deployments.JoinConfiguration, n inventory.Node) (deployments.JoinConfiguration, error) {
+	if err := validate.NodeLabels(n.Labels); err != nil {
+		return joinConfiguration, err
+	}
+	taints, err := n.ParsedTaints()
+	if err != nil {
+		return joinConfiguration, err
+	}
+	labels := map[string]string{}
+	for key, value := range joinConfiguration.Labels {
+		labels[key] = value
+	}
+	for key, value := range n.Labels {
+		labels[key] = value
+	}
+	joinConfiguration.Labels = labels
+	joinConfiguration.Taints = append(append([]v1.Taint{}, joinConfiguration.Taints...), taints...)
+	return joinConfiguration, nil
+}
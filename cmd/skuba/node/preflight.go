@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package node
+
+import (
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	node "github.com/SUSE/skuba/pkg/skuba/actions/node/preflight"
+)
+
+type preflightOptions struct {
+	role         string
+	controlPlane string
+}
+
+// NewPreflightCmd creates a new `skuba node preflight` cobra command
+func NewPreflightCmd() *cobra.Command {
+	preflightOptions := preflightOptions{}
+	target := ssh.Target{}
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Checks that a node can be bootstrapped or joined, without changing it",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := target.Validate(); err != nil {
+				klog.Fatal(err)
+			}
+			var role *deployments.Role
+			if preflightOptions.role != "" {
+				nodeRole := deployments.MustGetRoleFromString(preflightOptions.role)
+				role = &nodeRole
+			}
+			preflightConfiguration := node.NewConfiguration(role, preflightOptions.controlPlane)
+			if err := node.Preflight(preflightConfiguration, target.GetDeployment("", role, flags.GetVerboseFlagLevel())); err != nil {
+				klog.Fatal(err)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+
+	cmd.Flags().AddFlagSet(target.GetFlags())
+	// checks never change the node
+	_ = cmd.Flags().MarkHidden("dry-run")
+	_ = cmd.Flags().MarkHidden("resume")
+	_ = cmd.Flags().MarkHidden("reboot-timeout")
+	cmd.Flags().StringVarP(&preflightOptions.role, "role", "r", "", "Role the node will have in the cluster (master|worker), the checks of both roles are run by default")
+	cmd.Flags().StringVarP(&preflightOptions.controlPlane, "control-plane", "", "", "Control plane endpoint the node has to reach (defaults to the one of the cluster definition folder, if any)")
+
+	return cmd
+}
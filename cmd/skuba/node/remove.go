@@ -29,7 +29,8 @@ import (
 )
 
 type removeOptions struct {
-	drainTimeout time.Duration
+	drainTimeout   time.Duration
+	skipEtcdBackup bool
 }
 
 // NewRemoveCmd creates a new `skuba node remove` cobra command
@@ -48,7 +49,7 @@ func NewRemoveCmd() *cobra.Command {
 				klog.Errorf("unable to get admin client set: %s", err)
 				os.Exit(1)
 			}
-			if err := node.Remove(clientSet, nodenames[0], removeOptions.drainTimeout); err != nil {
+			if err := node.Remove(clientSet, nodenames[0], removeOptions.drainTimeout, removeOptions.skipEtcdBackup); err != nil {
 				klog.Fatalf("error removing node %s: %s", nodenames[0], err)
 			}
 		},
@@ -57,6 +58,7 @@ func NewRemoveCmd() *cobra.Command {
 	cmd.Flags().DurationVar(&removeOptions.drainTimeout, "drain-timeout", 0, `Time to wait for the node to drain, before proceeding with node removal.
 The time can be specified using abbreviations for units: e.g. 1h15m15s (Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h").
 Will wait indefinitely by default.`)
+	cmd.Flags().BoolVar(&removeOptions.skipEtcdBackup, "skip-etcd-backup", false, "Remove a control plane node without taking an etcd snapshot first")
 
 	return cmd
 }
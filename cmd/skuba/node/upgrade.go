@@ -25,7 +25,9 @@ import (
 	"k8s.io/klog"
 
 	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/pkg/skuba/actions/node/upgrade"
 )
@@ -64,18 +66,35 @@ func newUpgradePlanCmd() *cobra.Command {
 	}
 }
 
+type upgradeApplyOptions struct {
+	inventory      string
+	parallel       int
+	skipEtcdBackup bool
+}
+
 func newUpgradeApplyCmd() *cobra.Command {
+	upgradeApplyOptions := upgradeApplyOptions{}
 	target := ssh.Target{}
 	cmd := cobra.Command{
 		Use:   "apply",
 		Short: "Apply node upgrade",
 		Run: func(cmd *cobra.Command, args []string) {
+			if upgradeApplyOptions.inventory != "" {
+				if cmd.Flags().Changed("target") {
+					klog.Fatal("--target cannot be used with --inventory")
+				}
+				upgradeInventory(upgradeApplyOptions, &target)
+				return
+			}
+			if err := target.Validate(); err != nil {
+				klog.Fatal(err)
+			}
 			clientSet, err := kubernetes.GetAdminClientSet()
 			if err != nil {
 				klog.Errorf("unable to get admin client set: %s", err)
 				os.Exit(1)
 			}
-			if err := upgrade.Apply(clientSet, target.GetDeployment("", nil, flags.GetVerboseFlagLevel())); err != nil {
+			if err := upgrade.Apply(clientSet, target.GetDeployment("", nil, flags.GetVerboseFlagLevel()), upgradeApplyOptions.skipEtcdBackup); err != nil {
 				fmt.Printf("Unable to apply node upgrade: %s\n", err)
 				os.Exit(1)
 			}
@@ -83,5 +102,39 @@ func newUpgradeApplyCmd() *cobra.Command {
 		Args: cobra.NoArgs,
 	}
 	cmd.Flags().AddFlagSet(target.GetFlags())
+	cmd.Flags().StringVarP(&upgradeApplyOptions.inventory, "inventory", "", "", "Path to an inventory file listing the nodes to upgrade, instead of the node given with --target")
+	cmd.Flags().IntVarP(&upgradeApplyOptions.parallel, "parallel", "", 1, "Number of workers of the inventory upgraded concurrently, control planes are always upgraded one at a time")
+	cmd.Flags().BoolVarP(&upgradeApplyOptions.skipEtcdBackup, "skip-etcd-backup", "", false, "Upgrade the first control plane node without taking an etcd snapshot first")
 	return &cmd
 }
+
+// upgradeInventory upgrades the nodes of the inventory, printing a summary of
+// the outcome for each of them
+func upgradeInventory(upgradeApplyOptions upgradeApplyOptions, target *ssh.Target) {
+	nodes, err := inventory.Load(upgradeApplyOptions.inventory)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	// prompt for the credentials once, before connecting to the nodes concurrently
+	if err := target.ResolveCredentials(); err != nil {
+		klog.Fatal(err)
+	}
+	clientSet, err := kubernetes.GetAdminClientSet()
+	if err != nil {
+		klog.Errorf("unable to get admin client set: %s", err)
+		os.Exit(1)
+	}
+	results, err := upgrade.ApplyInventory(clientSet, nodes.Nodes, upgradeApplyOptions.parallel, upgradeApplyOptions.skipEtcdBackup, func(n inventory.Node) *deployments.Target {
+		connection := nodes.ConnectionSSH(n)
+		nodeTarget := target.WithConnection(connection.User, connection.Port, connection.Sudo)
+		return nodeTarget.GetNodeDeployment(n.Target, "", nil, flags.GetVerboseFlagLevel())
+	})
+	fmt.Println()
+	inventory.PrintSummary(os.Stdout, results)
+	if err != nil {
+		klog.Fatalf("error upgrading nodes: %s", err)
+	}
+	if err := inventory.Failed(results); err != nil {
+		klog.Fatalf("error upgrading nodes: %s", err)
+	}
+}
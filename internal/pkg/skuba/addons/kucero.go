@@ -37,10 +37,22 @@ func (renderContext renderContext) KuceroImage() string {
 	return GetKuceroImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Kucero, renderContext.config.ClusterVersion).Version)
 }
 
+// KubeletCAKeyAvailable returns whether the kubelet CA key can be uploaded to
+// the control plane nodes to sign the kubelet server certificates
+func (renderContext renderContext) KubeletCAKeyAvailable() bool {
+	return !kubernetes.KubeletCAKeyMissing()
+}
+
 func renderKuceroTemplate(addonConfiguration AddonConfiguration) string {
 	return kuceroManifest
 }
 
+// kucero runs on every node to renew the kubelet server certificate of the
+// node before it expires, and on control plane nodes only to sign the
+// certificate signing requests of the nodes with the kubelet CA key, which
+// never leaves the control plane nodes. Without the kubelet CA key, when an
+// external CA is imported without key, nothing can sign the certificates in
+// the cluster and they are not renewed.
 const (
 	kuceroManifest = `---
 apiVersion: v1
@@ -49,6 +61,12 @@ metadata:
   name: kucero
   namespace: kube-system
 ---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: kucero-controller
+  namespace: kube-system
+---
 apiVersion: rbac.authorization.k8s.io/v1
 kind: Role
 metadata:
@@ -122,6 +140,19 @@ rules:
   - pods/eviction
   verbs:
   - create
+- apiGroups:
+  - ""
+  resources:
+  - events
+  verbs:
+  - create
+  - patch
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: kucero-controller
+rules:
 - apiGroups:
   - certificates.k8s.io
   resourceNames:
@@ -179,6 +210,9 @@ subjects:
 - kind: ServiceAccount
   name: kucero
   namespace: kube-system
+- kind: ServiceAccount
+  name: kucero-controller
+  namespace: kube-system
 ---
 apiVersion: rbac.authorization.k8s.io/v1
 kind: ClusterRoleBinding
@@ -195,6 +229,19 @@ subjects:
 ---
 apiVersion: rbac.authorization.k8s.io/v1
 kind: ClusterRoleBinding
+metadata:
+  name: kucero-controller
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: kucero-controller
+subjects:
+- kind: ServiceAccount
+  name: kucero-controller
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
 metadata:
   name: suse:caasp:psp:kucero
 roleRef:
@@ -205,6 +252,9 @@ subjects:
 - kind: ServiceAccount
   name: kucero
   namespace: kube-system
+- kind: ServiceAccount
+  name: kucero-controller
+  namespace: kube-system
 ---
 apiVersion: apps/v1
 kind: DaemonSet
@@ -248,17 +298,16 @@ spec:
                   fieldPath: spec.nodeName
           command:
             - /usr/bin/kucero
-            - --ca-cert-path=/var/lib/kubelet/pki/kubelet-ca.crt
-            - --ca-key-path=/var/lib/kubelet/pki/kubelet-ca.key
+            - --enable-kubelet-csr-controller=false
+{{- if not .KubeletCAKeyAvailable}}
+            - --enable-kubelet-server-cert-rotation=false
+{{- end}}
+            - --polling-period=1h
+            # kubelet server certificates are valid for one year
+            - --renew-before=720h
           volumeMounts:
             - mountPath: /etc/kubernetes/kubelet.conf
               name: kubelet-conf
-            - mountPath: /var/lib/kubelet/pki/kubelet-ca.crt
-              name: ca-crt
-              readOnly: true
-            - mountPath: /var/lib/kubelet/pki/kubelet-ca.key
-              name: ca-key
-              readOnly: true
             - mountPath: /var/lib/kubelet/config.yaml
               name: kubelet-config-yaml
       volumes:
@@ -266,6 +315,58 @@ spec:
           hostPath:
             path: /etc/kubernetes/kubelet.conf
             type: File
+        - name: kubelet-config-yaml
+          hostPath:
+            path: /var/lib/kubelet/config.yaml
+            type: File
+{{- if .KubeletCAKeyAvailable}}
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: kucero-controller
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      name: kucero-controller
+  revisionHistoryLimit: 3
+  updateStrategy:
+    rollingUpdate:
+      maxUnavailable: 1
+    type: RollingUpdate
+  template:
+    metadata:
+      labels:
+        name: kucero-controller
+      annotations:
+        {{.AnnotatedVersion}}
+    spec:
+      serviceAccountName: kucero-controller
+      # the kubelet CA key is only present on control plane nodes
+      nodeSelector:
+        node-role.kubernetes.io/master: ""
+      tolerations:
+        - key: node-role.kubernetes.io/master
+          effect: NoSchedule
+      restartPolicy: Always
+      containers:
+        - name: kucero-controller
+          image: {{.KuceroImage}}
+          imagePullPolicy: IfNotPresent
+          command:
+            - /usr/bin/kucero
+            - --enable-kubelet-server-cert-rotation=false
+            - --ca-cert-path=/var/lib/kubelet/pki/kubelet-ca.crt
+            - --ca-key-path=/var/lib/kubelet/pki/kubelet-ca.key
+          volumeMounts:
+            - mountPath: /var/lib/kubelet/pki/kubelet-ca.crt
+              name: ca-crt
+              readOnly: true
+            - mountPath: /var/lib/kubelet/pki/kubelet-ca.key
+              name: ca-key
+              readOnly: true
+      volumes:
         - name: ca-crt
           hostPath:
             path: /var/lib/kubelet/pki/kubelet-ca.crt
@@ -273,10 +374,7 @@ spec:
         - name: ca-key
           hostPath:
             path: /var/lib/kubelet/pki/kubelet-ca.key
-            type: FileOrCreate
-        - name: kubelet-config-yaml
-          hostPath:
-            path: /var/lib/kubelet/config.yaml
             type: File
+{{- end}}
 `
 )
@@ -19,8 +19,16 @@ package addons
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	img "github.com/SUSE/skuba/pkg/skuba"
 )
@@ -56,3 +64,99 @@ func TestGetKuceroImage(t *testing.T) {
 		}
 	}
 }
+
+func renderKuceroDaemonSets(t *testing.T) map[string]appsv1.DaemonSet {
+	rendered, err := Addons[kubernetes.Kucero].Render(AddonConfiguration{ClusterVersion: kubernetes.LatestVersion()})
+	if err != nil {
+		t.Fatalf("could not render the kucero manifest: %v", err)
+	}
+
+	daemonSets := map[string]appsv1.DaemonSet{}
+	for _, document := range strings.Split(rendered, "\n---\n") {
+		if !strings.Contains(document, "kind: DaemonSet") {
+			continue
+		}
+		daemonSet := appsv1.DaemonSet{}
+		if err := yaml.Unmarshal([]byte(document), &daemonSet); err != nil {
+			t.Fatalf("could not parse the kucero daemonset: %v", err)
+		}
+		daemonSets[daemonSet.ObjectMeta.Name] = daemonSet
+	}
+	return daemonSets
+}
+
+func TestKuceroManifestCAKey(t *testing.T) {
+	daemonSets := renderKuceroDaemonSets(t)
+
+	keyVolume := func(daemonSet appsv1.DaemonSet) *v1.Volume {
+		for i, volume := range daemonSet.Spec.Template.Spec.Volumes {
+			if volume.HostPath != nil && volume.HostPath.Path == "/var/lib/kubelet/pki/kubelet-ca.key" {
+				return &daemonSet.Spec.Template.Spec.Volumes[i]
+			}
+		}
+		return nil
+	}
+
+	if _, ok := daemonSets["kucero"]; !ok {
+		t.Fatal("expected a kucero daemonset renewing the certificates of every node")
+	}
+	if volume := keyVolume(daemonSets["kucero"]); volume != nil {
+		t.Errorf("expected the kucero daemonset not to mount the kubelet CA key, got %v", volume)
+	}
+
+	controller, ok := daemonSets["kucero-controller"]
+	if !ok {
+		t.Fatal("expected a kucero-controller daemonset signing the certificates")
+	}
+	if _, ok := controller.Spec.Template.Spec.NodeSelector["node-role.kubernetes.io/master"]; !ok {
+		t.Errorf("expected the kucero-controller daemonset to run on control plane nodes only, got node selector %v", controller.Spec.Template.Spec.NodeSelector)
+	}
+	volume := keyVolume(controller)
+	if volume == nil {
+		t.Fatal("expected the kucero-controller daemonset to mount the kubelet CA key")
+	}
+	if volume.HostPath.Type == nil || *volume.HostPath.Type != v1.HostPathFile {
+		t.Errorf("expected the kubelet CA key to be mounted only when present, got type %v", volume.HostPath.Type)
+	}
+}
+
+func TestKuceroManifestWithoutCAKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kucero")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get the working directory: %v", err)
+	}
+	defer os.Chdir(wd) //nolint:errcheck
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("could not change directory: %v", err)
+	}
+	// an external CA imported without its key
+	if err := os.MkdirAll(img.PkiDir(), 0700); err != nil {
+		t.Fatalf("could not create the pki directory: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(img.PkiDir(), kubernetes.KubeletCACertName), []byte{}, 0600); err != nil {
+		t.Fatalf("could not write the kubelet CA: %v", err)
+	}
+
+	daemonSets := renderKuceroDaemonSets(t)
+
+	if _, ok := daemonSets["kucero-controller"]; ok {
+		t.Error("expected no kucero-controller daemonset without the kubelet CA key")
+	}
+	kucero, ok := daemonSets["kucero"]
+	if !ok {
+		t.Fatal("expected a kucero daemonset")
+	}
+	command := kucero.Spec.Template.Spec.Containers[0].Command
+	found := false
+	for _, arg := range command {
+		found = found || arg == "--enable-kubelet-server-cert-rotation=false"
+	}
+	if !found {
+		t.Errorf("expected the kubelet server certificate rotation to be disabled, got %v", command)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package deployments
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CommandResult holds the outcome of a command run on a target
+type CommandResult struct {
+	Command  string
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Success returns whether the command exited successfully
+func (r *CommandResult) Success() bool {
+	return r.ExitCode == 0
+}
+
+// CommandError is returned when a command run on a target fails or times
+// out, carrying the result of the command
+type CommandError struct {
+	*CommandResult
+	TimedOut bool
+	Timeout  time.Duration
+}
+
+func (e *CommandError) Error() string {
+	var msg string
+	if e.TimedOut {
+		msg = fmt.Sprintf("command %q timed out after %s", e.Command, e.Timeout)
+	} else {
+		msg = fmt.Sprintf("command %q failed with exit code %d after %s", e.Command, e.ExitCode, e.Duration.Round(time.Millisecond))
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg = fmt.Sprintf("%s: %s", msg, stderr)
+	}
+	return msg
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package deployments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestCommandError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *CommandError
+		expected string
+	}{
+		{
+			name: "failed command with stderr",
+			err: &CommandError{CommandResult: &CommandResult{
+				Command:  "zypper install kubernetes-kubeadm",
+				ExitCode: 104,
+				Stderr:   "No provider of 'kubernetes-kubeadm' found.\n",
+				Duration: 1500 * time.Millisecond,
+			}},
+			expected: `command "zypper install kubernetes-kubeadm" failed with exit code 104 after 1.5s: No provider of 'kubernetes-kubeadm' found.`,
+		},
+		{
+			name: "failed command without stderr",
+			err: &CommandError{CommandResult: &CommandResult{
+				Command:  "false",
+				ExitCode: 1,
+			}},
+			expected: `command "false" failed with exit code 1 after 0s`,
+		},
+		{
+			name: "timed out command",
+			err: &CommandError{
+				CommandResult: &CommandResult{Command: "sleep 60", ExitCode: -1, Stderr: "partial output"},
+				TimedOut:      true,
+				Timeout:       time.Minute,
+			},
+			expected: `command "sleep 60" timed out after 1m0s: partial output`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := errors.Wrap(tt.err, "failed to apply state")
+			if got := err.Error(); got != "failed to apply state: "+tt.expected {
+				t.Errorf("expected %q, got %q", "failed to apply state: "+tt.expected, got)
+			}
+			cmdErr, ok := errors.Cause(err).(*CommandError)
+			if !ok {
+				t.Fatalf("expected the cause to be a *CommandError, got %T", errors.Cause(err))
+			}
+			if cmdErr.ExitCode != tt.err.ExitCode {
+				t.Errorf("expected exit code %d, got %d", tt.err.ExitCode, cmdErr.ExitCode)
+			}
+		})
+	}
+}
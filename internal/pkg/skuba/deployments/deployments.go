@@ -19,6 +19,7 @@ package deployments
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 
@@ -32,6 +33,19 @@ type Actionable interface {
 	IsServiceEnabled(serviceName string) (bool, error)
 }
 
+// Streamer is implemented by the Actionables able to transfer files without
+// holding their whole contents in memory
+type Streamer interface {
+	UploadFileStream(targetPath string, contents io.Reader, perm os.FileMode) error
+	DownloadFileStream(sourcePath string, contents io.Writer) error
+}
+
+// Checkpointer is implemented by the Actionables recording the states applied
+// on the target, so that an interrupted deployment can be resumed
+type Checkpointer interface {
+	ClearCheckpoint() error
+}
+
 type TargetCache struct {
 	OsRelease map[string]string
 }
@@ -42,6 +56,11 @@ type Target struct {
 	Nodename string
 	Role     *Role
 	Cache    TargetCache
+	// DryRun is set when the changes to the target are only printed, the
+	// actions must not change the cluster either
+	DryRun bool
+	// Resume is set when the deployment continues a previous interrupted one
+	Resume bool
 }
 
 func (t *Target) Apply(data interface{}, states ...string) error {
@@ -56,8 +75,25 @@ func (t *Target) Apply(data interface{}, states ...string) error {
 	return t.Actionable.Apply(data, filteredStates...)
 }
 
+// Complete is called once the deployment of the target succeeded: the record
+// of the applied states is removed, as there is nothing left to resume
+func (t *Target) Complete() error {
+	if checkpointer, ok := t.Actionable.(Checkpointer); ok {
+		return checkpointer.ClearCheckpoint()
+	}
+	return nil
+}
+
 func (t *Target) UploadFile(sourcePath, targetPath string, perm os.FileMode) error {
 	klog.V(1).Infof("uploading local file %q to remote file %q", sourcePath, targetPath)
+	if streamer, ok := t.Actionable.(Streamer); ok {
+		f, err := os.Open(sourcePath)
+		if err != nil {
+			return fmt.Errorf("could not find file %s", sourcePath)
+		}
+		defer f.Close()
+		return streamer.UploadFileStream(targetPath, f, perm)
+	}
 	if contents, err := ioutil.ReadFile(sourcePath); err == nil {
 		return t.UploadFileContents(targetPath, string(contents), perm)
 	}
@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package fake provides test doubles recording what is done on a target and
+// replaying canned responses: an Actionable recording the operations applied
+// on it, and a Node recording the commands the real states run on it.
+package fake
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+const (
+	// UpdateGoldenEnv is the environment variable that, when set, makes
+	// AssertGolden rewrite the golden files instead of comparing them
+	UpdateGoldenEnv = "SKUBA_UPDATE_GOLDEN"
+
+	// OsReleaseSLES15SP2 is the /etc/os-release of a SLES 15 SP2 node
+	OsReleaseSLES15SP2 = `NAME="SLES"
+VERSION="15-SP2"
+VERSION_ID="15.2"
+PRETTY_NAME="SUSE Linux Enterprise Server 15 SP2"
+ID="sles"
+ID_LIKE="suse"
+`
+)
+
+// Operation kinds recorded by the fake Actionable
+const (
+	OperationApply          = "apply"
+	OperationUpload         = "upload"
+	OperationDownload       = "download"
+	OperationServiceEnabled = "service-enabled"
+	OperationCheck          = "check"
+)
+
+// Operation is an operation applied on the fake target
+type Operation struct {
+	Kind string
+	// Name is the state, check, file path or service the operation applies to
+	Name string
+	// Data is the data the state was applied with
+	Data interface{}
+	// Contents and Perm are the contents and permissions of uploaded files
+	Contents string
+	Perm     os.FileMode
+}
+
+func (o Operation) String() string {
+	if o.Kind == OperationUpload {
+		return fmt.Sprintf("%s %s %04o", o.Kind, o.Name, o.Perm)
+	}
+	return fmt.Sprintf("%s %s", o.Kind, o.Name)
+}
+
+// Hook is run when a state is applied on the fake target, e.g. to create the
+// files the real state would
+type Hook func(a *Actionable, data interface{}) error
+
+// Actionable is a deployments.Actionable recording every operation applied on
+// it. Responses are scripted through its exported fields, which must be set
+// before it is used.
+type Actionable struct {
+	// Files holds the contents of the files on the target, uploaded files are
+	// stored in it
+	Files map[string]string
+	// Services holds whether services are enabled on the target
+	Services map[string]bool
+	// Errors holds the errors returned by operations, keyed by their string
+	// representation, e.g. "apply kubeadm.join", "check swap" or
+	// "download /etc/machine-id"
+	Errors map[string]error
+	// Hooks holds functions run when states are applied, keyed by state
+	Hooks map[string]Hook
+
+	mu         sync.Mutex
+	operations []Operation
+}
+
+// NewActionable returns a fake Actionable for a SLES 15 SP2 node
+func NewActionable() *Actionable {
+	return &Actionable{
+		Files: map[string]string{
+			"/etc/os-release": OsReleaseSLES15SP2,
+		},
+		Services: map[string]bool{},
+		Errors:   map[string]error{},
+		Hooks:    map[string]Hook{},
+	}
+}
+
+// NewTarget returns a deployments.Target backed by a fake Actionable
+func NewTarget(target, nodename string, role *deployments.Role) (*deployments.Target, *Actionable) {
+	actionable := NewActionable()
+	return &deployments.Target{
+		Actionable: actionable,
+		Target:     target,
+		Nodename:   nodename,
+		Role:       role,
+	}, actionable
+}
+
+func (a *Actionable) record(operation Operation) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.operations = append(a.operations, operation)
+	return a.Errors[operation.String()]
+}
+
+// Apply records the states, stopping at the first one that fails
+func (a *Actionable) Apply(data interface{}, states ...string) error {
+	for _, state := range states {
+		if err := a.record(Operation{Kind: OperationApply, Name: state, Data: data}); err != nil {
+			return err
+		}
+		if hook, ok := a.Hooks[state]; ok {
+			if err := hook(a, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Check records the check, which passes unless an error is scripted for it
+func (a *Actionable) Check(data interface{}, check string) error {
+	return a.record(Operation{Kind: OperationCheck, Name: check, Data: data})
+}
+
+// UploadFileContents records the upload and stores the file contents
+func (a *Actionable) UploadFileContents(targetPath, contents string, perm os.FileMode) error {
+	if err := a.record(Operation{Kind: OperationUpload, Name: targetPath, Contents: contents, Perm: perm}); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Files[targetPath] = contents
+	return nil
+}
+
+// DownloadFileContents records the download and returns the file contents
+func (a *Actionable) DownloadFileContents(sourcePath string) (string, error) {
+	if err := a.record(Operation{Kind: OperationDownload, Name: sourcePath}); err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	contents, ok := a.Files[sourcePath]
+	if !ok {
+		return "", &os.PathError{Op: "open", Path: sourcePath, Err: os.ErrNotExist}
+	}
+	return contents, nil
+}
+
+// IsServiceEnabled records the check and returns whether the service is enabled
+func (a *Actionable) IsServiceEnabled(serviceName string) (bool, error) {
+	if err := a.record(Operation{Kind: OperationServiceEnabled, Name: serviceName}); err != nil {
+		return false, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.Services[serviceName], nil
+}
+
+// Operations returns the operations recorded so far
+func (a *Actionable) Operations() []Operation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Operation{}, a.operations...)
+}
+
+// Transcript returns the recorded operations, one per line
+func (a *Actionable) Transcript() string {
+	var transcript strings.Builder
+	for _, operation := range a.Operations() {
+		fmt.Fprintln(&transcript, operation)
+	}
+	return transcript.String()
+}
+
+// AssertGolden compares the transcript with the golden file, which is
+// rewritten instead when SKUBA_UPDATE_GOLDEN is set
+func (a *Actionable) AssertGolden(t testing.TB, goldenPath string) {
+	t.Helper()
+	assertGolden(t, goldenPath, a.Transcript())
+}
+
+func assertGolden(t testing.TB, goldenPath, transcript string) {
+	t.Helper()
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+			t.Fatalf("could not create golden file directory: %v", err)
+		}
+		if err := ioutil.WriteFile(goldenPath, []byte(transcript), 0644); err != nil {
+			t.Fatalf("could not update golden file %s: %v", goldenPath, err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("could not read golden file %s (set %s=1 to create it): %v", goldenPath, UpdateGoldenEnv, err)
+	}
+	if transcript != string(expected) {
+		t.Errorf("transcript does not match golden file %s (set %s=1 to update it)\nexpected:\n%s\ngot:\n%s", goldenPath, UpdateGoldenEnv, expected, transcript)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package fake
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestActionable(t *testing.T) {
+	role := deployments.WorkerRole
+	target, actionable := NewTarget("10.0.0.1", "worker-0", &role)
+	actionable.Services["skuba-update.timer"] = true
+	actionable.Errors["apply kubeadm.join"] = errors.New("kubeadm join failed")
+	actionable.Hooks["kubelet.configure"] = func(a *Actionable, data interface{}) error {
+		return a.UploadFileContents("/etc/sysconfig/kubelet", "KUBELET_EXTRA_ARGS=", 0644)
+	}
+
+	if profile, err := target.OSProfile(); err != nil || profile.Name != "suse" {
+		t.Errorf("expected the suse profile, got %v (%v)", profile, err)
+	}
+	if enabled, err := target.IsServiceEnabled("skuba-update.timer"); err != nil || !enabled {
+		t.Errorf("expected skuba-update.timer to be enabled, got %t (%v)", enabled, err)
+	}
+	if err := target.Apply(nil, "kubelet.configure", "", "kubeadm.join", "kubelet.enable"); err == nil {
+		t.Error("expected kubeadm.join to fail")
+	}
+	if _, err := target.DownloadFileContents("/etc/machine-id"); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if contents, err := target.DownloadFileContents("/etc/sysconfig/kubelet"); err != nil || contents != "KUBELET_EXTRA_ARGS=" {
+		t.Errorf("expected the uploaded contents, got %q (%v)", contents, err)
+	}
+
+	expected := `download /etc/os-release
+service-enabled skuba-update.timer
+apply kubelet.configure
+upload /etc/sysconfig/kubelet 0644
+apply kubeadm.join
+download /etc/machine-id
+download /etc/sysconfig/kubelet
+`
+	if transcript := actionable.Transcript(); transcript != expected {
+		t.Errorf("expected transcript:\n%s\ngot:\n%s", expected, transcript)
+	}
+}
+
+func TestNode(t *testing.T) {
+	node := NewNode()
+	node.Responses["systemctl"] = Response{Stdout: "active"}
+	node.Responses["systemctl is-enabled"] = Response{Stdout: "disabled", ExitCode: 1}
+	node.Errors["download /etc/kubernetes/admin.conf"] = errors.New("connection lost")
+
+	if stdout, _, exitCode, err := node.Run("systemctl is-active crio", ""); err != nil || stdout != "active" || exitCode != 0 {
+		t.Errorf("expected the systemctl response, got %q, %d (%v)", stdout, exitCode, err)
+	}
+	if stdout, _, exitCode, err := node.Run("systemctl is-enabled kubelet", ""); err != nil || stdout != "disabled" || exitCode != 1 {
+		t.Errorf("expected the longest matching response, got %q, %d (%v)", stdout, exitCode, err)
+	}
+	if stdout, _, exitCode, err := node.Run("modprobe vxlan", ""); err != nil || stdout != "" || exitCode != 0 {
+		t.Errorf("expected other commands to succeed without output, got %q, %d (%v)", stdout, exitCode, err)
+	}
+	if err := node.UploadFile("/etc/sysconfig/kubelet", []byte("KUBELET_EXTRA_ARGS="), 0644); err != nil {
+		t.Errorf("expected no error but got %v", err)
+	}
+	if contents, err := node.DownloadFile("/etc/sysconfig/kubelet"); err != nil || string(contents) != "KUBELET_EXTRA_ARGS=" {
+		t.Errorf("expected the uploaded contents, got %q (%v)", contents, err)
+	}
+	if _, err := node.DownloadFile("/etc/kubernetes/admin.conf"); err == nil {
+		t.Error("expected the download of admin.conf to fail")
+	}
+	if _, err := node.DownloadFile("/etc/machine-id"); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+
+	expected := `run systemctl is-active crio
+run systemctl is-enabled kubelet
+run modprobe vxlan
+upload /etc/sysconfig/kubelet 0644
+download /etc/sysconfig/kubelet
+download /etc/kubernetes/admin.conf
+download /etc/machine-id
+`
+	if transcript := node.Transcript(); transcript != expected {
+		t.Errorf("expected transcript:\n%s\ngot:\n%s", expected, transcript)
+	}
+}
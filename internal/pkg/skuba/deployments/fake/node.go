@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package fake
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Response is the canned result of a command run on a fake node
+type Response struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Node is a fake node recording the commands and file transfers run on it,
+// meant to be given to ssh.NewDeployment so that the real states are applied
+// on it. Responses are scripted through its exported fields, which must be
+// set before it is used.
+type Node struct {
+	// Files holds the contents of the files on the node, uploaded files are
+	// stored in it
+	Files map[string]string
+	// Responses holds the results of commands, keyed by a prefix of the
+	// command, the longest matching prefix wins. Other commands succeed
+	// without output.
+	Responses map[string]Response
+	// Errors holds the errors returned when commands can not be run or files
+	// transferred, keyed by their string representation, e.g.
+	// "run systemctl start crio" or "download /etc/kubernetes/admin.conf"
+	Errors map[string]error
+
+	mu       sync.Mutex
+	commands []string
+}
+
+// NewNode returns a fake SLES 15 SP2 node
+func NewNode() *Node {
+	return &Node{
+		Files: map[string]string{
+			"/etc/os-release": OsReleaseSLES15SP2,
+		},
+		Responses: map[string]Response{},
+		Errors:    map[string]error{},
+	}
+}
+
+func (n *Node) record(command string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.commands = append(n.commands, command)
+	return n.Errors[command]
+}
+
+// Run records the command and returns its scripted response
+func (n *Node) Run(command, stdin string) (string, string, int, error) {
+	if err := n.record("run " + command); err != nil {
+		return "", "", -1, err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var response Response
+	matched := -1
+	for prefix, candidate := range n.Responses {
+		if strings.HasPrefix(command, prefix) && len(prefix) > matched {
+			response, matched = candidate, len(prefix)
+		}
+	}
+	return response.Stdout, response.Stderr, response.ExitCode, nil
+}
+
+// UploadFile records the upload and stores the file contents
+func (n *Node) UploadFile(targetPath string, contents []byte, perm os.FileMode) error {
+	if err := n.record(fmt.Sprintf("upload %s %04o", targetPath, perm)); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Files[targetPath] = string(contents)
+	return nil
+}
+
+// DownloadFile records the download and returns the file contents
+func (n *Node) DownloadFile(sourcePath string) ([]byte, error) {
+	if err := n.record("download " + sourcePath); err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	contents, ok := n.Files[sourcePath]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: sourcePath, Err: os.ErrNotExist}
+	}
+	return []byte(contents), nil
+}
+
+// Commands returns the commands and file transfers recorded so far
+func (n *Node) Commands() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string{}, n.commands...)
+}
+
+// Transcript returns the recorded commands and file transfers, one per line
+func (n *Node) Transcript() string {
+	var transcript strings.Builder
+	for _, command := range n.Commands() {
+		fmt.Fprintln(&transcript, command)
+	}
+	return transcript.String()
+}
+
+// AssertGolden compares the transcript with the golden file, which is
+// rewritten instead when SKUBA_UPDATE_GOLDEN is set
+func (n *Node) AssertGolden(t testing.TB, goldenPath string) {
+	t.Helper()
+	assertGolden(t, goldenPath, n.Transcript())
+}
@@ -20,6 +20,7 @@ package deployments
 import (
 	"strings"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog"
 )
 
@@ -45,4 +46,11 @@ func MustGetRoleFromString(s string) (role Role) {
 type JoinConfiguration struct {
 	Role             Role
 	KubeadmExtraArgs map[string]string
+	// Labels are the labels the node registers with
+	Labels map[string]string
+	// Taints are the taints the node registers with
+	Taints []v1.Taint
+	// KubeletExtraArgs are added to the kubelet arguments of the node,
+	// overriding the ones of the join configuration template
+	KubeletExtraArgs map[string]string
 }
@@ -27,6 +27,72 @@ import (
 
 const (
 	SUSEOSID = "suse"
+
+	// the systemd units of the nodes with a read-only /usr are written to
+	// /etc instead
+	etcKubeletUnitFile   = "/etc/systemd/system/kubelet.service"
+	etcKubeletDropInFile = "/etc/systemd/system/kubelet.service.d/10-kubeadm.conf"
+)
+
+// PackageManager is the tool installing and removing the packages of a node
+type PackageManager string
+
+const (
+	// ZypperPackageManager installs packages with zypper
+	ZypperPackageManager PackageManager = "zypper"
+	// TransactionalUpdatePackageManager installs packages with
+	// transactional-update, in a new snapshot of a read-only root
+	TransactionalUpdatePackageManager PackageManager = "transactional-update"
+)
+
+// OSProfile describes how a family of operating systems is deployed
+type OSProfile struct {
+	// Name identifies the profile
+	Name string
+	// IDs are the /etc/os-release ID or ID_LIKE values of the family
+	IDs []string
+	// PackageManager installs the packages, none when empty
+	PackageManager PackageManager
+	// KubeletUnitFile is the location of the kubelet systemd unit
+	KubeletUnitFile string
+	// KubeletDropInFile is the location of the kubeadm drop-in of the kubelet
+	// systemd unit
+	KubeletDropInFile string
+	// SUSECNIDir is set when the CNI plugins are installed in the SUSE CNI
+	// directory instead of the kubelet default
+	SUSECNIDir bool
+}
+
+var (
+	// OSProfiles are the supported families of operating systems, the
+	// profile of a node is the first one matching its ID, or else the first
+	// one matching its ID_LIKE
+	OSProfiles = []OSProfile{
+		{
+			Name:              "sle-micro",
+			IDs:               []string{"sle-micro", "suse-microos", "opensuse-microos"},
+			PackageManager:    TransactionalUpdatePackageManager,
+			KubeletUnitFile:   etcKubeletUnitFile,
+			KubeletDropInFile: etcKubeletDropInFile,
+			SUSECNIDir:        true,
+		},
+		{
+			Name:              "suse",
+			IDs:               []string{"sles", "opensuse-leap", "opensuse-tumbleweed", SUSEOSID},
+			PackageManager:    ZypperPackageManager,
+			KubeletUnitFile:   "/usr/lib/systemd/system/kubelet.service",
+			KubeletDropInFile: "/usr/lib/systemd/system/kubelet.service.d/10-kubeadm.conf",
+			SUSECNIDir:        true,
+		},
+	}
+
+	// GenericOSProfile is the profile of the nodes not matching any of the
+	// supported families
+	GenericOSProfile = OSProfile{
+		Name:              "generic",
+		KubeletUnitFile:   "/lib/systemd/system/kubelet.service",
+		KubeletDropInFile: etcKubeletDropInFile,
+	}
 )
 
 func (t *Target) osRelease() (map[string]string, error) {
@@ -49,19 +115,48 @@ func (t *Target) osRelease() (map[string]string, error) {
 	return t.Cache.OsRelease, nil
 }
 
-func (t *Target) hasOS(os string) (bool, error) {
+// OSProfile returns the profile of the operating system of the target
+func (t *Target) OSProfile() (*OSProfile, error) {
 	osRelease, err := t.osRelease()
 	if err != nil {
-		return false, errors.Wrap(err, "could not retrieve OS release information")
+		return nil, errors.Wrap(err, "could not retrieve OS release information")
 	}
+	return osProfileFor(osRelease["ID"], strings.Fields(osRelease["ID_LIKE"])), nil
+}
 
-	if strings.Contains(osRelease["ID_LIKE"], os) {
-		return true, nil
-	}
+// Transactional returns the profile of the nodes of the family whose root
+// filesystem is read-only: the packages are installed with
+// transactional-update, and the kubelet units are written to /etc
+func (p OSProfile) Transactional() *OSProfile {
+	p.PackageManager = TransactionalUpdatePackageManager
+	p.KubeletUnitFile = etcKubeletUnitFile
+	p.KubeletDropInFile = etcKubeletDropInFile
+	return &p
+}
 
-	return false, nil
+func osProfileFor(id string, idLike []string) *OSProfile {
+	for _, osID := range append([]string{id}, idLike...) {
+		for i, profile := range OSProfiles {
+			for _, profileID := range profile.IDs {
+				if osID == profileID {
+					return &OSProfiles[i]
+				}
+			}
+		}
+	}
+	profile := GenericOSProfile
+	return &profile
 }
 
-func (t *Target) IsSUSEOS() (bool, error) {
-	return t.hasOS(SUSEOSID)
+// CheckOSVersion returns an error when the operating system release of the
+// target cannot be part of the cluster
+func (t *Target) CheckOSVersion() error {
+	osRelease, err := t.osRelease()
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve OS release information")
+	}
+	if strings.Contains(osRelease["VERSION_ID"], "15.1") {
+		return errors.New("SLES 15 SP1 nodes cannot join a CaaSP 4.5 (for SLES 15 SP2) cluster")
+	}
+	return nil
 }
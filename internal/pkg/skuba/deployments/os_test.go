@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package deployments
+
+import (
+	"testing"
+)
+
+func TestOSProfile(t *testing.T) {
+	tests := []struct {
+		name                   string
+		osRelease              map[string]string
+		expectedProfile        string
+		expectedPackageManager PackageManager
+		expectedSUSECNIDir     bool
+	}{
+		{
+			name:                   "SLES",
+			osRelease:              map[string]string{"ID": "sles", "ID_LIKE": "suse", "VERSION_ID": "15.2"},
+			expectedProfile:        "suse",
+			expectedPackageManager: ZypperPackageManager,
+			expectedSUSECNIDir:     true,
+		},
+		{
+			name:                   "openSUSE Tumbleweed",
+			osRelease:              map[string]string{"ID": "opensuse-tumbleweed", "ID_LIKE": "opensuse suse"},
+			expectedProfile:        "suse",
+			expectedPackageManager: ZypperPackageManager,
+			expectedSUSECNIDir:     true,
+		},
+		{
+			name:                   "SLE Micro",
+			osRelease:              map[string]string{"ID": "sle-micro", "ID_LIKE": "suse", "VERSION_ID": "5.0"},
+			expectedProfile:        "sle-micro",
+			expectedPackageManager: TransactionalUpdatePackageManager,
+			expectedSUSECNIDir:     true,
+		},
+		{
+			name:                   "openSUSE MicroOS",
+			osRelease:              map[string]string{"ID": "opensuse-microos", "ID_LIKE": "suse opensuse opensuse-tumbleweed microos"},
+			expectedProfile:        "sle-micro",
+			expectedPackageManager: TransactionalUpdatePackageManager,
+			expectedSUSECNIDir:     true,
+		},
+		{
+			name:                   "derivative of a SUSE distribution",
+			osRelease:              map[string]string{"ID": "custom", "ID_LIKE": "suse"},
+			expectedProfile:        "suse",
+			expectedPackageManager: ZypperPackageManager,
+			expectedSUSECNIDir:     true,
+		},
+		{
+			name:            "unsupported distribution",
+			osRelease:       map[string]string{"ID": "ubuntu", "ID_LIKE": "debian"},
+			expectedProfile: "generic",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			target := &Target{Cache: TargetCache{OsRelease: tt.osRelease}}
+			profile, err := target.OSProfile()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if profile.Name != tt.expectedProfile {
+				t.Errorf("expected profile %q, got %q", tt.expectedProfile, profile.Name)
+			}
+			if profile.PackageManager != tt.expectedPackageManager {
+				t.Errorf("expected package manager %q, got %q", tt.expectedPackageManager, profile.PackageManager)
+			}
+			if profile.SUSECNIDir != tt.expectedSUSECNIDir {
+				t.Errorf("expected the SUSE CNI directory to be used: %t, got %t", tt.expectedSUSECNIDir, profile.SUSECNIDir)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package deployments
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// PreflightChecks are the checks run on a candidate node before it is
+// bootstrapped or joined, in the order they are reported
+var PreflightChecks = []string{
+	"os",
+	"kernel-modules",
+	"swap",
+	"sysctl",
+	"ports",
+	"time-sync",
+	"dns",
+	"control-plane-endpoint",
+	"repositories",
+	"disk-space",
+}
+
+// PreflightConfiguration is the data the preflight checks of a candidate
+// node are run with
+type PreflightConfiguration struct {
+	// Role is the role the node will have in the cluster, the checks of
+	// every role are run when nil
+	Role *Role
+	// ControlPlaneEndpoint is the host[:port] of the control plane, the
+	// checks of the control plane are skipped when empty
+	ControlPlaneEndpoint string
+	// ControlPlaneRunning is set when the cluster has been bootstrapped, so
+	// that its control plane is expected to be reachable
+	ControlPlaneRunning bool
+	// KubernetesVersion is the version whose packages have to be available
+	// to the node
+	KubernetesVersion string
+}
+
+// Checker is implemented by the Actionables able to run preflight checks,
+// which inspect the target without changing it
+type Checker interface {
+	Check(data interface{}, check string) error
+}
+
+// CheckSkippedError is returned by the checks that do not apply to the target
+type CheckSkippedError struct {
+	Reason string
+}
+
+func (e *CheckSkippedError) Error() string {
+	return e.Reason
+}
+
+// SkipCheck returns the error of a check that does not apply to the target
+func SkipCheck(format string, args ...interface{}) error {
+	return &CheckSkippedError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// CheckResult holds the outcome of a check run on a target
+type CheckResult struct {
+	Name string
+	Err  error
+}
+
+// Skipped returns whether the check did not apply to the target
+func (r CheckResult) Skipped() bool {
+	_, skipped := errors.Cause(r.Err).(*CheckSkippedError)
+	return skipped
+}
+
+// Failed returns whether the check found a problem on the target
+func (r CheckResult) Failed() bool {
+	return r.Err != nil && !r.Skipped()
+}
+
+// Check runs the checks on the target, returning the outcome of each of them
+func (t *Target) Check(data interface{}, checks ...string) ([]CheckResult, error) {
+	checker, ok := t.Actionable.(Checker)
+	if !ok {
+		return nil, errors.Errorf("checks cannot be run on %s", t.Target)
+	}
+	results := []CheckResult{}
+	for _, check := range checks {
+		results = append(results, CheckResult{Name: check, Err: checker.Check(data, check)})
+	}
+	return results, nil
+}
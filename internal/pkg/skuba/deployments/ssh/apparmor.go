@@ -22,6 +22,6 @@ func init() {
 }
 
 func apparmorStart(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "enable", "--now", "apparmor")
+	_, err := t.ssh("systemctl", "enable", "--now", "apparmor")
 	return err
 }
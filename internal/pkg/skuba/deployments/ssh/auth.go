@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+	"k8s.io/klog"
+)
+
+var (
+	errSSHNoAuthMethodsErr = errors.New("no SSH authentication method available: load keys in the ssh-agent, or use --ssh-key or --ssh-password")
+
+	// prompt reads a secret from the terminal, tests replace it to answer
+	// the prompts
+	prompt = terminalPrompt
+)
+
+// credentials holds the private keys and the password read for a run. It is
+// shared by the targets of all the nodes of the run, so that each secret is
+// prompted for only once, even when the nodes are deployed in parallel.
+type credentials struct {
+	sync.Mutex
+	password *string
+	signers  map[string]ssh.Signer
+}
+
+// getCredentials returns the credentials shared with the other nodes
+func (t *Target) getCredentials() *credentials {
+	if t.credentials == nil {
+		t.credentials = &credentials{}
+	}
+	return t.credentials
+}
+
+// ResolveCredentials reads the private key given with --ssh-key, and prompts
+// for the password when --ssh-password is given, before connecting to the
+// nodes, so that the nodes deployed in parallel do not prompt concurrently
+func (t *Target) ResolveCredentials() error {
+	if t.local {
+		return nil
+	}
+	if t.sshKey != "" {
+		if _, err := t.keyFileSigner(t.sshKey); err != nil {
+			return err
+		}
+	}
+	if t.sshPassword {
+		if _, err := t.readPassword(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authMethods returns the SSH authentication methods to be tried against a
+// host, in order: ssh-agent (default), private key file, identity files from
+// the ssh config and password.
+//
+// The client tries each method type only once, so the keys of the ssh-agent,
+// the private key file and the identity files are all offered through a
+// single public key method.
+//
+// The returned function closes the connection to the ssh-agent, it has to be
+// called once the handshake using the methods is done.
+func (t *Target) authMethods(identityFiles []string) ([]ssh.AuthMethod, func(), error) {
+	methods := []ssh.AuthMethod{}
+
+	agentSigners, closeAgent := agentSigners()
+
+	signers := []ssh.Signer{}
+	if t.sshKey != "" {
+		signer, err := t.keyFileSigner(t.sshKey)
+		if err != nil {
+			closeAgent()
+			return nil, nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	// identity files from the ssh config are best effort, as with OpenSSH
+	for _, identityFile := range identityFiles {
+		if _, err := os.Stat(identityFile); err != nil {
+			klog.V(1).Infof("skipping identity file %s: %s", identityFile, err)
+			continue
+		}
+		signer, err := t.keyFileSigner(identityFile)
+		if err != nil {
+			klog.Warningf("skipping identity file %s: %s", identityFile, err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	if agentSigners != nil || len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			allSigners := []ssh.Signer{}
+			if agentSigners != nil {
+				keys, err := agentSigners()
+				if err != nil {
+					klog.Warningf("could not get the keys of the ssh-agent: %s", err)
+				}
+				allSigners = append(allSigners, keys...)
+			}
+			return append(allSigners, signers...), nil
+		}))
+	}
+
+	if t.sshPassword {
+		methods = append(methods, ssh.PasswordCallback(t.readPassword))
+	}
+
+	if len(methods) == 0 {
+		closeAgent()
+		return nil, nil, errSSHNoAuthMethodsErr
+	}
+	return methods, closeAgent, nil
+}
+
+// agentSigners returns a function listing the signers of the ssh-agent, or
+// nil if there is no usable agent or it has no keys loaded, and a function
+// closing the connection to the agent.
+func agentSigners() (func() ([]ssh.Signer, error), func()) {
+	noop := func() {}
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if len(socket) == 0 {
+		klog.V(1).Info("SSH_AUTH_SOCK is undefined, skipping ssh-agent authentication")
+		return nil, noop
+	}
+
+	agentConn, err := net.Dial("unix", socket)
+	if err != nil {
+		klog.Warningf("could not connect to the ssh-agent: %s", err)
+		return nil, noop
+	}
+	closeAgent := func() {
+		if err := agentConn.Close(); err != nil {
+			klog.V(1).Infof("could not close the connection to the ssh-agent: %s", err)
+		}
+	}
+	agentClient := agent.NewClient(agentConn)
+
+	keys, err := agentClient.List()
+	if err != nil {
+		klog.Warningf("could not list the keys in the ssh-agent: %s", err)
+		closeAgent()
+		return nil, noop
+	}
+	if len(keys) == 0 {
+		klog.V(1).Info("no keys have been loaded in the ssh-agent, skipping ssh-agent authentication")
+		closeAgent()
+		return nil, noop
+	}
+
+	return agentClient.Signers, closeAgent
+}
+
+// keyFileSigner parses the given private key, decrypting it with the
+// passphrase given by keyPassphrase when it is encrypted. The key is parsed
+// once and shared with the other nodes.
+func (t *Target) keyFileSigner(keyPath string) (ssh.Signer, error) {
+	credentials := t.getCredentials()
+	credentials.Lock()
+	defer credentials.Unlock()
+	if signer, ok := credentials.signers[keyPath]; ok {
+		return signer, nil
+	}
+	signer, err := t.parseKeyFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if credentials.signers == nil {
+		credentials.signers = map[string]ssh.Signer{}
+	}
+	credentials.signers[keyPath] = signer
+	return signer, nil
+}
+
+func (t *Target) parseKeyFile(keyPath string) (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read private key %s", keyPath)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return signer, nil
+	}
+	if _, ok := err.(*ssh.PassphraseMissingError); !ok {
+		return nil, errors.Wrapf(err, "could not parse private key %s", keyPath)
+	}
+
+	passphrase, err := t.keyPassphrase(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decrypt private key %s", keyPath)
+	}
+	return signer, nil
+}
+
+// keyPassphrase reads the passphrase of the key given with --ssh-key from
+// --ssh-key-passphrase-file. As with OpenSSH, the passphrase of any other key,
+// such as the identity files of the ssh config, is prompted for.
+func (t *Target) keyPassphrase(keyPath string) ([]byte, error) {
+	if t.sshKeyPassphraseFile != "" && keyPath == t.sshKey {
+		passphrase, err := ioutil.ReadFile(t.sshKeyPassphraseFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read passphrase file %s", t.sshKeyPassphraseFile)
+		}
+		return []byte(strings.TrimRight(string(passphrase), "\r\n")), nil
+	}
+	passphrase, err := prompt(fmt.Sprintf("Enter passphrase for key %s: ", keyPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read private key passphrase")
+	}
+	return []byte(passphrase), nil
+}
+
+// readPassword prompts for the SSH password once, and reuses it for any
+// further connection (e.g. bastion, target and the other nodes).
+func (t *Target) readPassword() (string, error) {
+	credentials := t.getCredentials()
+	credentials.Lock()
+	defer credentials.Unlock()
+	if credentials.password != nil {
+		return *credentials.password, nil
+	}
+	password, err := prompt(fmt.Sprintf("%s's password: ", t))
+	if err != nil {
+		return "", errors.Wrap(err, "could not read SSH password")
+	}
+	credentials.password = &password
+	return password, nil
+}
+
+func terminalPrompt(message string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("standard input is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, message)
+	value, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
@@ -0,0 +1,409 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const testPassword = "linux"
+
+func writeTestKey(t *testing.T, dir, name string, key *rsa.PrivateKey, passphrase string) string {
+	block := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+	if passphrase != "" {
+		var err error
+		//nolint:staticcheck
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatalf("could not encrypt key: %v", err)
+		}
+	}
+	keyPath := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("could not write key: %v", err)
+	}
+	return keyPath
+}
+
+// startTestServer starts an SSH server on the loopback interface, accepting
+// the given public key and testPassword
+func startTestServer(t *testing.T, authorizedKey ssh.PublicKey) net.Listener {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("could not create host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for channel := range channels {
+					_ = channel.Reject(ssh.Prohibited, "no channels allowed")
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+// startTestAgent starts an ssh-agent holding the given keys, and returns its
+// socket and the number of connections to it left open
+func startTestAgent(t *testing.T, dir string, keys ...*rsa.PrivateKey) (string, net.Listener, *int32) {
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatalf("could not add key to the agent: %v", err)
+		}
+	}
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	var openConnections int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&openConnections, 1)
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				atomic.AddInt32(&openConnections, -1)
+			}()
+		}
+	}()
+	return socket, listener, &openConnections
+}
+
+func TestAuthMethods(t *testing.T) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	defer func() {
+		if err := os.Setenv("SSH_AUTH_SOCK", socket); err != nil {
+			t.Errorf("could not restore SSH_AUTH_SOCK: %v", err)
+		}
+	}()
+
+	authorizedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	wrongKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	authorizedPublicKey, err := ssh.NewPublicKey(&authorizedKey.PublicKey)
+	if err != nil {
+		t.Fatalf("could not create public key: %v", err)
+	}
+	server := startTestServer(t, authorizedPublicKey)
+	defer server.Close()
+
+	tests := []struct {
+		name               string
+		agentKeys          []*rsa.PrivateKey
+		key                *rsa.PrivateKey
+		passphrase         string
+		passphraseFile     string
+		identityFile       *rsa.PrivateKey
+		identityPassphrase string
+		promptedPassphrase string
+		usePassword        bool
+		expectedErrorMatch bool
+		expectedAuthError  bool
+		expectedPrompts    []string
+	}{
+		{
+			name:               "no agent, key or password",
+			expectedErrorMatch: true,
+		},
+		{
+			name:      "agent key",
+			agentKeys: []*rsa.PrivateKey{authorizedKey},
+		},
+		{
+			name: "unencrypted private key",
+			key:  authorizedKey,
+		},
+		{
+			name:      "private key with a wrong key in the agent",
+			agentKeys: []*rsa.PrivateKey{wrongKey},
+			key:       authorizedKey,
+		},
+		{
+			name:           "encrypted private key with passphrase file and a wrong key in the agent",
+			agentKeys:      []*rsa.PrivateKey{wrongKey},
+			key:            authorizedKey,
+			passphrase:     "secret",
+			passphraseFile: "secret\n",
+		},
+		{
+			name:               "encrypted private key with wrong passphrase",
+			key:                authorizedKey,
+			passphrase:         "secret",
+			passphraseFile:     "wrong",
+			expectedErrorMatch: true,
+		},
+		{
+			name:         "identity file with a wrong key in the agent",
+			agentKeys:    []*rsa.PrivateKey{wrongKey},
+			identityFile: authorizedKey,
+		},
+		{
+			name:               "encrypted identity file with the passphrase file of another key",
+			key:                wrongKey,
+			passphrase:         "secret",
+			passphraseFile:     "secret",
+			identityFile:       authorizedKey,
+			identityPassphrase: "other",
+			promptedPassphrase: "other",
+			expectedPrompts:    []string{"identity"},
+		},
+		{
+			name:               "encrypted identity file without a passphrase",
+			key:                wrongKey,
+			passphrase:         "secret",
+			passphraseFile:     "secret",
+			identityFile:       authorizedKey,
+			identityPassphrase: "secret",
+			expectedAuthError:  true,
+			expectedPrompts:    []string{"identity"},
+		},
+		{
+			name:        "password with wrong keys",
+			agentKeys:   []*rsa.PrivateKey{wrongKey},
+			key:         wrongKey,
+			usePassword: true,
+		},
+		{
+			name:              "wrong keys only",
+			agentKeys:         []*rsa.PrivateKey{wrongKey},
+			key:               wrongKey,
+			expectedAuthError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-ssh-auth")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := os.Unsetenv("SSH_AUTH_SOCK"); err != nil {
+				t.Fatalf("could not unset SSH_AUTH_SOCK: %v", err)
+			}
+			var agentConnections *int32
+			if len(tt.agentKeys) > 0 {
+				var agentSocket string
+				var agentListener net.Listener
+				agentSocket, agentListener, agentConnections = startTestAgent(t, dir, tt.agentKeys...)
+				defer agentListener.Close()
+				if err := os.Setenv("SSH_AUTH_SOCK", agentSocket); err != nil {
+					t.Fatalf("could not set SSH_AUTH_SOCK: %v", err)
+				}
+			}
+
+			prompts := []string{}
+			defer func(previous func(string) (string, error)) { prompt = previous }(prompt)
+			prompt = func(message string) (string, error) {
+				prompts = append(prompts, message)
+				if tt.promptedPassphrase == "" {
+					return "", errors.New("not a terminal")
+				}
+				return tt.promptedPassphrase, nil
+			}
+
+			password := testPassword
+			target := Target{sshPassword: tt.usePassword, credentials: &credentials{password: &password}}
+			if tt.key != nil {
+				target.sshKey = writeTestKey(t, dir, "id_rsa", tt.key, tt.passphrase)
+			}
+			if tt.passphraseFile != "" {
+				target.sshKeyPassphraseFile = filepath.Join(dir, "passphrase")
+				if err := ioutil.WriteFile(target.sshKeyPassphraseFile, []byte(tt.passphraseFile), 0600); err != nil {
+					t.Fatalf("could not write passphrase file: %v", err)
+				}
+			}
+			identityFiles := []string{filepath.Join(dir, "missing")}
+			if tt.identityFile != nil {
+				identityFiles = append(identityFiles, writeTestKey(t, dir, "identity", tt.identityFile, tt.identityPassphrase))
+			}
+			expectedPrompts := []string{}
+			for _, keyName := range tt.expectedPrompts {
+				expectedPrompts = append(expectedPrompts, fmt.Sprintf("Enter passphrase for key %s: ", filepath.Join(dir, keyName)))
+			}
+
+			methods, closeAgent, err := target.authMethods(identityFiles)
+			if !reflect.DeepEqual(prompts, expectedPrompts) {
+				t.Errorf("expected prompts %v, got %v", expectedPrompts, prompts)
+			}
+			if tt.expectedErrorMatch {
+				if err == nil {
+					t.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error but got %v", err)
+				return
+			}
+
+			config, err := createClientConfig("sles", methods, ssh.InsecureIgnoreHostKey(), 10*time.Second)
+			if err != nil {
+				t.Fatalf("could not create client config: %v", err)
+			}
+			client, err := ssh.Dial("tcp", server.Addr().String(), config)
+			closeAgent()
+			if agentConnections != nil {
+				// the agent serves the connection until the client closes it
+				deadline := time.Now().Add(time.Second)
+				for atomic.LoadInt32(agentConnections) > 0 && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if open := atomic.LoadInt32(agentConnections); open != 0 {
+					t.Errorf("expected the connections to the agent to be closed, %d left open", open)
+				}
+			}
+			if tt.expectedAuthError {
+				if err == nil {
+					client.Close()
+					t.Error("expected an authentication error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected to authenticate but got %v", err)
+				return
+			}
+			client.Close()
+		})
+	}
+}
+
+func TestCredentialsSharedByNodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-ssh-auth")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	var prompts int32
+	defer func(previous func(string) (string, error)) { prompt = previous }(prompt)
+	prompt = func(message string) (string, error) {
+		atomic.AddInt32(&prompts, 1)
+		if strings.HasPrefix(message, "Enter passphrase") {
+			return "secret", nil
+		}
+		return testPassword, nil
+	}
+
+	target := &Target{}
+	target.GetFlags()
+	target.sshKey = writeTestKey(t, dir, "id_rsa", key, "secret")
+	target.sshPassword = true
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nodeTarget := target.WithConnection("sles", 0, false)
+			deployment := nodeTarget.GetNodeDeployment(fmt.Sprintf("10.0.0.%d", i), "", nil, "0")
+			node := deployment.Actionable.(*Target)
+			_, closeAgent, err := node.authMethods(nil)
+			if err != nil {
+				t.Errorf("expected no error but got %v", err)
+			} else {
+				closeAgent()
+			}
+			password, err := node.readPassword()
+			if err != nil || password != testPassword {
+				t.Errorf("expected password %q, got %q (%v)", testPassword, password, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if prompts != 2 {
+		t.Errorf("expected the passphrase and the password to be prompted once, got %d prompts", prompts)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// checkpoint records the states successfully applied on a node, so that an
+// interrupted deployment can be resumed without applying them again
+type checkpoint struct {
+	path   string
+	States []appliedState `json:"states"`
+}
+
+type appliedState struct {
+	Name string `json:"name"`
+	// DataHash identifies the data the state was applied with: a state is
+	// only skipped when resuming with the same data
+	DataHash  string    `json:"dataHash"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// loadCheckpoint reads the checkpoint at path, an empty checkpoint is
+// returned if it does not exist
+func loadCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{path: path}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read checkpoint %s", path)
+	}
+	if err := json.Unmarshal(contents, c); err != nil {
+		return nil, errors.Wrapf(err, "could not parse checkpoint %s", path)
+	}
+	return c, nil
+}
+
+// recorded returns whether the state has been applied, with any data
+func (c *checkpoint) recorded(name string) bool {
+	for _, state := range c.States {
+		if state.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applied returns whether the state has been applied with the same data
+func (c *checkpoint) applied(name, dataHash string) bool {
+	for _, state := range c.States {
+		if state.Name == name && state.DataHash == dataHash {
+			return true
+		}
+	}
+	return false
+}
+
+// record adds the state to the checkpoint and saves it
+func (c *checkpoint) record(name, dataHash string) error {
+	states := []appliedState{}
+	for _, state := range c.States {
+		if state.Name != name {
+			states = append(states, state)
+		}
+	}
+	c.States = append(states, appliedState{Name: name, DataHash: dataHash, AppliedAt: time.Now().UTC()})
+	return c.save()
+}
+
+func (c *checkpoint) save() error {
+	contents, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return errors.Wrapf(err, "could not create checkpoint directory %s", filepath.Dir(c.path))
+	}
+	tmpPath := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, contents, 0600); err != nil {
+		return errors.Wrapf(err, "could not write checkpoint %s", c.path)
+	}
+	return errors.Wrapf(os.Rename(tmpPath, c.path), "could not write checkpoint %s", c.path)
+}
+
+// remove deletes the checkpoint, if it exists
+func (c *checkpoint) remove() error {
+	c.States = nil
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove checkpoint %s", c.path)
+	}
+	return nil
+}
+
+// dataHash returns a hash identifying the data a state is applied with
+func dataHash(data interface{}) (string, error) {
+	contents, err := json.Marshal(data)
+	if err != nil {
+		return "", errors.Wrap(err, "could not serialize state data")
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), nil
+}
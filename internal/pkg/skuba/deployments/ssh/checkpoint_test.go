@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	fakedeployments "github.com/SUSE/skuba/internal/pkg/skuba/deployments/fake"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+func TestResume(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "skuba-checkpoint")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	//nolint:errcheck
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("could not change directory: %v", err)
+	}
+
+	applied := []string{}
+	failing := map[string]bool{}
+	for _, name := range []string{"test.reset", "test.install", "test.join"} {
+		name := name
+		stateMap[name] = func(t *Target, data interface{}) error {
+			if failing[name] {
+				return errors.New("failed")
+			}
+			applied = append(applied, name)
+			return nil
+		}
+		defer delete(stateMap, name)
+	}
+	installed := true
+	inEffectMap["test.install"] = func(t *Target, data interface{}) (bool, error) {
+		return installed, nil
+	}
+	defer delete(inEffectMap, "test.install")
+
+	newTarget := func(resume bool) *Target {
+		return &Target{local: true, resume: resume, target: &deployments.Target{Target: "node-0"}}
+	}
+	recordedStates := func() []string {
+		c, err := loadCheckpoint(skubaconstants.CheckpointFile("node-0"))
+		if err != nil {
+			t.Fatalf("could not load checkpoint: %v", err)
+		}
+		names := []string{}
+		for _, state := range c.States {
+			names = append(names, state.Name)
+		}
+		return names
+	}
+	data := deployments.JoinConfiguration{Role: deployments.WorkerRole}
+	states := []string{"test.reset", "test.install", "test.join"}
+
+	tests := []struct {
+		name             string
+		resume           bool
+		data             interface{}
+		failing          string
+		notInstalled     bool
+		complete         bool
+		errorExpected    bool
+		expectedApplied  []string
+		expectedRecorded []string
+	}{
+		{
+			name:             "interrupted deployment",
+			data:             data,
+			failing:          "test.join",
+			errorExpected:    true,
+			expectedApplied:  []string{"test.reset", "test.install"},
+			expectedRecorded: []string{"test.reset", "test.install"},
+		},
+		{
+			name:             "resumed deployment skips applied states",
+			resume:           true,
+			data:             data,
+			failing:          "test.join",
+			errorExpected:    true,
+			expectedApplied:  []string{},
+			expectedRecorded: []string{"test.reset", "test.install"},
+		},
+		{
+			name:             "resumed deployment applies again states no longer in effect",
+			resume:           true,
+			data:             data,
+			failing:          "test.join",
+			notInstalled:     true,
+			errorExpected:    true,
+			expectedApplied:  []string{"test.install"},
+			expectedRecorded: []string{"test.reset", "test.install"},
+		},
+		{
+			name:             "resumed deployment with different data",
+			resume:           true,
+			data:             deployments.JoinConfiguration{Role: deployments.MasterRole},
+			failing:          "test.join",
+			errorExpected:    true,
+			expectedApplied:  []string{"test.reset", "test.install"},
+			expectedRecorded: []string{"test.reset", "test.install"},
+		},
+		{
+			name:             "new deployment removes the previous checkpoint",
+			data:             data,
+			failing:          "test.reset",
+			errorExpected:    true,
+			expectedApplied:  []string{},
+			expectedRecorded: []string{},
+		},
+		{
+			name:             "resumed deployment after a new one applies all states",
+			resume:           true,
+			data:             data,
+			expectedApplied:  []string{"test.reset", "test.install", "test.join"},
+			expectedRecorded: []string{"test.reset", "test.install", "test.join"},
+		},
+		{
+			name:             "completed deployment removes the checkpoint",
+			data:             data,
+			complete:         true,
+			expectedApplied:  []string{"test.reset", "test.install", "test.join"},
+			expectedRecorded: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		applied = []string{}
+		failing = map[string]bool{tt.failing: true}
+		installed = !tt.notInstalled
+		target := newTarget(tt.resume)
+		err := target.Apply(tt.data, states...)
+		if err == nil && tt.complete {
+			err = target.ClearCheckpoint()
+		}
+		if tt.errorExpected && err == nil {
+			t.Errorf("%s: expected an error but got none", tt.name)
+		} else if !tt.errorExpected && err != nil {
+			t.Errorf("%s: expected no error but got %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(applied, tt.expectedApplied) {
+			t.Errorf("%s: expected applied states %v, got %v", tt.name, tt.expectedApplied, applied)
+		}
+		if recorded := recordedStates(); !reflect.DeepEqual(recorded, tt.expectedRecorded) {
+			t.Errorf("%s: expected recorded states %v, got %v", tt.name, tt.expectedRecorded, recorded)
+		}
+	}
+	if _, err := os.Stat(skubaconstants.CheckpointFile("node-0")); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed, got %v", err)
+	}
+}
+
+func TestApplyDryRunDoesNotHashData(t *testing.T) {
+	stateMap["test.noop"] = func(t *Target, data interface{}) error {
+		return nil
+	}
+	defer delete(stateMap, "test.noop")
+
+	// the data cannot be serialized, it is not needed as nothing is recorded
+	target := &Target{local: true, dryRun: true, target: &deployments.Target{Target: "node-0"}}
+	if err := target.Apply(make(chan int), "test.noop"); err != nil {
+		t.Errorf("expected no error but got %v", err)
+	}
+}
+
+func TestResumeFailedKubeadmJoin(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "skuba-checkpoint")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	//nolint:errcheck
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("could not change directory: %v", err)
+	}
+
+	// kubeadm join fails halfway on the first attempt, leaving the node
+	// configured: it only succeeds on a node reset in between
+	joinFailing := true
+	defer func(join Runner) { stateMap["kubeadm.join"] = join }(stateMap["kubeadm.join"])
+	stateMap["kubeadm.join"] = func(t *Target, data interface{}) error {
+		if joinFailing {
+			return errors.New("kubeadm join failed")
+		}
+		_, err := t.ssh("kubeadm", "join")
+		return err
+	}
+
+	node := fakedeployments.NewNode()
+	data := deployments.JoinConfiguration{Role: deployments.WorkerRole}
+	states := []string{"kubeadm.reset", "kubelet.enable", "kubeadm.join"}
+	apply := func(resume bool) error {
+		target := &Target{executor: node, resume: resume, target: &deployments.Target{Target: "node-0"}}
+		return target.Apply(data, states...)
+	}
+
+	if err := apply(false); err == nil {
+		t.Fatal("expected the join to fail")
+	}
+	joinFailing = false
+	if err := apply(true); err != nil {
+		t.Fatalf("expected the resumed join to succeed but got %v", err)
+	}
+	// once joined, resuming again skips the reset along with the join
+	if err := apply(true); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	expected := `run kubeadm reset --cri-socket /var/run/crio/crio.sock --ignore-preflight-errors all --force -v 
+run systemctl enable kubelet
+run kubeadm reset --cri-socket /var/run/crio/crio.sock --ignore-preflight-errors all --force -v 
+run systemctl is-enabled kubelet
+run kubeadm join
+run test -f /etc/kubernetes/kubelet.conf
+run systemctl is-enabled kubelet
+run test -f /etc/kubernetes/kubelet.conf
+`
+	if transcript := node.Transcript(); transcript != expected {
+		t.Errorf("expected transcript:\n%s\ngot:\n%s", expected, transcript)
+	}
+}
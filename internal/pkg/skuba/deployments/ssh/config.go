@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// defSystemSSHConfig is the system wide OpenSSH client configuration
+	defSystemSSHConfig = "/etc/ssh/ssh_config"
+	// maxProxyJumpDepth limits the recursion when resolving ProxyJump chains,
+	// protecting against loops in the ssh_config files
+	maxProxyJumpDepth = 10
+)
+
+// sshConfig holds the parsed OpenSSH client configuration files. Values are
+// looked up in order, the first file defining a value wins.
+type sshConfig []*ssh_config.Config
+
+// hop is a single SSH connection to be established, either to the target
+// itself or to one of the jump hosts in front of it.
+type hop struct {
+	alias         string
+	user          string
+	host          string
+	port          int
+	identityFiles []string
+}
+
+func (h hop) address() string {
+	return net.JoinHostPort(h.host, strconv.Itoa(h.port))
+}
+
+// defaultSSHConfigFile returns the path to the user OpenSSH client configuration
+func defaultSSHConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// loadSSHConfig parses the given ssh_config files, ignoring the ones that do
+// not exist.
+func loadSSHConfig(paths ...string) (sshConfig, error) {
+	config := sshConfig{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not open ssh config %s", path)
+		}
+		c, err := ssh_config.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse ssh config %s", path)
+		}
+		klog.V(2).Infof("using ssh config %s", path)
+		config = append(config, c)
+	}
+	return config, nil
+}
+
+func (c sshConfig) get(alias, key string) string {
+	for _, config := range c {
+		if value, err := config.Get(alias, key); err == nil && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (c sshConfig) getAll(alias, key string) []string {
+	for _, config := range c {
+		if values, err := config.GetAll(alias, key); err == nil && len(values) > 0 {
+			return values
+		}
+	}
+	return nil
+}
+
+// resolve fills the hop with the values of the ssh_config Host entry matching
+// its alias. Values already present in the hop take precedence.
+func (c sshConfig) resolve(h hop) (hop, error) {
+	if h.host == "" {
+		h.host = h.alias
+		if hostname := c.get(h.alias, "HostName"); hostname != "" {
+			h.host = strings.Replace(hostname, "%h", h.alias, -1)
+		}
+	}
+	if h.user == "" {
+		h.user = c.get(h.alias, "User")
+	}
+	if h.user == "" {
+		currentUser, err := user.Current()
+		if err != nil {
+			return h, errors.Wrap(err, "could not determine the SSH user")
+		}
+		h.user = currentUser.Username
+	}
+	if h.port == 0 {
+		h.port = defSSHPort
+		if port := c.get(h.alias, "Port"); port != "" {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return h, errors.Wrapf(err, "invalid port %q for host %s in ssh config", port, h.alias)
+			}
+			h.port = p
+		}
+	}
+	for _, identityFile := range c.getAll(h.alias, "IdentityFile") {
+		h.identityFiles = append(h.identityFiles, expandPath(identityFile, h.alias))
+	}
+	return h, nil
+}
+
+// chain returns the list of hops needed to reach the given one, following the
+// ProxyJump entries of the ssh_config, with the given hop itself last.
+func (c sshConfig) chain(h hop, depth int) ([]hop, error) {
+	if depth > maxProxyJumpDepth {
+		return nil, errors.Errorf("too many ProxyJump hops to reach %s, check your ssh config for loops", h.alias)
+	}
+	h, err := c.resolve(h)
+	if err != nil {
+		return nil, err
+	}
+	proxyJump := c.get(h.alias, "ProxyJump")
+	if proxyJump == "" || strings.EqualFold(proxyJump, "none") {
+		return []hop{h}, nil
+	}
+	hops := []hop{}
+	for _, spec := range strings.Split(proxyJump, ",") {
+		jumpHop, err := parseHopSpec(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+		jumpHops, err := c.chain(jumpHop, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, jumpHops...)
+	}
+	return append(hops, h), nil
+}
+
+// parseHopSpec parses a ProxyJump entry in the form [user@]host[:port]
+func parseHopSpec(spec string) (hop, error) {
+	h := hop{}
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		h.user = spec[:i]
+		spec = spec[i+1:]
+	}
+	h.alias = spec
+	if host, port, err := net.SplitHostPort(spec); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return h, errors.Wrapf(err, "invalid port in ProxyJump entry %q", spec)
+		}
+		h.alias = host
+		h.port = p
+	}
+	if h.alias == "" {
+		return h, fmt.Errorf("invalid ProxyJump entry %q", spec)
+	}
+	return h, nil
+}
+
+// expandPath expands the `~` and `%d` (home directory) and `%h` (host) tokens
+// supported by OpenSSH in IdentityFile paths
+func expandPath(path, alias string) string {
+	home, _ := os.UserHomeDir()
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(home, path[2:])
+	}
+	path = strings.Replace(path, "%d", home, -1)
+	return strings.Replace(path, "%h", alias, -1)
+}
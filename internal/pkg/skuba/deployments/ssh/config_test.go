@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testSSHConfig = `
+Host worker-*
+    HostName %h.example.com
+    User sles
+    Port 2222
+    ProxyJump jump2
+
+Host jump2
+    HostName 10.0.0.2
+    User jumper
+    ProxyJump admin@jump1:2200
+
+Host jump1
+    HostName 10.0.0.1
+
+Host loop
+    ProxyJump loop
+
+Host direct
+    HostName 192.168.0.10
+    ProxyJump none
+`
+
+func TestSSHConfigChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-ssh-config")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(configPath, []byte(testSSHConfig), 0600); err != nil {
+		t.Fatalf("could not write ssh config: %v", err)
+	}
+	config, err := loadSSHConfig(configPath, filepath.Join(dir, "does-not-exist"))
+	if err != nil {
+		t.Fatalf("could not load ssh config: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		target        hop
+		expectedHops  []string
+		errorExpected bool
+	}{
+		{
+			name:   "multi-hop ProxyJump chain",
+			target: hop{alias: "worker-3"},
+			expectedHops: []string{
+				"admin@10.0.0.1:2200",
+				"jumper@10.0.0.2:22",
+				"sles@worker-3.example.com:2222",
+			},
+		},
+		{
+			name:   "explicit values take precedence",
+			target: hop{alias: "worker-3", user: "root", port: 22},
+			expectedHops: []string{
+				"admin@10.0.0.1:2200",
+				"jumper@10.0.0.2:22",
+				"root@worker-3.example.com:22",
+			},
+		},
+		{
+			name:         "ProxyJump none",
+			target:       hop{alias: "direct", user: "root"},
+			expectedHops: []string{"root@192.168.0.10:22"},
+		},
+		{
+			name:         "unknown host",
+			target:       hop{alias: "10.1.1.1", user: "root"},
+			expectedHops: []string{"root@10.1.1.1:22"},
+		},
+		{
+			name:          "ProxyJump loop",
+			target:        hop{alias: "loop", user: "root"},
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			hops, err := config.chain(tt.target, 0)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error but got %v", err)
+				return
+			}
+			gotHops := []string{}
+			for _, h := range hops {
+				gotHops = append(gotHops, h.user+"@"+h.address())
+			}
+			if !reflect.DeepEqual(gotHops, tt.expectedHops) {
+				t.Errorf("expected hops %v, got %v", tt.expectedHops, gotHops)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+const (
+	defConnectTimeout    = 30 * time.Second
+	defKeepaliveInterval = 15 * time.Second
+	// keepaliveMaxFailures is the number of consecutive unanswered keepalive
+	// requests after which the connection is considered lost
+	keepaliveMaxFailures = 3
+	// maxReconnectAttempts is the number of times a command is retried on a
+	// new connection when the connection is lost
+	maxReconnectAttempts = 3
+	// streamsGracePeriod is the time given to the output streams of a timed
+	// out command to be flushed
+	streamsGracePeriod = 5 * time.Second
+)
+
+// isConnectionError returns whether the error was caused by the connection
+// to the target, as opposed to the remote command itself
+func isConnectionError(err error) bool {
+	switch errors.Cause(err).(type) {
+	case *deployments.CommandError, *ssh.ExitError:
+		return false
+	}
+	return true
+}
+
+// timeout returns the time a command can run for, the smallest of the
+// command timeout and the time left for the current state
+func (t *Target) timeout() time.Duration {
+	timeout := t.commandTimeout
+	if !t.stateDeadline.IsZero() {
+		remaining := time.Until(t.stateDeadline)
+		if remaining < time.Millisecond {
+			remaining = time.Millisecond
+		}
+		if timeout == 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return timeout
+}
+
+// drainStreams collects whatever output is available for a command that has
+// been aborted, without waiting on a connection that might be dead
+func drainStreams(stdoutChan, stderrChan <-chan string) (stdout string, stderr string) {
+	timer := time.NewTimer(streamsGracePeriod)
+	defer timer.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case stdout = <-stdoutChan:
+		case stderr = <-stderrChan:
+		case <-timer.C:
+			return stdout, stderr
+		}
+	}
+	return stdout, stderr
+}
+
+// startKeepalive periodically sends keepalive requests on the connection,
+// closing it when the target stops answering, so that running commands fail
+// instead of hanging forever
+func (t *Target) startKeepalive(client *ssh.Client) {
+	if t.keepaliveInterval <= 0 {
+		return
+	}
+	closed := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(closed)
+	}()
+	go func() {
+		ticker := time.NewTicker(t.keepaliveInterval)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ticker.C:
+			}
+			if err := sendKeepalive(client, t.keepaliveInterval); err != nil {
+				failures++
+				klog.V(2).Infof("keepalive request to %s failed (%d/%d): %s", t, failures, keepaliveMaxFailures, err)
+				if failures >= keepaliveMaxFailures {
+					klog.Warningf("%s is not answering keepalive requests, closing the connection", t)
+					client.Close()
+					return
+				}
+				continue
+			}
+			failures = 0
+		}
+	}()
+}
+
+func sendKeepalive(client *ssh.Client, timeout time.Duration) error {
+	errChan := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errChan <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-errChan:
+		return err
+	case <-timer.C:
+		return errors.New("keepalive request timed out")
+	}
+}
+
+// resetClient closes the current connection, a new one will be established
+// for the next command
+func (t *Target) resetClient() {
+	if t.sftpClient != nil {
+		t.sftpClient.Close()
+	}
+	t.sftpClient = nil
+	t.sftpErr = nil
+	if t.client != nil {
+		t.client.Close()
+	}
+	t.client = nil
+}
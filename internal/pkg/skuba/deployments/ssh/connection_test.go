@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		commandTimeout time.Duration
+		stateDeadline  time.Duration
+		expectedMin    time.Duration
+		expectedMax    time.Duration
+	}{
+		{
+			name: "no timeout",
+		},
+		{
+			name:           "command timeout only",
+			commandTimeout: time.Minute,
+			expectedMin:    time.Minute,
+			expectedMax:    time.Minute,
+		},
+		{
+			name:          "state deadline only",
+			stateDeadline: time.Minute,
+			expectedMin:   50 * time.Second,
+			expectedMax:   time.Minute,
+		},
+		{
+			name:           "state deadline shorter than command timeout",
+			commandTimeout: time.Hour,
+			stateDeadline:  time.Minute,
+			expectedMin:    50 * time.Second,
+			expectedMax:    time.Minute,
+		},
+		{
+			name:           "command timeout shorter than state deadline",
+			commandTimeout: time.Minute,
+			stateDeadline:  time.Hour,
+			expectedMin:    time.Minute,
+			expectedMax:    time.Minute,
+		},
+		{
+			name:          "state deadline exceeded",
+			stateDeadline: -time.Minute,
+			expectedMin:   time.Millisecond,
+			expectedMax:   time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			target := Target{commandTimeout: tt.commandTimeout}
+			if tt.stateDeadline != 0 {
+				target.stateDeadline = time.Now().Add(tt.stateDeadline)
+			}
+			timeout := target.timeout()
+			if timeout < tt.expectedMin || timeout > tt.expectedMax {
+				t.Errorf("expected timeout between %s and %s, got %s", tt.expectedMin, tt.expectedMax, timeout)
+			}
+		})
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "connection closed",
+			err:      io.EOF,
+			expected: true,
+		},
+		{
+			name:     "command failed",
+			err:      &deployments.CommandError{CommandResult: &deployments.CommandResult{Command: "false", ExitCode: 1}},
+			expected: false,
+		},
+		{
+			name:     "wrapped command failure",
+			err:      errors.Wrap(&deployments.CommandError{CommandResult: &deployments.CommandResult{Command: "false", ExitCode: 1}}, "failed to apply state"),
+			expected: false,
+		},
+		{
+			name:     "command timed out",
+			err:      &deployments.CommandError{CommandResult: &deployments.CommandResult{Command: "sleep 10", ExitCode: -1}, TimedOut: true},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionError(tt.err); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
@@ -31,6 +31,7 @@ func init() {
 	stateMap["cri.configure"] = criConfigure
 	stateMap["cri.sysconfig"] = criSysconfig
 	stateMap["cri.start"] = criStart
+	inEffectMap["cri.start"] = criStarted
 }
 
 func criConfigure(t *Target, data interface{}) error {
@@ -39,7 +40,7 @@ func criConfigure(t *Target, data interface{}) error {
 		return errors.Wrap(err, "Could not read local cri directory: "+skuba.CriConfDir())
 	}
 	defer func() {
-		_, _, err := t.ssh("rm -rf /tmp/crio.conf.d")
+		_, err := t.ssh("rm -rf /tmp/crio.conf.d")
 		if err != nil {
 			// If the deferred function has any return values, they are discarded when the function completes
 			// https://golang.org/ref/spec#Defer_statements
@@ -53,10 +54,10 @@ func criConfigure(t *Target, data interface{}) error {
 		}
 	}
 
-	if _, _, err = t.ssh("mkdir -p /etc/crio/crio.conf.d"); err != nil {
+	if _, err = t.ssh("mkdir -p /etc/crio/crio.conf.d"); err != nil {
 		return err
 	}
-	if _, _, err = t.ssh("cp -r /tmp/crio.conf.d/*.conf /etc/crio/crio.conf.d"); err != nil {
+	if _, err = t.ssh("cp -r /tmp/crio.conf.d/*.conf /etc/crio/crio.conf.d"); err != nil {
 		return err
 	}
 
@@ -66,7 +67,7 @@ func criConfigure(t *Target, data interface{}) error {
 		return nil
 	}
 	defer func() {
-		_, _, err := t.ssh("rm -rf /tmp/containers")
+		_, err := t.ssh("rm -rf /tmp/containers")
 		if err != nil {
 			// If the deferred function has any return values, they are discarded when the function completes
 			// https://golang.org/ref/spec#Defer_statements
@@ -78,20 +79,28 @@ func criConfigure(t *Target, data interface{}) error {
 		return err
 	}
 
-	if _, _, err = t.ssh("mkdir -p /etc/containers"); err != nil {
+	if _, err = t.ssh("mkdir -p /etc/containers"); err != nil {
 		return err
 	}
-	_, _, err = t.ssh("cp -r /tmp/containers/*.conf /etc/containers")
+	_, err = t.ssh("cp -r /tmp/containers/*.conf /etc/containers")
 	return err
 }
 
 // criSysconfig will enforce the package sysconfig configuration.
 func criSysconfig(t *Target, data interface{}) error {
-	_, _, err := t.ssh("cp -f /usr/share/fillup-templates/sysconfig.crio /etc/sysconfig/crio")
+	_, err := t.ssh("cp -f /usr/share/fillup-templates/sysconfig.crio /etc/sysconfig/crio")
 	return err
 }
 
 func criStart(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "enable", "--now", "crio")
+	_, err := t.ssh("systemctl", "enable", "--now", "crio")
 	return err
 }
+
+func criStarted(t *Target, data interface{}) (bool, error) {
+	enabled, err := t.IsServiceEnabled("crio")
+	if err != nil || !enabled {
+		return false, err
+	}
+	return t.isServiceActive("crio")
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// In dry-run mode, the commands changing the target are printed instead of
+// being run, while the silent commands, which only inspect the target, and
+// the downloads still happen.
+
+// dryRunOutput is where the dry-run plan is printed
+var dryRunOutput io.Writer = os.Stdout
+
+// dryRunCommand prints the command that would be run on the target
+func (t *Target) dryRunCommand(stdin string, command string, args ...string) *deployments.CommandResult {
+	finalCommand := strings.Join(append([]string{command}, args...), " ")
+	if t.sudo {
+		finalCommand = fmt.Sprintf("sudo sh -c '%s'", finalCommand)
+	}
+	if len(stdin) > 0 {
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would run %q with %d bytes of input\n", t.target.Target, finalCommand, len(stdin))
+	} else {
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would run %q\n", t.target.Target, finalCommand)
+	}
+	return &deployments.CommandResult{Command: finalCommand, Start: time.Now()}
+}
+
+// dryRunUpload prints the file that would be uploaded to the target, with the
+// changes to its current contents
+func (t *Target) dryRunUpload(targetPath string, contents io.Reader, perm os.FileMode) error {
+	newContents, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return err
+	}
+	// paths relative to the output of commands that did not run cannot be
+	// inspected
+	if !path.IsAbs(targetPath) {
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would upload %s (mode %04o, %d bytes)\n", t.target.Target, targetPath, perm, len(newContents))
+		return nil
+	}
+	current := bytes.Buffer{}
+	if err := t.DownloadFileStream(targetPath, &current); err != nil {
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would create %s (mode %04o, %d bytes)\n", t.target.Target, targetPath, perm, len(newContents))
+		return nil
+	}
+	if bytes.Equal(current.Bytes(), newContents) {
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would upload %s (mode %04o), contents unchanged\n", t.target.Target, targetPath, perm)
+		return nil
+	}
+	diff, err := fileDiff(targetPath, current.String(), string(newContents))
+	if err != nil {
+		return errors.Wrapf(err, "could not compute the changes to %s", targetPath)
+	}
+	fmt.Fprintf(dryRunOutput, "[dry-run] %s: would update %s (mode %04o):\n%s", t.target.Target, targetPath, perm, diff)
+	return nil
+}
+
+func fileDiff(name, current, updated string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(current),
+		B:        difflib.SplitLines(updated),
+		FromFile: name,
+		ToFile:   name,
+		Context:  3,
+	})
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-dry-run")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	existingFile := filepath.Join(dir, "existing")
+	if err := ioutil.WriteFile(existingFile, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	output := &bytes.Buffer{}
+	defer func() {
+		dryRunOutput = os.Stdout
+	}()
+	dryRunOutput = output
+
+	tests := []struct {
+		name           string
+		run            func(target *Target) error
+		expectedOutput []string
+		unchangedFile  string
+	}{
+		{
+			name: "commands changing the target are printed",
+			run: func(target *Target) error {
+				_, err := target.ssh("touch", filepath.Join(dir, "created"))
+				return err
+			},
+			expectedOutput: []string{`would run "touch ` + filepath.Join(dir, "created") + `"`},
+			unchangedFile:  filepath.Join(dir, "created"),
+		},
+		{
+			name: "silent commands are run",
+			run: func(target *Target) error {
+				result, err := target.silentSsh("cat", existingFile)
+				if err == nil && result.Stdout != "a\nb\nc" {
+					t.Errorf("unexpected output %q", result.Stdout)
+				}
+				return err
+			},
+		},
+		{
+			name: "uploads show the changes",
+			run: func(target *Target) error {
+				return target.UploadFileContents(existingFile, "a\nB\nc\n", 0600)
+			},
+			expectedOutput: []string{"would update " + existingFile + " (mode 0600)", "-b", "+B"},
+			unchangedFile:  existingFile,
+		},
+		{
+			name: "uploads of new files",
+			run: func(target *Target) error {
+				return target.UploadFileContents(filepath.Join(dir, "new"), "contents", 0644)
+			},
+			expectedOutput: []string{"would create " + filepath.Join(dir, "new") + " (mode 0644, 8 bytes)"},
+			unchangedFile:  filepath.Join(dir, "new"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			output.Reset()
+			var before []byte
+			if tt.unchangedFile != "" {
+				before, _ = ioutil.ReadFile(tt.unchangedFile)
+			}
+			target := &Target{local: true, dryRun: true, target: &deployments.Target{Target: "node-0"}}
+			if err := tt.run(target); err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			for _, expected := range tt.expectedOutput {
+				if !strings.Contains(output.String(), expected) {
+					t.Errorf("expected output to contain %q, got %q", expected, output.String())
+				}
+			}
+			if tt.unchangedFile != "" {
+				after, _ := ioutil.ReadFile(tt.unchangedFile)
+				if !bytes.Equal(before, after) {
+					t.Errorf("expected %s to be unchanged", tt.unchangedFile)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+const (
+	etcdClientPort       = 2379
+	defEtcdStopTimeout   = 5 * time.Minute
+	etcdStopPollInterval = 5 * time.Second
+	// previousDataDirMarker records, next to the stopped manifests, where the
+	// previous etcd data was moved to while it is replaced by the snapshot
+	previousDataDirMarker = "previous-data-dir"
+)
+
+var (
+	manifestsDir = "/etc/kubernetes/manifests"
+	// stoppedManifestsDir holds the static pod manifests of the control plane
+	// components stopped while etcd is restored
+	stoppedManifestsDir = "/etc/kubernetes/manifests.skuba-restore"
+	etcdRestoreSnapshot = "/var/lib/etcd-snapshots/restore.db"
+
+	// stoppedManifests are the static pods stopped while etcd is restored
+	stoppedManifests = []string{"etcd.yaml", "kube-apiserver.yaml"}
+)
+
+func init() {
+	stateMap["etcd.snapshot.upload"] = etcdSnapshotUpload
+	stateMap["etcd.restore"] = etcdRestore
+}
+
+// etcdSnapshotUpload uploads the snapshot to restore to the target, and checks
+// its integrity with etcdctl before anything is changed in the cluster
+func etcdSnapshotUpload(t *Target, data interface{}) error {
+	restoreConfiguration, ok := data.(deployments.EtcdRestoreConfiguration)
+	if !ok {
+		return errors.New("couldn't access etcd restore configuration")
+	}
+
+	if _, err := t.silentSsh("test", "-x", "/usr/bin/etcdctl"); err != nil {
+		if cmdErr, ok := errors.Cause(err).(*deployments.CommandError); !ok || cmdErr.ExitCode != 1 {
+			return err
+		}
+		if _, err := t.InstallPackages("etcdctl"); err != nil {
+			return errors.Wrap(err, "could not install etcdctl")
+		}
+	}
+
+	if _, err := t.ssh("mkdir", "-p", filepath.Dir(etcdRestoreSnapshot)); err != nil {
+		return err
+	}
+	if err := t.target.UploadFile(restoreConfiguration.SnapshotFile, etcdRestoreSnapshot, 0600); err != nil {
+		return errors.Wrap(err, "could not upload the etcd snapshot")
+	}
+	if _, err := t.ssh("env", "ETCDCTL_API=3", "etcdctl", "snapshot", "status", etcdRestoreSnapshot); err != nil {
+		if _, err := t.ssh("rm", "-f", etcdRestoreSnapshot); err != nil {
+			fmt.Println("Could not delete the etcd snapshot")
+		}
+		return errors.Wrapf(err, "the etcd snapshot %s is not valid", restoreConfiguration.SnapshotFile)
+	}
+	return nil
+}
+
+// etcdRestore replaces the data of the etcd member of the target with the
+// snapshot uploaded by etcd.snapshot.upload, as a new single member cluster.
+// The etcd and API server static pods are stopped while the data is restored,
+// and the previous data is kept next to the data directory. When the restore
+// fails, or when a previous one was interrupted, the previous data and the
+// static pods are put back as they were.
+func etcdRestore(t *Target, data interface{}) (err error) {
+	manifest, err := t.etcdManifest()
+	if err != nil {
+		return err
+	}
+	flags, err := etcdFlags(manifest)
+	if err != nil {
+		return err
+	}
+	name, peerURL, dataDir := flags["name"], flags["initial-advertise-peer-urls"], flags["data-dir"]
+	if name == "" || peerURL == "" || dataDir == "" {
+		return errors.New("could not find the name, peer URL and data directory of the etcd member in its static pod manifest")
+	}
+
+	if _, err := t.silentSsh("test", "-d", stoppedManifestsDir); err == nil {
+		klog.Infof("a previous etcd restore on %s did not complete, rolling it back", t)
+		if err := t.rollbackEtcdRestore(dataDir); err != nil {
+			return errors.Wrap(err, "could not roll back the previous etcd restore")
+		}
+	}
+
+	defer func() {
+		if _, err := t.ssh("rm", "-f", etcdRestoreSnapshot); err != nil {
+			fmt.Println("Could not delete the etcd snapshot")
+		}
+	}()
+
+	if _, err := t.ssh("mkdir", "-p", stoppedManifestsDir); err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		klog.Errorf("etcd restore failed on %s, rolling it back: %s", t, err)
+		if rollbackErr := t.rollbackEtcdRestore(dataDir); rollbackErr != nil {
+			err = errors.Wrapf(err, "could not roll back the etcd restore (%v), the previous etcd data and the static pod manifests are recorded in %s", rollbackErr, stoppedManifestsDir)
+		}
+	}()
+
+	for _, manifest := range stoppedManifests {
+		if _, err := t.ssh("mv", filepath.Join(manifestsDir, manifest), stoppedManifestsDir); err != nil {
+			return err
+		}
+	}
+	if err := t.waitForEtcdStop(); err != nil {
+		return err
+	}
+
+	previousDataDir := fmt.Sprintf("%s.before-restore-%s", dataDir, time.Now().UTC().Format("20060102-150405"))
+	if _, err := t.sshWithStdin(previousDataDir, "tee", filepath.Join(stoppedManifestsDir, previousDataDirMarker)); err != nil {
+		return err
+	}
+	if _, err := t.ssh("mv", dataDir, previousDataDir); err != nil {
+		return err
+	}
+	klog.Infof("previous etcd data of %s kept in %s", t, previousDataDir)
+	_, err = t.ssh("env", "ETCDCTL_API=3", "etcdctl", "snapshot", "restore", etcdRestoreSnapshot,
+		"--name", name,
+		"--initial-cluster", fmt.Sprintf("%s=%s", name, peerURL),
+		"--initial-advertise-peer-urls", peerURL,
+		"--data-dir", dataDir)
+	if err != nil {
+		return errors.Wrap(err, "could not restore the etcd snapshot")
+	}
+
+	for _, manifest := range stoppedManifests {
+		if _, err := t.ssh("mv", filepath.Join(stoppedManifestsDir, manifest), manifestsDir); err != nil {
+			return err
+		}
+	}
+	if _, err := t.ssh("rm", "-f", filepath.Join(stoppedManifestsDir, previousDataDirMarker)); err != nil {
+		return err
+	}
+	_, err = t.ssh("rmdir", stoppedManifestsDir)
+	return err
+}
+
+// etcdManifest returns the etcd static pod manifest, which is among the
+// stopped manifests when a previous restore did not complete
+func (t *Target) etcdManifest() (string, error) {
+	for _, dir := range []string{manifestsDir, stoppedManifestsDir} {
+		result, err := t.silentSsh("cat", filepath.Join(dir, "etcd.yaml"))
+		if err == nil {
+			return result.Stdout, nil
+		}
+		if cmdErr, ok := errors.Cause(err).(*deployments.CommandError); !ok || cmdErr.ExitCode != 1 {
+			return "", err
+		}
+	}
+	return "", errors.New("could not read the etcd static pod manifest, the node has to be a control plane")
+}
+
+// rollbackEtcdRestore puts back the previous etcd data recorded by the
+// restore, if it was moved already, and the stopped static pod manifests
+func (t *Target) rollbackEtcdRestore(dataDir string) error {
+	marker := filepath.Join(stoppedManifestsDir, previousDataDirMarker)
+	result, err := t.silentSsh("cat", marker)
+	if err == nil {
+		previousDataDir := strings.TrimSpace(result.Stdout)
+		if _, err := t.silentSsh("test", "-d", previousDataDir); err == nil {
+			if _, err := t.ssh("rm", "-rf", dataDir); err != nil {
+				return err
+			}
+			if _, err := t.ssh("mv", previousDataDir, dataDir); err != nil {
+				return err
+			}
+		}
+		if _, err := t.ssh("rm", "-f", marker); err != nil {
+			return err
+		}
+	} else if cmdErr, ok := errors.Cause(err).(*deployments.CommandError); !ok || cmdErr.ExitCode != 1 {
+		return err
+	}
+
+	for _, manifest := range stoppedManifests {
+		if _, err := t.silentSsh("test", "-f", filepath.Join(stoppedManifestsDir, manifest)); err != nil {
+			// the manifest was not moved, or was moved back already
+			continue
+		}
+		if _, err := t.ssh("mv", filepath.Join(stoppedManifestsDir, manifest), manifestsDir); err != nil {
+			return err
+		}
+	}
+	_, err = t.ssh("rmdir", stoppedManifestsDir)
+	return err
+}
+
+// waitForEtcdStop waits until etcd stopped listening for clients, once its
+// static pod manifest was removed
+func (t *Target) waitForEtcdStop() error {
+	if t.dryRun {
+		return nil
+	}
+	start := time.Now()
+	for time.Since(start) < defEtcdStopTimeout {
+		result, err := t.silentSsh("ss", "--no-header", "--listening", "--tcp", "--numeric")
+		if err != nil {
+			return err
+		}
+		if !listeningPorts(result.Stdout)[etcdClientPort] {
+			return nil
+		}
+		klog.V(2).Infof("etcd is still running on %s, waiting...", t)
+		time.Sleep(etcdStopPollInterval)
+	}
+	return errors.Errorf("etcd did not stop within %s on %s", defEtcdStopTimeout, t)
+}
+
+// etcdFlags returns the flags of the etcd command of its static pod manifest
+func etcdFlags(manifest string) (map[string]string, error) {
+	return staticPodFlags(manifest, "etcd")
+}
+
+// staticPodFlags returns the flags of the command of the named container of
+// a static pod manifest
+func staticPodFlags(manifest, name string) (map[string]string, error) {
+	pod := v1.Pod{}
+	if err := yaml.Unmarshal([]byte(manifest), &pod); err != nil {
+		return nil, errors.Wrapf(err, "could not parse the %s static pod manifest", name)
+	}
+	flags := map[string]string{}
+	for _, container := range pod.Spec.Containers {
+		if container.Name != name {
+			continue
+		}
+		for _, arg := range append(container.Command, container.Args...) {
+			parts := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)
+			if len(parts) == 2 && strings.HasPrefix(arg, "--") {
+				flags[parts[0]] = parts[1]
+			}
+		}
+	}
+	return flags, nil
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestEtcdFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		manifest      string
+		expected      map[string]string
+		errorExpected bool
+	}{
+		{
+			name: "kubeadm manifest",
+			manifest: `apiVersion: v1
+kind: Pod
+metadata:
+  name: etcd
+  namespace: kube-system
+spec:
+  containers:
+  - command:
+    - etcd
+    - --advertise-client-urls=https://10.0.0.10:2379
+    - --data-dir=/var/lib/etcd
+    - --initial-advertise-peer-urls=https://10.0.0.10:2380
+    - --name=master-0
+    image: registry.suse.com/caasp/v4.5/etcd:3.4.13
+    name: etcd
+  hostNetwork: true
+`,
+			expected: map[string]string{
+				"advertise-client-urls":       "https://10.0.0.10:2379",
+				"data-dir":                    "/var/lib/etcd",
+				"initial-advertise-peer-urls": "https://10.0.0.10:2380",
+				"name":                        "master-0",
+			},
+		},
+		{
+			name:     "no etcd container",
+			manifest: "spec:\n  containers:\n  - name: sidecar\n    command: [\"--name=sidecar\"]\n",
+			expected: map[string]string{},
+		},
+		{
+			name:          "invalid manifest",
+			manifest:      "spec: [",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := etcdFlags(tt.manifest)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			if !reflect.DeepEqual(flags, tt.expected) {
+				t.Errorf("expected flags %v, got %v", tt.expected, flags)
+			}
+		})
+	}
+}
+
+// fakeEtcdctl restores a snapshot by writing its contents in the data
+// directory
+const fakeEtcdctl = `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    restore) snapshot="$2" ;;
+    --data-dir) mkdir -p "$2/member" && cp "$snapshot" "$2/member/db" ;;
+  esac
+  shift
+done
+`
+
+func TestEtcdRestore(t *testing.T) {
+	defer func(manifests, stopped, snapshot, path string) {
+		manifestsDir, stoppedManifestsDir, etcdRestoreSnapshot = manifests, stopped, snapshot
+		os.Setenv("PATH", path) //nolint:errcheck
+	}(manifestsDir, stoppedManifestsDir, etcdRestoreSnapshot, os.Getenv("PATH"))
+
+	tests := []struct {
+		name                 string
+		etcdctl              bool
+		interrupted          bool
+		errorExpected        bool
+		expectedData         string
+		expectedPreviousData bool
+	}{
+		{
+			name:                 "successful restore",
+			etcdctl:              true,
+			expectedData:         "restored",
+			expectedPreviousData: true,
+		},
+		{
+			name:          "failed restore is rolled back",
+			errorExpected: true,
+			expectedData:  "previous",
+		},
+		{
+			name:                 "interrupted restore is rolled back first",
+			etcdctl:              true,
+			interrupted:          true,
+			expectedData:         "restored",
+			expectedPreviousData: true,
+		},
+		{
+			name:          "interrupted restore is rolled back on failure",
+			interrupted:   true,
+			errorExpected: true,
+			expectedData:  "previous",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-etcd-restore")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			manifestsDir = filepath.Join(dir, "manifests")
+			stoppedManifestsDir = filepath.Join(dir, "manifests.skuba-restore")
+			etcdRestoreSnapshot = filepath.Join(dir, "restore.db")
+			dataDir := filepath.Join(dir, "etcd")
+
+			writeFile := func(path, contents string) {
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatalf("could not create %s: %v", filepath.Dir(path), err)
+				}
+				if err := ioutil.WriteFile(path, []byte(contents), 0700); err != nil {
+					t.Fatalf("could not write %s: %v", path, err)
+				}
+			}
+			etcdManifest := fmt.Sprintf("spec:\n  containers:\n  - name: etcd\n    command:\n    - etcd\n    - --name=master-0\n    - --data-dir=%s\n    - --initial-advertise-peer-urls=https://127.0.0.1:2380\n", dataDir)
+			writeFile(etcdRestoreSnapshot, "restored")
+			bin := filepath.Join(dir, "bin")
+			if err := os.MkdirAll(bin, 0700); err != nil {
+				t.Fatalf("could not create %s: %v", bin, err)
+			}
+			if tt.etcdctl {
+				writeFile(filepath.Join(bin, "etcdctl"), fakeEtcdctl)
+			}
+			os.Setenv("PATH", bin+":/usr/bin:/bin") //nolint:errcheck
+
+			if tt.interrupted {
+				// stopped after the previous data was moved and partially restored
+				previousDataDir := dataDir + ".before-restore-20200101-000000"
+				writeFile(filepath.Join(stoppedManifestsDir, "etcd.yaml"), etcdManifest)
+				writeFile(filepath.Join(stoppedManifestsDir, "kube-apiserver.yaml"), "kube-apiserver")
+				writeFile(filepath.Join(stoppedManifestsDir, previousDataDirMarker), previousDataDir+"\n")
+				writeFile(filepath.Join(previousDataDir, "member", "db"), "previous")
+				writeFile(filepath.Join(dataDir, "member", "db"), "partial")
+				if err := os.MkdirAll(manifestsDir, 0700); err != nil {
+					t.Fatalf("could not create %s: %v", manifestsDir, err)
+				}
+			} else {
+				writeFile(filepath.Join(manifestsDir, "etcd.yaml"), etcdManifest)
+				writeFile(filepath.Join(manifestsDir, "kube-apiserver.yaml"), "kube-apiserver")
+				writeFile(filepath.Join(dataDir, "member", "db"), "previous")
+			}
+
+			target := &deployments.Target{Target: "127.0.0.1", Nodename: "master-0"}
+			target.Actionable = &Target{target: target, local: true}
+			err = etcdRestore(target.Actionable.(*Target), deployments.EtcdRestoreConfiguration{})
+			if tt.errorExpected && err == nil {
+				t.Errorf("error expected on %s, but no error reported", tt.name)
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err)
+			}
+
+			data, err := ioutil.ReadFile(filepath.Join(dataDir, "member", "db"))
+			if err != nil || string(data) != tt.expectedData {
+				t.Errorf("expected etcd data %q, got %q (%v)", tt.expectedData, data, err)
+			}
+			previousDataDirs, _ := filepath.Glob(dataDir + ".before-restore-*")
+			if tt.expectedPreviousData != (len(previousDataDirs) == 1) {
+				t.Errorf("expected previous etcd data kept: %v, got %v", tt.expectedPreviousData, previousDataDirs)
+			}
+			for _, manifest := range stoppedManifests {
+				if _, err := os.Stat(filepath.Join(manifestsDir, manifest)); err != nil {
+					t.Errorf("expected the %s static pod manifest to be in place: %v", manifest, err)
+				}
+			}
+			if _, err := os.Stat(stoppedManifestsDir); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be removed", stoppedManifestsDir)
+			}
+			if _, err := os.Stat(etcdRestoreSnapshot); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be removed", etcdRestoreSnapshot)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// Executor runs the commands and the file transfers of a target in place of
+// SSH, e.g. a fake node recording them in tests
+type Executor interface {
+	// Run runs the command, returning its output and exit code. The error is
+	// only set when the command could not be run.
+	Run(command, stdin string) (stdout, stderr string, exitCode int, err error)
+	UploadFile(targetPath string, contents []byte, perm os.FileMode) error
+	DownloadFile(sourcePath string) ([]byte, error)
+}
+
+// NewDeployment returns the deployment of a node whose commands and file
+// transfers are run by the executor, so that the real states can be applied
+// on a fake node
+func NewDeployment(executor Executor, targetName, nodename string, role *deployments.Role) *deployments.Target {
+	res := deployments.Target{
+		Target:   targetName,
+		Nodename: nodename,
+		Role:     role,
+	}
+	res.Actionable = &Target{
+		target:   &res,
+		executor: executor,
+	}
+	return &res
+}
+
+// runExecutorCommand runs the command of the result through the executor,
+// filling the result
+func (t *Target) runExecutorCommand(result *deployments.CommandResult, stdin string) error {
+	var err error
+	result.Stdout, result.Stderr, result.ExitCode, err = t.executor.Run(result.Command, stdin)
+	result.Duration = time.Since(result.Start)
+	if err != nil {
+		result.ExitCode = -1
+		return err
+	}
+	// the output read from SSH or local commands has no trailing newline
+	result.Stdout = strings.TrimSuffix(result.Stdout, "\n")
+	result.Stderr = strings.TrimSuffix(result.Stderr, "\n")
+	if result.ExitCode != 0 {
+		return &deployments.CommandError{CommandResult: result}
+	}
+	return nil
+}
+
+func (t *Target) uploadFileExecutor(targetPath string, contents io.Reader, perm os.FileMode) error {
+	data, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the contents of %s", targetPath)
+	}
+	return t.executor.UploadFile(targetPath, data, perm)
+}
+
+func (t *Target) downloadFileExecutor(sourcePath string, contents io.Writer) error {
+	data, err := t.executor.DownloadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(contents, bytes.NewReader(data))
+	return err
+}
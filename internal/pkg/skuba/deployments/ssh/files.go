@@ -18,40 +18,251 @@
 package ssh
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
 	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 )
 
 // UploadFileContents creates a file with the content sent
 // into a target system's specific path.
 func (t *Target) UploadFileContents(targetPath, contents string, perm os.FileMode) error {
+	return t.UploadFileStream(targetPath, strings.NewReader(contents), perm)
+}
+
+// UploadFileStream streams the contents into a target system's specific path
+// using SFTP, falling back to a shell pipeline when SFTP is not available. The
+// file is replaced atomically once its checksum has been verified, keeping the
+// ownership of the file it replaces.
+func (t *Target) UploadFileStream(targetPath string, contents io.Reader, perm os.FileMode) error {
 	klog.V(1).Infof("uploading to remote file %q with contents", targetPath)
-	dir, _ := path.Split(targetPath)
-	encodedContents := base64.StdEncoding.EncodeToString([]byte(contents))
-	if _, _, err := t.silentSsh("mkdir", "-p", dir); err != nil {
+	if t.dryRun {
+		return t.dryRunUpload(targetPath, contents, perm)
+	}
+	if t.executor != nil {
+		return t.uploadFileExecutor(targetPath, contents, perm)
+	}
+	if t.local && !t.sudo {
+		return uploadFileLocal(targetPath, contents, perm)
+	}
+	dir, file := path.Split(targetPath)
+	tmpPath := path.Join(dir, fmt.Sprintf(".%s.skuba-tmp", file))
+	hash := sha256.New()
+	contents = io.TeeReader(contents, hash)
+
+	sftpClient, err := t.sftp()
+	if err == nil {
+		err = t.uploadFileSFTP(sftpClient, tmpPath, contents, perm)
+	} else {
+		klog.V(2).Infof("SFTP is not available, falling back to shell upload: %s", err)
+		err = t.uploadFileShell(tmpPath, contents, perm)
+	}
+	if err != nil {
+		return err
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if err := t.verifyChecksum(tmpPath, checksum); err != nil {
+		if _, rmErr := t.silentSsh("rm", "-f", tmpPath); rmErr != nil {
+			klog.Warningf("could not delete the temporary file %s: %s", tmpPath, rmErr)
+		}
+		return err
+	}
+	return t.replaceFile(tmpPath, targetPath, checksum)
+}
+
+// replaceFile moves the temporary file over the target file, keeping its
+// ownership. Moving the file can not be retried blindly when the connection
+// is lost while it runs, as the temporary file is gone once it has been
+// moved: the target file is checked instead.
+func (t *Target) replaceFile(tmpPath, targetPath, checksum string) error {
+	replace := fmt.Sprintf("{ chown --reference=%[2]s %[1]s 2>/dev/null || true; } && mv -f %[1]s %[2]s", tmpPath, targetPath)
+	for attempt := 0; ; attempt++ {
+		_, err := t.internalSshWithStdin(true, false, "", replace)
+		if err == nil || !isConnectionError(err) || attempt >= maxReconnectAttempts {
+			return err
+		}
+		klog.Warningf("connection to %s lost while replacing %s: %s, checking whether it was replaced", t, targetPath, err)
+		t.resetClient()
+		if _, err := t.silentSsh("test", "-e", tmpPath); err != nil {
+			if _, isCommandError := errors.Cause(err).(*deployments.CommandError); !isCommandError {
+				return err
+			}
+			// the temporary file is gone: it has been moved
+			return t.verifyChecksum(targetPath, checksum)
+		}
+	}
+}
+
+// uploadFileSFTP streams the contents to a staging file owned by the SSH user,
+// and installs it as tmpPath with the right permissions, which might require
+// privileges the SSH user does not have.
+func (t *Target) uploadFileSFTP(sftpClient *sftp.Client, tmpPath string, contents io.Reader, perm os.FileMode) error {
+	stagingPath, err := stagingFilePath()
+	if err != nil {
 		return err
 	}
-	if _, _, err := t.silentSsh("install", "-m", fmt.Sprintf("%04o", perm), "/dev/null", targetPath); err != nil {
+	f, err := sftpClient.OpenFile(stagingPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return errors.Wrapf(err, "could not create staging file %s", stagingPath)
+	}
+	defer func() {
+		if err := sftpClient.Remove(stagingPath); err != nil {
+			klog.Warningf("could not delete the staging file %s: %s", stagingPath, err)
+		}
+	}()
+	if _, err := io.Copy(f, contents); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "could not write staging file %s", stagingPath)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "could not write staging file %s", stagingPath)
+	}
+	_, err = t.silentSsh(fmt.Sprintf("mkdir -p %s && install -m %04o %s %s", path.Dir(tmpPath), perm, stagingPath, tmpPath))
+	return err
+}
+
+// uploadFileShell pipes the base64 encoded contents to tmpPath through the
+// standard input of a remote shell.
+func (t *Target) uploadFileShell(tmpPath string, contents io.Reader, perm os.FileMode) error {
+	data, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return err
+	}
+	encodedContents := base64.StdEncoding.EncodeToString(data)
+	if _, err := t.silentSsh("mkdir", "-p", path.Dir(tmpPath)); err != nil {
 		return err
 	}
-	_, _, err := t.silentSshWithStdin(encodedContents, "base64", "-d", "-w0", fmt.Sprintf("> %s", targetPath))
+	if _, err := t.silentSsh("install", "-m", fmt.Sprintf("%04o", perm), "/dev/null", tmpPath); err != nil {
+		return err
+	}
+	_, err = t.silentSshWithStdin(encodedContents, "base64", "-d", "-w0", fmt.Sprintf("> %s", tmpPath))
 	return err
 }
 
 // DownloadFileContents gets the content of a file in a target system
 func (t *Target) DownloadFileContents(sourcePath string) (string, error) {
+	contents := bytes.Buffer{}
+	if err := t.DownloadFileStream(sourcePath, &contents); err != nil {
+		return "", err
+	}
+	return contents.String(), nil
+}
+
+// DownloadFileStream streams the content of a file in a target system using
+// SFTP, falling back to a shell pipeline when SFTP is not available. The
+// checksum of the received contents is verified against the remote file.
+func (t *Target) DownloadFileStream(sourcePath string, contents io.Writer) error {
 	klog.V(1).Infof("downloading remote file %q contents", sourcePath)
-	stdout, _, err := t.silentSsh("base64", "-w0", sourcePath)
+	if t.executor != nil {
+		return t.downloadFileExecutor(sourcePath, contents)
+	}
+	if t.local && !t.sudo {
+		return downloadFileLocal(sourcePath, contents)
+	}
+	sftpClient, err := t.sftp()
 	if err != nil {
-		return "", err
+		klog.V(2).Infof("SFTP is not available, falling back to shell download: %s", err)
+		return t.downloadFileShell(sourcePath, contents)
+	}
+
+	readPath := sourcePath
+	if t.sudo {
+		// the SSH user might not be allowed to read the file: stage a copy it owns
+		if readPath, err = stagingFilePath(); err != nil {
+			return err
+		}
+		if _, err := t.silentSsh("install", "-m", "0600", "-o", t.user, sourcePath, readPath); err != nil {
+			return err
+		}
+		defer func() {
+			if _, err := t.silentSsh("rm", "-f", readPath); err != nil {
+				klog.Warningf("could not delete the staging file %s: %s", readPath, err)
+			}
+		}()
+	}
+
+	f, err := sftpClient.Open(readPath)
+	if err != nil {
+		return errors.Wrapf(err, "could not open remote file %s", sourcePath)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(contents, hash), f); err != nil {
+		return errors.Wrapf(err, "could not read remote file %s", sourcePath)
+	}
+	return t.verifyChecksum(readPath, hex.EncodeToString(hash.Sum(nil)))
+}
+
+func (t *Target) downloadFileShell(sourcePath string, contents io.Writer) error {
+	result, err := t.silentSsh("base64", "-w0", sourcePath)
+	if err != nil {
+		return err
+	}
+	decodedStdout, err := base64.StdEncoding.DecodeString(result.Stdout)
+	if err != nil {
+		return err
 	}
-	decodedStdout, err := base64.StdEncoding.DecodeString(stdout)
+	checksum := sha256.Sum256(decodedStdout)
+	if err := t.verifyChecksum(sourcePath, hex.EncodeToString(checksum[:])); err != nil {
+		return err
+	}
+	_, err = contents.Write(decodedStdout)
+	return err
+}
+
+// verifyChecksum checks that the remote file has the expected sha256 checksum
+func (t *Target) verifyChecksum(remotePath, checksum string) error {
+	result, err := t.silentSsh("sha256sum", remotePath)
 	if err != nil {
+		return errors.Wrapf(err, "could not compute the checksum of remote file %s", remotePath)
+	}
+	fields := strings.Fields(result.Stdout)
+	if len(fields) == 0 || fields[0] != checksum {
+		return errors.Errorf("checksum mismatch for remote file %s: expected %s, got %q", remotePath, checksum, result.Stdout)
+	}
+	return nil
+}
+
+// sftp returns the SFTP client for the target, creating it on first use
+func (t *Target) sftp() (*sftp.Client, error) {
+	if t.sftpClient != nil {
+		return t.sftpClient, nil
+	}
+	if t.sftpErr != nil {
+		return nil, t.sftpErr
+	}
+	if t.local {
+		// privileged local file transfers go through sudo in a shell
+		return nil, errors.New("SFTP is not used for local targets")
+	}
+	if t.client == nil {
+		if err := t.initClient(); err != nil {
+			return nil, errors.Wrap(err, "failed to initialize client")
+		}
+	}
+	t.sftpClient, t.sftpErr = sftp.NewClient(t.client)
+	return t.sftpClient, t.sftpErr
+}
+
+// stagingFilePath returns a random path in the remote temporary directory
+func stagingFilePath() (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
 		return "", err
 	}
-	return string(decodedStdout), nil
+	return fmt.Sprintf("/tmp/.skuba-%x", suffix), nil
 }
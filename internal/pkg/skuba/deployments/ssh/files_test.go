@@ -0,0 +1,357 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// fakeSudo runs the command as the current user
+const fakeSudo = `#!/bin/sh
+exec "$@"
+`
+
+// fakeSha256sum prints a checksum that never matches
+const fakeSha256sum = `#!/bin/sh
+echo "0000000000000000000000000000000000000000000000000000000000000000  $1"
+`
+
+// fileServer is an SSH server on the loopback interface running the commands
+// locally, and serving SFTP unless it is disabled
+type fileServer struct {
+	net.Listener
+	sftpDisabled bool
+	// dropAfter closes the connection instead of returning the exit status
+	// of the first command containing it
+	dropAfter string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func startFileServer(t *testing.T, sftpDisabled bool, dropAfter string) *fileServer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("could not create host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	server := &fileServer{Listener: listener, sftpDisabled: sftpDisabled, dropAfter: dropAfter}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for newChannel := range channels {
+					if newChannel.ChannelType() != "session" {
+						_ = newChannel.Reject(ssh.UnknownChannelType, "only sessions are allowed")
+						continue
+					}
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go server.serveSession(conn, channel, requests)
+				}
+			}()
+		}
+	}()
+	return server
+}
+
+func (s *fileServer) serveSession(conn net.Conn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for request := range requests {
+		switch request.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(request.Payload, &payload); err != nil {
+				_ = request.Reply(false, nil)
+				continue
+			}
+			_ = request.Reply(true, nil)
+			s.mu.Lock()
+			s.commands = append(s.commands, payload.Command)
+			drop := s.dropAfter != "" && strings.Contains(payload.Command, s.dropAfter)
+			if drop {
+				s.dropAfter = ""
+			}
+			s.mu.Unlock()
+			cmd := exec.Command("sh", "-c", payload.Command)
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = channel, channel, channel.Stderr()
+			var status uint32
+			if err := cmd.Run(); err != nil {
+				status = 127
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					status = uint32(exitErr.ExitCode())
+				}
+			}
+			if drop {
+				conn.Close()
+				return
+			}
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(request.Payload, &payload); err != nil || payload.Name != "sftp" || s.sftpDisabled {
+				_ = request.Reply(false, nil)
+				continue
+			}
+			_ = request.Reply(true, nil)
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			_ = server.Serve()
+			return
+		default:
+			_ = request.Reply(false, nil)
+		}
+	}
+}
+
+// ran returns whether a command containing the given string was run
+func (s *fileServer) ran(command string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.commands {
+		if strings.Contains(c, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// newFileServerTarget returns a target connecting to the server, without
+// ssh config or host key checking
+func newFileServerTarget(t *testing.T, dir string, server *fileServer, sudo bool) *Target {
+	currentUser, err := user.Current()
+	if err != nil {
+		t.Fatalf("could not get the current user: %v", err)
+	}
+	sshConfigFile := filepath.Join(dir, "ssh_config")
+	if err := ioutil.WriteFile(sshConfigFile, []byte{}, 0600); err != nil {
+		t.Fatalf("could not write ssh config: %v", err)
+	}
+	password := testPassword
+	return &Target{
+		target:                &deployments.Target{Target: "127.0.0.1"},
+		user:                  currentUser.Username,
+		port:                  server.Addr().(*net.TCPAddr).Port,
+		sudo:                  sudo,
+		sshPassword:           true,
+		credentials:           &credentials{password: &password},
+		sshConfigFile:         sshConfigFile,
+		strictHostKeyChecking: "no",
+		knownHostsFile:        filepath.Join(dir, "known_hosts"),
+	}
+}
+
+// stagingFiles returns the staging files left in the temporary directory
+func stagingFiles(t *testing.T) map[string]bool {
+	files, err := filepath.Glob("/tmp/.skuba-*")
+	if err != nil {
+		t.Fatalf("could not list the staging files: %v", err)
+	}
+	result := map[string]bool{}
+	for _, file := range files {
+		result[file] = true
+	}
+	return result
+}
+
+func TestFileTransfers(t *testing.T) {
+	defer func(path string) {
+		os.Setenv("PATH", path) //nolint:errcheck
+	}(os.Getenv("PATH"))
+
+	tests := []struct {
+		name         string
+		sftpDisabled bool
+		sudo         bool
+		// badChecksum makes sha256sum print a checksum that never matches
+		badChecksum bool
+		// dropAfter closes the connection once a command containing it ran
+		dropAfter string
+		// expectedCommand is a command the transfers are expected to run
+		expectedCommand string
+		errorExpected   bool
+	}{
+		{
+			name:            "SFTP",
+			expectedCommand: "install -m 0640 /tmp/.skuba-",
+		},
+		{
+			name:            "SFTP with sudo",
+			sudo:            true,
+			expectedCommand: "sudo sh -c 'install -m 0600 -o ",
+		},
+		{
+			name:            "shell fallback when SFTP is not available",
+			sftpDisabled:    true,
+			expectedCommand: "base64 -d -w0",
+		},
+		{
+			name:            "connection lost once the file is replaced",
+			dropAfter:       "mv -f",
+			expectedCommand: "test -e",
+		},
+		{
+			name:          "SFTP checksum mismatch",
+			badChecksum:   true,
+			errorExpected: true,
+		},
+		{
+			name:          "shell checksum mismatch",
+			sftpDisabled:  true,
+			badChecksum:   true,
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-files")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			bin := filepath.Join(dir, "bin")
+			if err := os.Mkdir(bin, 0755); err != nil {
+				t.Fatalf("could not create bin directory: %v", err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(bin, "sudo"), []byte(fakeSudo), 0755); err != nil {
+				t.Fatalf("could not write fake sudo: %v", err)
+			}
+			if tt.badChecksum {
+				if err := ioutil.WriteFile(filepath.Join(bin, "sha256sum"), []byte(fakeSha256sum), 0755); err != nil {
+					t.Fatalf("could not write fake sha256sum: %v", err)
+				}
+			}
+			os.Setenv("PATH", bin+":/usr/bin:/bin") //nolint:errcheck
+
+			// the file to replace, owned by another user when possible
+			targetPath := filepath.Join(dir, "etc", "kubelet.conf")
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				t.Fatalf("could not create target directory: %v", err)
+			}
+			if err := ioutil.WriteFile(targetPath, []byte("old contents"), 0600); err != nil {
+				t.Fatalf("could not write target file: %v", err)
+			}
+			expectedUID, expectedGID := os.Getuid(), os.Getgid()
+			if os.Geteuid() == 0 {
+				expectedUID, expectedGID = 1234, 1234
+				if err := os.Chown(targetPath, expectedUID, expectedGID); err != nil {
+					t.Fatalf("could not change the owner of the target file: %v", err)
+				}
+			}
+
+			server := startFileServer(t, tt.sftpDisabled, tt.dropAfter)
+			defer server.Close()
+			target := newFileServerTarget(t, dir, server, tt.sudo)
+			defer target.resetClient()
+			stagingFilesBefore := stagingFiles(t)
+
+			contents := "new contents\nwith several lines\n"
+			err = target.UploadFileContents(targetPath, contents, 0640)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an upload error but got none")
+				}
+			} else if err != nil {
+				t.Errorf("expected no upload error but got %v", err)
+			}
+
+			expectedContents := contents
+			if tt.errorExpected {
+				expectedContents = "old contents"
+			}
+			if actual, err := ioutil.ReadFile(targetPath); err != nil || string(actual) != expectedContents {
+				t.Errorf("expected the target file to contain %q, got %q (%v)", expectedContents, actual, err)
+			}
+			info, err := os.Stat(targetPath)
+			if err != nil {
+				t.Fatalf("could not stat the target file: %v", err)
+			}
+			if !tt.errorExpected && info.Mode().Perm() != 0640 {
+				t.Errorf("expected the target file mode to be 0640, got %04o", info.Mode().Perm())
+			}
+			if stat := info.Sys().(*syscall.Stat_t); int(stat.Uid) != expectedUID || int(stat.Gid) != expectedGID {
+				t.Errorf("expected the target file to be owned by %d:%d, got %d:%d", expectedUID, expectedGID, stat.Uid, stat.Gid)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(targetPath), ".kubelet.conf.skuba-tmp")); !os.IsNotExist(err) {
+				t.Errorf("expected the temporary file to be removed, got %v", err)
+			}
+
+			downloaded, err := target.DownloadFileContents(targetPath)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected a download error but got none")
+				}
+			} else if err != nil {
+				t.Errorf("expected no download error but got %v", err)
+			} else if downloaded != contents {
+				t.Errorf("expected to download %q, got %q", contents, downloaded)
+			}
+
+			for file := range stagingFiles(t) {
+				if !stagingFilesBefore[file] {
+					t.Errorf("expected the staging file %s to be removed", file)
+				}
+			}
+			if tt.expectedCommand != "" && !server.ran(tt.expectedCommand) {
+				t.Errorf("expected a command containing %q to run, got %q", tt.expectedCommand, server.commands)
+			}
+		})
+	}
+}
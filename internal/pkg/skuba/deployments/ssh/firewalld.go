@@ -18,19 +18,91 @@
 package ssh
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
 	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/firewall"
+	"github.com/SUSE/skuba/internal/pkg/skuba/node"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
 )
 
 func init() {
 	stateMap["firewalld.disable"] = firewalldDisable
+	stateMap["firewalld.configure"] = firewalldConfigure
 }
 
 func firewalldDisable(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "cat", "firewalld")
+	_, err := t.ssh("systemctl", "cat", "firewalld")
 	if err == nil {
-		_, _, err := t.ssh("systemctl", "disable", "--now", "firewalld")
+		_, err := t.ssh("systemctl", "disable", "--now", "firewalld")
 		return err
 	}
 	klog.V(4).Info("=== Could not find firewalld.service ===")
 	return nil
 }
+
+// firewalldConfigure opens the ports of the role of the node, and the extra
+// ports of the firewall configuration, with the skuba firewalld service
+func firewalldConfigure(t *Target, data interface{}) error {
+	if _, err := t.silentSsh("systemctl", "cat", "firewalld"); err != nil {
+		if _, ok := errors.Cause(err).(*deployments.CommandError); ok {
+			klog.V(4).Info("=== Could not find firewalld.service ===")
+			return nil
+		}
+		return err
+	}
+	firewallConfiguration, err := firewall.Load(skubaconstants.FirewallConfFile())
+	if err != nil {
+		return err
+	}
+	nodePortRange, err := nodePortRange()
+	if err != nil {
+		return err
+	}
+	service, err := firewall.ServiceFile(firewallConfiguration.RolePorts(t.target.Role, nodePortRange))
+	if err != nil {
+		return err
+	}
+	if err := t.UploadFileContents(fmt.Sprintf("/etc/firewalld/services/%s.xml", firewall.ServiceName), service, 0644); err != nil {
+		return err
+	}
+
+	addService := []string{fmt.Sprintf("--add-service=%s", firewall.ServiceName)}
+	if firewallConfiguration.Zone != "" {
+		addService = append([]string{fmt.Sprintf("--zone=%s", firewallConfiguration.Zone)}, addService...)
+	}
+	if _, err := t.silentSsh("systemctl", "is-active", "--quiet", "firewalld"); err != nil {
+		if _, ok := errors.Cause(err).(*deployments.CommandError); !ok {
+			return err
+		}
+		// the configuration is used once firewalld is started
+		_, err := t.ssh("firewall-offline-cmd", addService...)
+		return err
+	}
+	// the service has to be loaded before it can be added to the zone
+	if _, err := t.ssh("firewall-cmd", "--reload"); err != nil {
+		return err
+	}
+	if _, err := t.ssh("firewall-cmd", append([]string{"--permanent"}, addService...)...); err != nil {
+		return err
+	}
+	_, err = t.ssh("firewall-cmd", "--reload")
+	return err
+}
+
+// nodePortRange returns the range of the NodePort services set on the API
+// server of the cluster, if any
+func nodePortRange() (string, error) {
+	initConfiguration, err := node.LoadInitConfigurationFromFile(skubaconstants.KubeadmInitConfFile())
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "could not parse %s file", skubaconstants.KubeadmInitConfFile())
+	}
+	return initConfiguration.APIServer.ExtraArgs["service-node-port-range"], nil
+}
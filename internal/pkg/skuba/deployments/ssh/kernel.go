@@ -19,34 +19,14 @@ package ssh
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/pkg/errors"
-)
-
-var (
-	modules = []string{
-		"br_netfilter",
-		"vxlan",
-	}
 
-	parameters = map[string]struct {
-		Attribute string
-		Value     string
-	}{
-		"net-ipv4-ip-forward": {
-			Attribute: "net.ipv4.ip_forward",
-			Value:     "1",
-		},
-		"net-ipv4-conf-all-forwarding": {
-			Attribute: "net.ipv4.conf.all.forwarding",
-			Value:     "1",
-		},
-		"net-bridge-bridge-nf-call-iptables": {
-			Attribute: "net.bridge.bridge-nf-call-iptables",
-			Value:     "1",
-		},
-	}
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kernel"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
 )
 
 func init() {
@@ -55,9 +35,19 @@ func init() {
 	stateMap["kernel.configure-parameters"] = kernelConfigureParameters
 }
 
+// kernelConfiguration returns the kernel configuration of the cluster
+// definition folder, only the required entries are configured without it
+func kernelConfiguration() (*kernel.Configuration, error) {
+	return kernel.Load(skubaconstants.KernelConfFile())
+}
+
 func kernelCheckModules(t *Target, data interface{}) error {
+	kernelConfiguration, err := kernelConfiguration()
+	if err != nil {
+		return err
+	}
 	errs := []string{}
-	for _, module := range modules {
+	for _, module := range kernelConfiguration.AllModules() {
 		if err := infoModule(t, module); err != nil {
 			errs = append(errs, fmt.Sprintf("module %s not found: %s", module, err))
 		}
@@ -69,40 +59,75 @@ func kernelCheckModules(t *Target, data interface{}) error {
 }
 
 func kernelLoadModules(t *Target, data interface{}) error {
-	for _, module := range modules {
+	kernelConfiguration, err := kernelConfiguration()
+	if err != nil {
+		return err
+	}
+	files := map[string]bool{}
+	for _, module := range kernelConfiguration.AllModules() {
 		if err := loadModule(t, module); err != nil {
 			return err
 		}
+		files[kernel.ModuleFile(module)] = true
 	}
-	return nil
+	return removeStaleFiles(t, "/etc/modules-load.d", "skuba-", files)
 }
 
 func kernelConfigureParameters(t *Target, data interface{}) error {
-	for parameterName, parameter := range parameters {
-		if err := configureParameter(t, parameterName, parameter.Attribute, parameter.Value); err != nil {
+	kernelConfiguration, err := kernelConfiguration()
+	if err != nil {
+		return err
+	}
+	parameters := kernelConfiguration.AllParameters()
+	files := map[string]bool{}
+	for _, parameter := range kernel.ParameterNames(parameters) {
+		if err := configureParameter(t, parameter, parameters[parameter]); err != nil {
 			return err
 		}
+		files[kernel.ParameterFile(parameter)] = true
 	}
-	return nil
+	return removeStaleFiles(t, "/etc/sysctl.d", "90-skuba-", files)
 }
 
 func infoModule(t *Target, module string) error {
-	if _, _, err := t.ssh(fmt.Sprintf("modinfo %s", module)); err != nil {
+	if _, err := t.silentSsh("modinfo", module); err != nil {
 		return err
 	}
 	return nil
 }
 
 func loadModule(t *Target, module string) error {
-	if _, _, err := t.ssh(fmt.Sprintf("modprobe %s", module)); err != nil {
+	if _, err := t.ssh(fmt.Sprintf("modprobe %s", module)); err != nil {
 		return err
 	}
-	return t.UploadFileContents(fmt.Sprintf("/etc/modules-load.d/skuba-%s.conf", module), module, 0644)
+	return t.UploadFileContents(kernel.ModuleFile(module), module, 0644)
 }
 
-func configureParameter(t *Target, name, attribute, value string) error {
-	if _, _, err := t.ssh(fmt.Sprintf("sysctl -w %s=%s", attribute, value)); err != nil {
+func configureParameter(t *Target, attribute, value string) error {
+	if _, err := t.ssh("sysctl", "-w", fmt.Sprintf(`"%s=%s"`, attribute, value)); err != nil {
 		return err
 	}
-	return t.UploadFileContents(fmt.Sprintf("/etc/sysctl.d/90-skuba-%s.conf", name), fmt.Sprintf("%s=%s", attribute, value), 0644)
+	return t.UploadFileContents(kernel.ParameterFile(attribute), fmt.Sprintf("%s=%s", attribute, value), 0644)
+}
+
+// removeStaleFiles removes the files of dir written by skuba, named with the
+// prefix, that are no longer configured
+func removeStaleFiles(t *Target, dir, prefix string, configured map[string]bool) error {
+	result, err := t.silentSsh("ls", "-1", dir)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*deployments.CommandError); ok {
+			return nil
+		}
+		return err
+	}
+	for _, name := range strings.Fields(result.Stdout) {
+		file := path.Join(dir, name)
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".conf") || configured[file] {
+			continue
+		}
+		if _, err := t.ssh("rm", "-f", file); err != nil {
+			return errors.Wrapf(err, "could not remove %s", file)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestRemoveStaleFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-sysctl")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"90-skuba-net-ipv4-ip-forward.conf", "90-skuba-fs-inotify-max-user-watches.conf", "99-custom.conf"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0644); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	target := &Target{local: true, target: &deployments.Target{Target: "node-0"}}
+	configured := map[string]bool{filepath.Join(dir, "90-skuba-net-ipv4-ip-forward.conf"): true}
+	if err := removeStaleFiles(target, dir, "90-skuba-", configured); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("could not list %s: %v", dir, err)
+	}
+	sort.Strings(files)
+	expected := []string{filepath.Join(dir, "90-skuba-net-ipv4-ip-forward.conf"), filepath.Join(dir, "99-custom.conf")}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files %v, got %v", expected, files)
+	}
+
+	if err := removeStaleFiles(target, filepath.Join(dir, "missing"), "90-skuba-", configured); err != nil {
+		t.Errorf("expected a missing directory to be ignored, got %v", err)
+	}
+}
@@ -19,10 +19,14 @@ package ssh
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/klog"
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
@@ -36,6 +40,10 @@ func init() {
 	stateMap["kubeadm.reset"] = kubeadmReset
 	stateMap["kubeadm.upgrade.apply"] = kubeadmUpgradeApply
 	stateMap["kubeadm.upgrade.node"] = kubeadmUpgradeNode
+	stateMap["kubeadm.certs.renew"] = kubeadmCertsRenew
+	inEffectMap["kubeadm.init"] = kubeadmNodeConfigured
+	inEffectMap["kubeadm.join"] = kubeadmNodeConfigured
+	preparingMap["kubeadm.reset"] = []string{"kubeadm.init", "kubeadm.join"}
 }
 
 func kubeadmInit(t *Target, data interface{}) error {
@@ -44,10 +52,11 @@ func kubeadmInit(t *Target, data interface{}) error {
 		return errors.New("couldn't access bootstrap configuration")
 	}
 
-	tempDir, _, err := t.ssh("mktemp", "-d")
+	result, err := t.ssh("mktemp", "-d")
 	if err != nil {
 		return err
 	}
+	tempDir := result.Stdout
 	f, err := os.Stat(skubaconstants.KubeadmInitConfFile())
 	if err != nil {
 		return err
@@ -58,7 +67,7 @@ func kubeadmInit(t *Target, data interface{}) error {
 		return err
 	}
 	defer func() {
-		_, _, err := t.ssh("rm", "-r", tempDir)
+		_, err := t.ssh("rm", "-r", tempDir)
 		if err != nil {
 			// If the deferred function has any return values, they are discarded when the function completes
 			// https://golang.org/ref/spec#Defer_statements
@@ -71,7 +80,7 @@ func kubeadmInit(t *Target, data interface{}) error {
 	if len(ignorePreflightErrorsVal) > 0 {
 		ignorePreflightErrors = "--ignore-preflight-errors=" + ignorePreflightErrorsVal
 	}
-	_, _, err = t.ssh("kubeadm", "init", "--config", remoteKubeadmInitConfFile, "--skip-token-print", ignorePreflightErrors, "-v", t.verboseLevel)
+	_, err = t.ssh("kubeadm", "init", "--config", remoteKubeadmInitConfFile, "--skip-token-print", ignorePreflightErrors, "-v", t.verboseLevel)
 	return err
 }
 
@@ -85,15 +94,24 @@ func kubeadmJoin(t *Target, data interface{}) error {
 		return errors.New("couldn't access join configuration")
 	}
 
-	configPath, err := join.ConfigPath(api, joinConfiguration.Role, t.target)
-	if err != nil {
+	var configPath string
+	if t.dryRun {
+		// rendering the configuration creates a bootstrap token in the
+		// cluster: show the configuration it would be rendered from instead
+		configPath = skubaconstants.MachineConfFile(t.target.Target)
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			configPath = skubaconstants.TemplatePathForRole(joinConfiguration.Role)
+		}
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would render the join configuration from %s with a fresh bootstrap token\n", t.target.Target, configPath)
+	} else if configPath, err = join.ConfigPath(api, joinConfiguration, t.target); err != nil {
 		return errors.Wrap(err, "unable to configure path")
 	}
 
-	tempDir, _, err := t.ssh("mktemp", "-d")
+	result, err := t.ssh("mktemp", "-d")
 	if err != nil {
 		return err
 	}
+	tempDir := result.Stdout
 	f, err := os.Stat(configPath)
 	if err != nil {
 		return err
@@ -104,7 +122,7 @@ func kubeadmJoin(t *Target, data interface{}) error {
 		return err
 	}
 	defer func() {
-		_, _, err := t.ssh("rm", "-r", remoteKubeadmInitConfFile)
+		_, err := t.ssh("rm", "-r", remoteKubeadmInitConfFile)
 		if err != nil {
 			// If the deferred function has any return values, they are discarded when the function completes
 			// https://golang.org/ref/spec#Defer_statements
@@ -117,15 +135,177 @@ func kubeadmJoin(t *Target, data interface{}) error {
 	if len(ignorePreflightErrorsVal) > 0 {
 		ignorePreflightErrors = "--ignore-preflight-errors=" + ignorePreflightErrorsVal
 	}
-	_, _, err = t.ssh("kubeadm", "join", "--config", remoteKubeadmInitConfFile, ignorePreflightErrors, "-v", t.verboseLevel)
+	_, err = t.ssh("kubeadm", "join", "--config", remoteKubeadmInitConfFile, ignorePreflightErrors, "-v", t.verboseLevel)
 	return err
 }
 
+// kubeadmNodeConfigured returns whether the node has been initialized or
+// joined, and not reset since
+func kubeadmNodeConfigured(t *Target, data interface{}) (bool, error) {
+	_, err := t.silentSsh("test", "-f", "/etc/kubernetes/kubelet.conf")
+	if err != nil {
+		if _, isCommandError := errors.Cause(err).(*deployments.CommandError); isCommandError {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 func kubeadmReset(t *Target, data interface{}) error {
-	_, _, err := t.ssh("kubeadm", "reset", "--cri-socket", "/var/run/crio/crio.sock", "--ignore-preflight-errors", "all", "--force", "-v", t.verboseLevel)
+	_, err := t.ssh("kubeadm", "reset", "--cri-socket", "/var/run/crio/crio.sock", "--ignore-preflight-errors", "all", "--force", "-v", t.verboseLevel)
 	return err
 }
 
+const defAPIServerSecurePort = "6443"
+
+var (
+	controlPlaneRestartTimeout      = 5 * time.Minute
+	controlPlaneRestartPollInterval = 5 * time.Second
+
+	// adminConf is the kubeconfig of the cluster admin on the control plane
+	// nodes
+	adminConf = "/etc/kubernetes/admin.conf"
+)
+
+// renewedComponents are the control plane static pods restarted once their
+// certificates are renewed
+var renewedComponents = []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+
+// kubeadmCertsRenew renews all the certificates managed by kubeadm on the
+// control plane node, saves the renewed admin.conf in the cluster definition
+// folder and restarts the control plane containers for them to load the
+// renewed certificates
+func kubeadmCertsRenew(t *Target, data interface{}) error {
+	if _, err := t.ssh("kubeadm", "alpha", "certs", "renew", "all", "-v", t.verboseLevel); err != nil {
+		return err
+	}
+	if err := t.downloadAdminConf(); err != nil {
+		return err
+	}
+	stopped := map[string]bool{}
+	for _, component := range renewedComponents {
+		ids, err := t.runningContainers(component)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			// kubelet starts the static pod container again
+			if _, err := t.ssh("crictl", "stop", id); err != nil {
+				return errors.Wrapf(err, "could not restart %s", component)
+			}
+			stopped[id] = true
+		}
+	}
+	return t.waitForControlPlaneRestart(stopped)
+}
+
+// downloadAdminConf saves the admin.conf of the control plane node in the
+// cluster definition folder. The nodes renewing their certificates in
+// parallel replace the file atomically.
+func (t *Target) downloadAdminConf() error {
+	if t.dryRun {
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would download %s to %s\n", t.target.Target, adminConf, skubaconstants.KubeConfigAdminFile())
+		return nil
+	}
+	contents, err := t.DownloadFileContents(adminConf)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(skubaconstants.KubeConfigAdminFile()), ".admin.conf-")
+	if err != nil {
+		return errors.Wrap(err, "could not save the renewed admin.conf")
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString(contents); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "could not save the renewed admin.conf")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "could not save the renewed admin.conf")
+	}
+	return errors.Wrap(os.Rename(tmpFile.Name(), skubaconstants.KubeConfigAdminFile()), "could not save the renewed admin.conf")
+}
+
+// runningContainers returns the IDs of the running containers of the control
+// plane component
+func (t *Target) runningContainers(component string) ([]string, error) {
+	result, err := t.silentSsh("crictl", "ps", "--quiet", "--state", "running", "--label", "io.kubernetes.container.name="+component)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list the %s containers", component)
+	}
+	return strings.Fields(result.Stdout), nil
+}
+
+// waitForControlPlaneRestart waits until kubelet started the stopped control
+// plane containers again, and the API server, which checks etcd, is healthy
+func (t *Target) waitForControlPlaneRestart(stopped map[string]bool) error {
+	if t.dryRun {
+		return nil
+	}
+	port, err := t.apiServerSecurePort()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for time.Since(start) < controlPlaneRestartTimeout {
+		restarted, err := t.controlPlaneRestarted(stopped, port)
+		if err != nil {
+			return err
+		}
+		if restarted {
+			return nil
+		}
+		klog.V(2).Infof("the control plane of %s is not healthy yet, waiting...", t)
+		time.Sleep(controlPlaneRestartPollInterval)
+	}
+	return errors.Errorf("the control plane did not restart within %s on %s", controlPlaneRestartTimeout, t)
+}
+
+func (t *Target) controlPlaneRestarted(stopped map[string]bool, apiServerPort string) (bool, error) {
+	for _, component := range renewedComponents {
+		ids, err := t.runningContainers(component)
+		if err != nil {
+			return false, err
+		}
+		restarted := false
+		for _, id := range ids {
+			if !stopped[id] {
+				restarted = true
+			}
+		}
+		if !restarted {
+			klog.V(2).Infof("%s is not running again on %s", component, t)
+			return false, nil
+		}
+	}
+	result, err := t.silentSsh("curl", "--silent", "--insecure", "--max-time", "5", fmt.Sprintf("https://127.0.0.1:%s/healthz", apiServerPort))
+	if err != nil {
+		if _, isCommandError := errors.Cause(err).(*deployments.CommandError); isCommandError {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.TrimSpace(result.Stdout) == "ok", nil
+}
+
+// apiServerSecurePort returns the port the API server of the control plane
+// node listens on, as set in its static pod manifest
+func (t *Target) apiServerSecurePort() (string, error) {
+	result, err := t.silentSsh("cat", filepath.Join(manifestsDir, "kube-apiserver.yaml"))
+	if err != nil {
+		return "", errors.Wrap(err, "could not read the kube-apiserver static pod manifest")
+	}
+	flags, err := staticPodFlags(result.Stdout, "kube-apiserver")
+	if err != nil {
+		return "", err
+	}
+	if port, ok := flags["secure-port"]; ok {
+		return port, nil
+	}
+	return defAPIServerSecurePort, nil
+}
+
 func kubeadmUpgradeApply(t *Target, data interface{}) error {
 	upgradeConfiguration, ok := data.(deployments.UpgradeConfiguration)
 	if !ok {
@@ -137,7 +317,7 @@ func kubeadmUpgradeApply(t *Target, data interface{}) error {
 		return err
 	}
 	defer func() {
-		_, _, err := t.ssh("rm", remoteKubeadmUpgradeConfFile)
+		_, err := t.ssh("rm", remoteKubeadmUpgradeConfFile)
 		if err != nil {
 			// If the deferred function has any return values, they are discarded when the function completes
 			// https://golang.org/ref/spec#Defer_statements
@@ -145,11 +325,11 @@ func kubeadmUpgradeApply(t *Target, data interface{}) error {
 		}
 	}()
 
-	_, _, err := t.ssh("kubeadm", "upgrade", "apply", "--config", remoteKubeadmUpgradeConfFile, "-y", "-v", t.verboseLevel)
+	_, err := t.ssh("kubeadm", "upgrade", "apply", "--config", remoteKubeadmUpgradeConfFile, "-y", "-v", t.verboseLevel)
 	return err
 }
 
 func kubeadmUpgradeNode(t *Target, data interface{}) error {
-	_, _, err := t.ssh("kubeadm", "upgrade", "node", "-v", t.verboseLevel)
+	_, err := t.ssh("kubeadm", "upgrade", "node", "-v", t.verboseLevel)
 	return err
 }
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+// fakeCrictl lists the running containers of a component from the file named
+// after it in the containers directory, and restarts a stopped container
+// under a new ID unless the component is listed in the stuck file
+const fakeCrictl = `#!/bin/sh
+containers=%q
+case "$1" in
+  ps)
+    for arg; do
+      case "$arg" in
+        io.kubernetes.container.name=*) cat "$containers/${arg#*=}" ;;
+      esac
+    done
+    ;;
+  stop)
+    for file in "$containers"/*; do
+      if grep -qx "$2" "$file" && ! grep -qx "$(basename "$file")" "$containers.stuck"; then
+        echo "restarted-$2" > "$file"
+      fi
+    done
+    ;;
+esac
+`
+
+func TestKubeadmCertsRenew(t *testing.T) {
+	defer func(manifests, conf string, timeout, interval time.Duration, path string) {
+		manifestsDir, adminConf = manifests, conf
+		controlPlaneRestartTimeout, controlPlaneRestartPollInterval = timeout, interval
+		os.Setenv("PATH", path) //nolint:errcheck
+	}(manifestsDir, adminConf, controlPlaneRestartTimeout, controlPlaneRestartPollInterval, os.Getenv("PATH"))
+	controlPlaneRestartTimeout = 500 * time.Millisecond
+	controlPlaneRestartPollInterval = 10 * time.Millisecond
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+	defer os.Chdir(wd) //nolint:errcheck
+
+	tests := []struct {
+		name          string
+		stuck         string
+		healthz       string
+		errorExpected bool
+	}{
+		{
+			name:    "control plane restarted",
+			healthz: "ok",
+		},
+		{
+			name:          "component not restarted",
+			stuck:         "kube-scheduler",
+			healthz:       "ok",
+			errorExpected: true,
+		},
+		{
+			name:          "API server not healthy",
+			healthz:       "[-]etcd failed",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-certs-renew")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			writeFile := func(path, contents string) {
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatalf("could not create %s: %v", filepath.Dir(path), err)
+				}
+				if err := ioutil.WriteFile(path, []byte(contents), 0700); err != nil {
+					t.Fatalf("could not write %s: %v", path, err)
+				}
+			}
+
+			// the renewed admin.conf is written by kubeadm
+			adminConf = filepath.Join(dir, "node", "admin.conf")
+			bin := filepath.Join(dir, "bin")
+			writeFile(adminConf, "expired")
+			writeFile(filepath.Join(bin, "kubeadm"), fmt.Sprintf("#!/bin/sh\necho renewed > %q\n", adminConf))
+			containers := filepath.Join(dir, "containers")
+			writeFile(filepath.Join(bin, "crictl"), fmt.Sprintf(fakeCrictl, containers))
+			writeFile(filepath.Join(bin, "curl"), fmt.Sprintf("#!/bin/sh\necho %q\n", tt.healthz))
+			for _, component := range renewedComponents {
+				writeFile(filepath.Join(containers, component), component+"-0\n")
+			}
+			writeFile(containers+".stuck", tt.stuck+"\n")
+			manifestsDir = filepath.Join(dir, "manifests")
+			writeFile(filepath.Join(manifestsDir, "kube-apiserver.yaml"), "spec:\n  containers:\n  - name: kube-apiserver\n    command:\n    - kube-apiserver\n    - --secure-port=6443\n")
+			os.Setenv("PATH", bin+":/usr/bin:/bin") //nolint:errcheck
+
+			clusterDir := filepath.Join(dir, "cluster")
+			writeFile(filepath.Join(clusterDir, skubaconstants.KubeConfigAdminFile()), "expired")
+			if err := os.Chdir(clusterDir); err != nil {
+				t.Fatalf("could not change directory: %v", err)
+			}
+
+			target := &deployments.Target{Target: "127.0.0.1", Nodename: "master-0"}
+			target.Actionable = &Target{target: target, local: true}
+			err = kubeadmCertsRenew(target.Actionable.(*Target), nil)
+			if tt.errorExpected && err == nil {
+				t.Error("expected an error but got none")
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+
+			contents, err := ioutil.ReadFile(skubaconstants.KubeConfigAdminFile())
+			if err != nil {
+				t.Fatalf("could not read admin.conf: %v", err)
+			}
+			if string(contents) != "renewed\n" {
+				t.Errorf("expected the renewed admin.conf to be downloaded, got %q", contents)
+			}
+		})
+	}
+}
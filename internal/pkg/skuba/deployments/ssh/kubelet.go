@@ -19,6 +19,7 @@ package ssh
 
 import (
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
@@ -34,6 +35,7 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh/assets"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/util"
 	"github.com/SUSE/skuba/pkg/skuba"
 )
 
@@ -42,24 +44,33 @@ func init() {
 	stateMap["kubelet.servercert.create-and-upload"] = kubeletCreateAndUploadServerCert
 	stateMap["kubelet.configure"] = kubeletConfigure
 	stateMap["kubelet.enable"] = kubeletEnable
+	inEffectMap["kubelet.enable"] = kubeletEnabled
+	stateMap["kubelet.restart"] = kubeletRestart
 }
 
 func kubeletUploadRootCert(t *Target, data interface{}) error {
 	// Upload root ca cert
 	caCertPath := filepath.Join(skuba.PkiDir(), kubernetes.KubeletCACertName)
 	f, err := os.Stat(caCertPath)
-	if err != nil {
+	if os.IsNotExist(err) && t.dryRun {
+		// the root ca is not generated in dry-run mode
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would upload the kubelet root certificate to %s\n", t.target.Target, kubernetes.KubeletCertAndKeyDir)
+		return nil
+	} else if err != nil {
 		return err
 	}
 	if err := t.target.UploadFile(caCertPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletCACertName), f.Mode()); err != nil {
 		return err
 	}
 
-	// Upload root ca key on control plane node only
+	// Upload root ca key on control plane node only, when it is available:
+	// an external CA can be imported without its key
 	if *t.target.Role == deployments.MasterRole {
 		caKeyPath := filepath.Join(skuba.PkiDir(), kubernetes.KubeletCAKeyName)
 		f, err = os.Stat(caKeyPath)
-		if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
 			return err
 		}
 		if err := t.target.UploadFile(caKeyPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletCAKeyName), f.Mode()); err != nil {
@@ -71,12 +82,119 @@ func kubeletUploadRootCert(t *Target, data interface{}) error {
 }
 
 func kubeletCreateAndUploadServerCert(t *Target, data interface{}) error {
+	host := t.target.Nodename
+	altNames, err := kubeletServerAltNames(t)
+	if err != nil {
+		return err
+	}
+
+	// Without kubelet root ca key, the server certificate is signed by the
+	// external CA the root ca certificate was imported from
+	if kubernetes.KubeletCAKeyMissing() {
+		return kubeletUploadSignedServerCert(t, altNames)
+	}
+
+	if t.dryRun {
+		// the generated certificate would differ from the current one anyway
+		fmt.Fprintf(dryRunOutput, "[dry-run] %s: would create and upload the kubelet server certificate and key to %s\n", t.target.Target, kubernetes.KubeletCertAndKeyDir)
+		return nil
+	}
+
 	// Read kubelet root ca certificate and key
 	caCert, caKey, err := pkiutil.TryLoadCertAndKeyFromDisk(skuba.PkiDir(), kubernetes.KubeletCACertAndKeyBaseName)
 	if err != nil {
 		return errors.Wrap(err, "failure loading kubelet CA certificate authority")
 	}
 
+	cfg := kubeletServerCertConfig(host, altNames)
+	cert, key, err := pkiutil.NewCertAndKey(caCert, caKey, cfg)
+	if err != nil {
+		return errors.Wrap(err, "couldn't generate kubelet server certificate")
+	}
+
+	// Save kubelet server certificate and key to local temporarily
+	if err := pkiutil.WriteCertAndKey(skuba.PkiDir(), host, cert, key); err != nil {
+		return errors.Wrapf(err, "failure while saving kubelet server %s certificate and key", host)
+	}
+
+	// Upload server certificate and key
+	certPath, keyPath := pkiutil.PathsForCertAndKey(skuba.PkiDir(), host)
+	if err := kubeletUploadServerCertAndKey(t, certPath, keyPath); err != nil {
+		return err
+	}
+
+	// Remove local temporarily kubelet server certificate and key
+	if err := os.Remove(certPath); err != nil {
+		return err
+	}
+	if err := os.Remove(keyPath); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// kubeletUploadSignedServerCert uploads the kubelet server certificate of the
+// target signed by the external CA. When it is missing, its CSR and key are
+// generated in the pki folder to be signed by the external CA.
+func kubeletUploadSignedServerCert(t *Target, altNames certutil.AltNames) error {
+	host := t.target.Nodename
+	baseName := kubernetes.ExternalKubeletServerCertAndKeyBaseName(host)
+	certPath, keyPath := pkiutil.PathsForCertAndKey(skuba.PkiDir(), baseName)
+	csrPath := filepath.Join(skuba.PkiDir(), baseName+".csr")
+
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		if t.dryRun {
+			fmt.Fprintf(dryRunOutput, "[dry-run] %s: would generate %s to be signed by the external CA\n", t.target.Target, csrPath)
+			return nil
+		}
+		// keep the key of a CSR which may already be being signed
+		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+			csr, key, err := pkiutil.NewCSRAndKey(kubeletServerCertConfig(host, altNames))
+			if err != nil {
+				return errors.Wrap(err, "couldn't generate kubelet server CSR")
+			}
+			if err := pkiutil.WriteCSR(skuba.PkiDir(), baseName, csr); err != nil {
+				return errors.Wrapf(err, "failure while saving kubelet server %s CSR", host)
+			}
+			if err := pkiutil.WriteKey(skuba.PkiDir(), baseName, key); err != nil {
+				return errors.Wrapf(err, "failure while saving kubelet server %s key", host)
+			}
+		}
+		return errors.Errorf("the kubelet CA key is not available, sign %s with the external CA and save the certificate to %s, then run the command again", csrPath, certPath)
+	}
+
+	caCert, err := pkiutil.TryLoadCertFromDisk(skuba.PkiDir(), kubernetes.KubeletCACertAndKeyBaseName)
+	if err != nil {
+		return errors.Wrap(err, "failure loading kubelet CA certificate authority")
+	}
+	cert, key, err := pkiutil.TryLoadCertAndKeyFromDisk(skuba.PkiDir(), baseName)
+	if err != nil {
+		return errors.Wrapf(err, "failure loading kubelet server %s certificate and key", host)
+	}
+	if err := cert.CheckSignatureFrom(caCert); err != nil {
+		return errors.Wrapf(err, "%s is not signed by the kubelet CA", certPath)
+	}
+	if err := util.CheckPublicKeyMatch(cert, key); err != nil {
+		return errors.Wrapf(err, "%s was not signed from %s", certPath, csrPath)
+	}
+
+	if err := kubeletUploadServerCertAndKey(t, certPath, keyPath); err != nil {
+		return err
+	}
+	if t.dryRun {
+		return nil
+	}
+	// the CSR has been signed and used, a new one is generated on renewal
+	if err := os.Remove(csrPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove %s", csrPath)
+	}
+	return nil
+}
+
+// kubeletServerAltNames returns the names and addresses of the target its
+// kubelet server certificate is valid for
+func kubeletServerAltNames(t *Target) (certutil.AltNames, error) {
 	host := t.target.Nodename
 	altNames := certutil.AltNames{}
 	if ip := net.ParseIP(host); ip != nil {
@@ -86,11 +204,11 @@ func kubeletCreateAndUploadServerCert(t *Target, data interface{}) error {
 	}
 
 	// Create AltNames with defaults DNSNames/IPs
-	stdout, _, err := t.silentSsh("hostname", "-I")
+	result, err := t.silentSsh("hostname", "-I")
 	if err != nil {
-		return err
+		return altNames, err
 	}
-	for _, addr := range strings.Split(stdout, " ") {
+	for _, addr := range strings.Split(result.Stdout, " ") {
 		if ip := net.ParseIP(addr); ip != nil {
 			altNames.IPs = append(altNames.IPs, ip)
 		}
@@ -106,26 +224,20 @@ func kubeletCreateAndUploadServerCert(t *Target, data interface{}) error {
 
 	altNames.IPs = append(altNames.IPs, alternateIPs...)
 	altNames.DNSNames = append(altNames.DNSNames, alternateDNS...)
+	return altNames, nil
+}
 
-	cfg := &pkiutil.CertConfig{
+func kubeletServerCertConfig(host string, altNames certutil.AltNames) *pkiutil.CertConfig {
+	return &pkiutil.CertConfig{
 		Config: certutil.Config{
 			CommonName: host,
 			AltNames:   altNames,
 			Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		},
 	}
-	cert, key, err := pkiutil.NewCertAndKey(caCert, caKey, cfg)
-	if err != nil {
-		return errors.Wrap(err, "couldn't generate kubelet server certificate")
-	}
-
-	// Save kubelet server certificate and key to local temporarily
-	if err := pkiutil.WriteCertAndKey(skuba.PkiDir(), host, cert, key); err != nil {
-		return errors.Wrapf(err, "failure while saving kubelet server %s certificate and key", host)
-	}
+}
 
-	// Upload server certificate and key
-	certPath, keyPath := pkiutil.PathsForCertAndKey(skuba.PkiDir(), host)
+func kubeletUploadServerCertAndKey(t *Target, certPath, keyPath string) error {
 	f, err := os.Stat(certPath)
 	if err != nil {
 		return err
@@ -137,40 +249,19 @@ func kubeletCreateAndUploadServerCert(t *Target, data interface{}) error {
 	if err != nil {
 		return err
 	}
-	if err := t.target.UploadFile(keyPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletServerKeyName), f.Mode()); err != nil {
-		return err
-	}
-
-	// Remove local temporarily kubelet server certificate and key
-	if err := os.Remove(certPath); err != nil {
-		return err
-	}
-	if err := os.Remove(keyPath); err != nil {
-		return err
-	}
-
-	return nil
+	return t.target.UploadFile(keyPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletServerKeyName), f.Mode())
 }
 
 func kubeletConfigure(t *Target, data interface{}) error {
-	isSUSE, err := t.target.IsSUSEOS()
+	profile, err := t.osProfile()
 	if err != nil {
 		return err
 	}
-	if isSUSE {
-		if err := t.UploadFileContents("/usr/lib/systemd/system/kubelet.service", assets.KubeletService, 0644); err != nil {
-			return err
-		}
-		if err := t.UploadFileContents("/usr/lib/systemd/system/kubelet.service.d/10-kubeadm.conf", assets.KubeadmService, 0644); err != nil {
-			return err
-		}
-	} else {
-		if err := t.UploadFileContents("/lib/systemd/system/kubelet.service", assets.KubeletService, 0644); err != nil {
-			return err
-		}
-		if err := t.UploadFileContents("/etc/systemd/system/kubelet.service.d/10-kubeadm.conf", assets.KubeadmService, 0644); err != nil {
-			return err
-		}
+	if err := t.UploadFileContents(profile.KubeletUnitFile, assets.KubeletService, 0644); err != nil {
+		return err
+	}
+	if err := t.UploadFileContents(profile.KubeletDropInFile, assets.KubeadmService, 0644); err != nil {
+		return err
 	}
 
 	cloudProvider, err := getCloudProvider()
@@ -184,12 +275,21 @@ func kubeletConfigure(t *Target, data interface{}) error {
 		}
 	}
 
-	_, _, err = t.ssh("systemctl", "daemon-reload")
+	_, err = t.ssh("systemctl", "daemon-reload")
 	return err
 }
 
 func kubeletEnable(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "enable", "kubelet")
+	_, err := t.ssh("systemctl", "enable", "kubelet")
+	return err
+}
+
+func kubeletEnabled(t *Target, data interface{}) (bool, error) {
+	return t.IsServiceEnabled("kubelet")
+}
+
+func kubeletRestart(t *Target, data interface{}) error {
+	_, err := t.ssh("systemctl", "restart", "kubelet")
 	return err
 }
 
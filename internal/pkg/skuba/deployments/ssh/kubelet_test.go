@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+func TestKubeletCreateAndUploadServerCertWithoutCAKey(t *testing.T) {
+	kubeletCACert, kubeletCAKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "kubelet-ca"}})
+	if err != nil {
+		t.Fatalf("could not create the kubelet CA: %v", err)
+	}
+	otherCACert, otherCAKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "other-ca"}})
+	if err != nil {
+		t.Fatalf("could not create another CA: %v", err)
+	}
+	serverCertConfig := kubeletServerCertConfig("node-0", certutil.AltNames{DNSNames: []string{"node-0"}})
+
+	tests := []struct {
+		name          string
+		signingCACert *x509.Certificate
+		signingCAKey  crypto.Signer
+		mismatchedKey bool
+		// the CSR is only generated outside of dry-run mode, nothing is
+		// uploaded to the node when it is
+		noDryRun         bool
+		expectedError    string
+		expectedUploaded bool
+		expectedCSR      bool
+	}{
+		{
+			name:          "CSR generated when the certificate is missing",
+			noDryRun:      true,
+			expectedError: "sign pki/kubelet-node-0.csr with the external CA",
+			expectedCSR:   true,
+		},
+		{
+			name: "CSR not generated in dry-run mode",
+		},
+		{
+			name:          "certificate signed by another CA",
+			signingCACert: otherCACert,
+			signingCAKey:  otherCAKey,
+			expectedError: "is not signed by the kubelet CA",
+		},
+		{
+			name:          "certificate not signed from the CSR key",
+			signingCACert: kubeletCACert,
+			signingCAKey:  kubeletCAKey,
+			mismatchedKey: true,
+			expectedError: "was not signed from pki/kubelet-node-0.csr",
+		},
+		{
+			name:             "certificate signed by the kubelet CA",
+			signingCACert:    kubeletCACert,
+			signingCAKey:     kubeletCAKey,
+			expectedUploaded: true,
+		},
+	}
+
+	output := &bytes.Buffer{}
+	defer func() {
+		dryRunOutput = os.Stdout
+	}()
+	dryRunOutput = output
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-kubelet-cert")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			wd, _ := os.Getwd()
+			if err := os.Chdir(dir); err != nil {
+				t.Fatalf("could not change to %s: %v", dir, err)
+			}
+			defer os.Chdir(wd) //nolint:errcheck
+
+			// the kubelet CA was imported without its key
+			if err := pkiutil.WriteCert(skuba.PkiDir(), kubernetes.KubeletCACertAndKeyBaseName, kubeletCACert); err != nil {
+				t.Fatalf("could not write the kubelet CA: %v", err)
+			}
+			if tt.signingCACert != nil {
+				cert, key, err := pkiutil.NewCertAndKey(tt.signingCACert, tt.signingCAKey, serverCertConfig)
+				if err != nil {
+					t.Fatalf("could not sign the server certificate: %v", err)
+				}
+				if err := pkiutil.WriteCertAndKey(skuba.PkiDir(), "kubelet-node-0", cert, key); err != nil {
+					t.Fatalf("could not write the server certificate: %v", err)
+				}
+				if tt.mismatchedKey {
+					if err := pkiutil.WriteKey(skuba.PkiDir(), "kubelet-node-0", otherCAKey); err != nil {
+						t.Fatalf("could not write the server key: %v", err)
+					}
+				}
+			}
+
+			output.Reset()
+			target := &deployments.Target{Target: "10.0.0.20", Nodename: "node-0"}
+			target.Actionable = &Target{target: target, local: true, dryRun: !tt.noDryRun}
+			err = kubeletCreateAndUploadServerCert(target.Actionable.(*Target), nil)
+			if tt.expectedError == "" && err != nil {
+				t.Errorf("error not expected, but an error was reported (%v)", err)
+			} else if tt.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tt.expectedError)) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedError, err)
+			}
+
+			uploaded := strings.Contains(output.String(), filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletServerCertName))
+			if uploaded != tt.expectedUploaded {
+				t.Errorf("expected the server certificate to be uploaded: %v, got output %q", tt.expectedUploaded, output.String())
+			}
+			if tt.signingCACert == nil {
+				for _, file := range []string{"kubelet-node-0.csr", "kubelet-node-0.key"} {
+					_, err := os.Stat(filepath.Join(skuba.PkiDir(), file))
+					if tt.expectedCSR && err != nil {
+						t.Errorf("expected %s to be generated: %v", file, err)
+					} else if !tt.expectedCSR && !os.IsNotExist(err) {
+						t.Errorf("expected %s not to be generated, got %v", file, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKubeletCertsDryRunWithoutRootCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-kubelet-cert")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("could not change to %s: %v", dir, err)
+	}
+	defer os.Chdir(wd) //nolint:errcheck
+
+	output := &bytes.Buffer{}
+	defer func() {
+		dryRunOutput = os.Stdout
+	}()
+	dryRunOutput = output
+
+	// the kubelet root certificate is not generated in dry-run mode
+	role := deployments.MasterRole
+	target := &deployments.Target{Target: "10.0.0.20", Nodename: "node-0", Role: &role}
+	target.Actionable = &Target{target: target, local: true, dryRun: true}
+	if err := kubeletUploadRootCert(target.Actionable.(*Target), nil); err != nil {
+		t.Errorf("error not expected uploading the root certificate, but an error was reported (%v)", err)
+	}
+	if err := kubeletCreateAndUploadServerCert(target.Actionable.(*Target), nil); err != nil {
+		t.Errorf("error not expected creating the server certificate, but an error was reported (%v)", err)
+	}
+	for _, expected := range []string{"would upload the kubelet root certificate", "would create and upload the kubelet server certificate"} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected output containing %q, got %q", expected, output.String())
+		}
+	}
+	if _, err := os.Stat(skuba.PkiDir()); !os.IsNotExist(err) {
+		t.Errorf("expected no pki folder to be created, got %v", err)
+	}
+}
@@ -108,7 +108,7 @@ func kubernetesFreshInstallAllPkgs(t *Target, data interface{}) error {
 	pkgs = append(pkgs, fmt.Sprintf("+cri-o-%s*", current))
 	pkgs = append(pkgs, fmt.Sprintf("+cri-tools-%s*", current))
 
-	_, _, err = t.ZypperInstall(pkgs...)
+	_, err = t.InstallPackages(pkgs...)
 	return err
 }
 
@@ -150,7 +150,7 @@ func kubernetesUpgradeStageOne(t *Target, data interface{}) error {
 	}
 
 	pkgs = append(pkgs, fmt.Sprintf("+kubernetes-%s-kubeadm", nextV))
-	_, _, err = t.ZypperInstall(pkgs...)
+	_, err = t.InstallPackages(pkgs...)
 	return err
 }
 
@@ -185,16 +185,16 @@ func kubernetesUpgradeStageTwo(t *Target, data interface{}) error {
 	pkgs = append(pkgs, fmt.Sprintf("+kubernetes-%s-kubelet", nextV))
 	pkgs = append(pkgs, fmt.Sprintf("+cri-o-%s*", nextV))
 	pkgs = append(pkgs, fmt.Sprintf("+cri-tools-%s*", nextV))
-	_, _, err = t.ZypperInstall(pkgs...)
+	_, err = t.InstallPackages(pkgs...)
 	return err
 }
 
 func kubernetesRestartServices(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "restart", "crio", "kubelet")
+	_, err := t.ssh("systemctl", "restart", "crio", "kubelet")
 	return err
 }
 
 func kubernetesEnsureServicesEnabled(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "enable", "crio", "kubelet")
+	_, err := t.ssh("systemctl", "enable", "crio", "kubelet")
 	return err
 }
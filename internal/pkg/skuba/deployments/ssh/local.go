@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// runLocalCommand runs the command of the result on the machine skuba is
+// running on, filling the result and returning whether the command was started
+func (t *Target) runLocalCommand(result *deployments.CommandResult, silent bool, stdin string) (started bool, err error) {
+	cmd := exec.Command("sh", "-c", result.Command)
+	// run the command in its own process group, so that it can be killed
+	// along with its children on timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if len(stdin) > 0 {
+		cmd.Stdin = bytes.NewBufferString(stdin)
+	}
+	stdoutReader, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, err
+	}
+	stderrReader, err := cmd.StderrPipe()
+	if err != nil {
+		return false, err
+	}
+	if !silent {
+		klog.V(2).Infof("running local command: %q", result.Command)
+	}
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+	stdoutChan := make(chan string, 1)
+	stderrChan := make(chan string, 1)
+	go readerStreamer(stdoutReader, stdoutChan, "stdout", silent)
+	go readerStreamer(stderrReader, stderrChan, "stderr", silent)
+
+	// the output streams have to be fully read before waiting for the command
+	var waitErr error
+	done := make(chan struct{})
+	go func() {
+		result.Stdout = <-stdoutChan
+		result.Stderr = <-stderrChan
+		waitErr = cmd.Wait()
+		close(done)
+	}()
+	var timeoutChan <-chan time.Time
+	timeout := t.timeout()
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	timedOut := false
+	select {
+	case <-done:
+	case <-timeoutChan:
+		timedOut = true
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			klog.Warningf("could not kill command %q: %s", result.Command, err)
+		}
+		<-done
+	}
+	result.Duration = time.Since(result.Start)
+	if exitErr, ok := waitErr.(*exec.ExitError); ok {
+		result.ExitCode = exitErr.ExitCode()
+	} else if waitErr != nil {
+		return true, waitErr
+	} else {
+		result.ExitCode = 0
+	}
+	if timedOut {
+		return true, &deployments.CommandError{CommandResult: result, TimedOut: true, Timeout: timeout}
+	}
+	if result.ExitCode != 0 {
+		return true, &deployments.CommandError{CommandResult: result}
+	}
+	return true, nil
+}
+
+// uploadFileLocal atomically writes the contents into a local file, keeping
+// the ownership of the file it replaces
+func uploadFileLocal(targetPath string, contents io.Reader, perm os.FileMode) error {
+	dir := filepath.Dir(targetPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "could not create directory %s", dir)
+	}
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(targetPath)+".skuba-tmp")
+	if err != nil {
+		return errors.Wrapf(err, "could not create temporary file for %s", targetPath)
+	}
+	tmpPath := f.Name()
+	defer func() {
+		if _, err := os.Stat(tmpPath); err == nil {
+			if err := os.Remove(tmpPath); err != nil {
+				klog.Warningf("could not delete the temporary file %s: %s", tmpPath, err)
+			}
+		}
+	}()
+	if _, err := io.Copy(f, contents); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "could not write temporary file %s", tmpPath)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "could not write temporary file %s", tmpPath)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return errors.Wrapf(err, "could not set permissions of %s", tmpPath)
+	}
+	if info, err := os.Stat(targetPath); err == nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if err := os.Chown(tmpPath, int(stat.Uid), int(stat.Gid)); err != nil {
+				klog.V(1).Infof("could not keep the ownership of %s: %s", targetPath, err)
+			}
+		}
+	}
+	return errors.Wrapf(os.Rename(tmpPath, targetPath), "could not replace %s", targetPath)
+}
+
+// downloadFileLocal reads the contents of a local file
+func downloadFileLocal(sourcePath string, contents io.Writer) error {
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not open file %s", sourcePath)
+	}
+	defer f.Close()
+	_, err = io.Copy(contents, f)
+	return errors.Wrapf(err, "could not read file %s", sourcePath)
+}
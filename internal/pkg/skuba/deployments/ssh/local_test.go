@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestLocalCommand(t *testing.T) {
+	tests := []struct {
+		name             string
+		command          string
+		stdin            string
+		commandTimeout   time.Duration
+		expectedStdout   string
+		expectedStderr   string
+		expectedExitCode int
+		expectedTimeout  bool
+	}{
+		{
+			name:           "successful command",
+			command:        "echo hello; echo world",
+			expectedStdout: "hello\nworld",
+		},
+		{
+			name:           "command reading stdin",
+			command:        "cat",
+			stdin:          "contents",
+			expectedStdout: "contents",
+		},
+		{
+			name:             "failed command",
+			command:          "echo failure >&2; exit 3",
+			expectedStderr:   "failure",
+			expectedExitCode: 3,
+		},
+		{
+			name:             "timed out command",
+			command:          "echo started; sleep 10",
+			commandTimeout:   100 * time.Millisecond,
+			expectedStdout:   "started",
+			expectedExitCode: -1,
+			expectedTimeout:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			target := Target{local: true, commandTimeout: tt.commandTimeout}
+			result, err := target.silentSshWithStdin(tt.stdin, tt.command)
+			if result.Stdout != tt.expectedStdout {
+				t.Errorf("expected stdout %q, got %q", tt.expectedStdout, result.Stdout)
+			}
+			if result.Stderr != tt.expectedStderr {
+				t.Errorf("expected stderr %q, got %q", tt.expectedStderr, result.Stderr)
+			}
+			if result.ExitCode != tt.expectedExitCode {
+				t.Errorf("expected exit code %d, got %d", tt.expectedExitCode, result.ExitCode)
+			}
+			if tt.expectedExitCode == 0 && !tt.expectedTimeout {
+				if err != nil {
+					t.Errorf("expected no error but got %v", err)
+				}
+				return
+			}
+			cmdErr, ok := errors.Cause(err).(*deployments.CommandError)
+			if !ok {
+				t.Fatalf("expected a command error, got %v", err)
+			}
+			if cmdErr.TimedOut != tt.expectedTimeout {
+				t.Errorf("expected timed out to be %t, got %t", tt.expectedTimeout, cmdErr.TimedOut)
+			}
+		})
+	}
+}
+
+func TestLocalFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-local-files")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := Target{local: true}
+	path := filepath.Join(dir, "etc", "kubernetes", "config")
+	for _, contents := range []string{"first version", "second version"} {
+		if err := target.UploadFileContents(path, contents, 0600); err != nil {
+			t.Fatalf("could not upload file: %v", err)
+		}
+		got, err := target.DownloadFileContents(path)
+		if err != nil {
+			t.Fatalf("could not download file: %v", err)
+		}
+		if got != contents {
+			t.Errorf("expected contents %q, got %q", contents, got)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("could not stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected permissions 0600, got %o", info.Mode().Perm())
+	}
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("could not read directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected temporary files to be cleaned up, got %d files", len(files))
+	}
+}
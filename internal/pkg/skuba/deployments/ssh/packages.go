@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// packageInstallers install packages with each of the package managers
+var packageInstallers = map[deployments.PackageManager]func(t *Target, packages ...string) (*deployments.CommandResult, error){
+	deployments.ZypperPackageManager:              (*Target).ZypperInstall,
+	deployments.TransactionalUpdatePackageManager: (*Target).TransactionalUpdateInstall,
+}
+
+// InstallPackages installs an arbitrary list of packages with the package
+// manager of the operating system of the target. As with zypper, packages
+// prefixed with a - are removed, and with a + are installed. Packages are
+// installed with transactional-update on read-only root filesystems, and the
+// target is rebooted into the new snapshot before returning.
+func (t *Target) InstallPackages(packages ...string) (*deployments.CommandResult, error) {
+	profile, err := t.osProfile()
+	if err != nil {
+		return nil, err
+	}
+	packageManager := profile.PackageManager
+	install, found := packageInstallers[packageManager]
+	if !found {
+		return nil, errors.Errorf("installing packages is not supported on %s nodes", profile.Name)
+	}
+	result, err := install(t, packages...)
+	if err != nil || packageManager != deployments.TransactionalUpdatePackageManager {
+		return result, err
+	}
+	// the packages are only available once the target runs the new snapshot
+	return result, t.reboot()
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestInstallPackages(t *testing.T) {
+	output := &bytes.Buffer{}
+	defer func() {
+		dryRunOutput = os.Stdout
+	}()
+	dryRunOutput = output
+
+	tests := []struct {
+		name           string
+		osRelease      map[string]string
+		expectedOutput []string
+		errorExpected  bool
+	}{
+		{
+			name:      "transactional-update and reboot on SLE Micro",
+			osRelease: map[string]string{"ID": "sle-micro", "ID_LIKE": "suse"},
+			expectedOutput: []string{
+				`would run "transactional-update --non-interactive --continue pkg install --auto-agree-with-licenses -- +kubernetes-1.18-kubeadm -kubernetes-1.17-kubeadm"`,
+				`would run "systemd-run --on-active=5 systemctl reboot"`,
+			},
+		},
+		{
+			name:          "unsupported distribution",
+			osRelease:     map[string]string{"ID": "ubuntu", "ID_LIKE": "debian"},
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			output.Reset()
+			target := &Target{local: true, dryRun: true, target: &deployments.Target{
+				Target: "node-0",
+				Cache:  deployments.TargetCache{OsRelease: tt.osRelease},
+			}}
+			_, err := target.InstallPackages("+kubernetes-1.18-kubeadm", "-kubernetes-1.17-kubeadm")
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			for _, expected := range tt.expectedOutput {
+				if !strings.Contains(output.String(), expected) {
+					t.Errorf("expected output to contain %q, got %q", expected, output.String())
+				}
+			}
+		})
+	}
+}
+
+func TestOSProfileOfTransactionalSystems(t *testing.T) {
+	tests := []struct {
+		name                   string
+		osRelease              map[string]string
+		transactional          bool
+		expectedPackageManager deployments.PackageManager
+		expectedKubeletUnit    string
+	}{
+		{
+			name:                   "SLE Micro",
+			osRelease:              map[string]string{"ID": "sle-micro", "ID_LIKE": "suse"},
+			transactional:          true,
+			expectedPackageManager: deployments.TransactionalUpdatePackageManager,
+			expectedKubeletUnit:    "/etc/systemd/system/kubelet.service",
+		},
+		{
+			name:                   "SLES with a read-only root filesystem",
+			osRelease:              map[string]string{"ID": "sles", "ID_LIKE": "suse"},
+			transactional:          true,
+			expectedPackageManager: deployments.TransactionalUpdatePackageManager,
+			expectedKubeletUnit:    "/etc/systemd/system/kubelet.service",
+		},
+		{
+			name:                   "SLES",
+			osRelease:              map[string]string{"ID": "sles", "ID_LIKE": "suse"},
+			expectedPackageManager: deployments.ZypperPackageManager,
+			expectedKubeletUnit:    "/usr/lib/systemd/system/kubelet.service",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			transactional := tt.transactional
+			target := &Target{local: true, transactional: &transactional, target: &deployments.Target{
+				Target: "node-0",
+				Cache:  deployments.TargetCache{OsRelease: tt.osRelease},
+			}}
+			profile, err := target.osProfile()
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			if profile.PackageManager != tt.expectedPackageManager {
+				t.Errorf("expected package manager %q, got %q", tt.expectedPackageManager, profile.PackageManager)
+			}
+			if profile.KubeletUnitFile != tt.expectedKubeletUnit {
+				t.Errorf("expected kubelet unit %q, got %q", tt.expectedKubeletUnit, profile.KubeletUnitFile)
+			}
+			if !strings.HasPrefix(profile.KubeletDropInFile, filepath.Dir(tt.expectedKubeletUnit)) {
+				t.Errorf("expected the kubelet drop-in next to the unit, got %q", profile.KubeletDropInFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+const (
+	// minimumFreeDiskSpace is the space required in /var/lib for the
+	// container images, the kubelet and etcd data
+	minimumFreeDiskSpace = 10 * 1024 * 1024 * 1024
+	// zypperCapNotFound is the exit code of zypper when no package matches
+	zypperCapNotFound = 104
+)
+
+var (
+	checkMap = map[string]Runner{}
+
+	// sysctlDirs are the directories of the sysctl configuration files
+	// applied by systemd-sysctl, in the lexical order of the file names
+	sysctlDirs = []string{"/etc/sysctl.d", "/run/sysctl.d", "/usr/lib/sysctl.d"}
+)
+
+func init() {
+	checkMap["os"] = checkOS
+	checkMap["kernel-modules"] = kernelCheckModules
+	checkMap["swap"] = checkSwap
+	checkMap["sysctl"] = checkSysctl
+	checkMap["ports"] = checkPorts
+	checkMap["time-sync"] = checkTimeSync
+	checkMap["dns"] = checkDNS
+	checkMap["control-plane-endpoint"] = checkControlPlaneEndpoint
+	checkMap["repositories"] = checkRepositories
+	checkMap["disk-space"] = checkDiskSpace
+}
+
+// Check runs a preflight check on the target. Checks only inspect the target,
+// they are neither recorded in the checkpoint nor affected by dry-run.
+func (t *Target) Check(data interface{}, check string) error {
+	runner, found := checkMap[check]
+	if !found {
+		return errors.Errorf("check does not exist: %s", check)
+	}
+	return t.applyState(runner, data)
+}
+
+func preflightConfiguration(data interface{}) (deployments.PreflightConfiguration, error) {
+	preflightConfiguration, ok := data.(deployments.PreflightConfiguration)
+	if !ok {
+		return preflightConfiguration, errors.New("couldn't access preflight configuration")
+	}
+	return preflightConfiguration, nil
+}
+
+func checkOS(t *Target, data interface{}) error {
+	profile, err := t.target.OSProfile()
+	if err != nil {
+		return err
+	}
+	if profile.PackageManager == "" {
+		return errors.Errorf("%s is not supported, packages cannot be installed on it", t.target.Cache.OsRelease["PRETTY_NAME"])
+	}
+	return t.target.CheckOSVersion()
+}
+
+func checkSwap(t *Target, data interface{}) error {
+	result, err := t.silentSsh("cat", "/proc/swaps")
+	if err != nil {
+		return err
+	}
+	if devices := swapDevices(result.Stdout); len(devices) > 0 {
+		return errors.Errorf("swap is enabled on %s, kubelet requires it to be disabled", strings.Join(devices, ", "))
+	}
+	return nil
+}
+
+// swapDevices returns the devices listed in /proc/swaps
+func swapDevices(swaps string) []string {
+	devices := []string{}
+	lines := strings.Split(strings.TrimSpace(swaps), "\n")
+	for _, line := range lines[1:] {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			devices = append(devices, fields[0])
+		}
+	}
+	return devices
+}
+
+// checkSysctl looks for sysctl configuration files overriding the kernel
+// parameters configured by skuba when the node boots
+func checkSysctl(t *Target, data interface{}) error {
+	kernelConfiguration, err := kernelConfiguration()
+	if err != nil {
+		return err
+	}
+	files, err := t.sysctlFiles()
+	if err != nil {
+		return err
+	}
+	errs := []string{}
+	for _, file := range files {
+		result, err := t.silentSsh("cat", file)
+		if err != nil {
+			if _, ok := errors.Cause(err).(*deployments.CommandError); ok {
+				continue
+			}
+			return err
+		}
+		errs = append(errs, sysctlOverrides(file, result.Stdout, kernelConfiguration.AllParameters())...)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// sysctlFiles returns the sysctl configuration files applied after the ones
+// of skuba, in the order they are applied. A file in /etc masks the files
+// with the same name in the other directories.
+func (t *Target) sysctlFiles() ([]string, error) {
+	filesByName := map[string]string{}
+	for _, dir := range sysctlDirs {
+		result, err := t.silentSsh("ls", "-1", dir)
+		if err != nil {
+			if _, ok := errors.Cause(err).(*deployments.CommandError); ok {
+				continue
+			}
+			return nil, err
+		}
+		for _, name := range strings.Fields(result.Stdout) {
+			if _, masked := filesByName[name]; masked || !strings.HasSuffix(name, ".conf") || name < "90-skuba-" || strings.HasPrefix(name, "90-skuba-") {
+				continue
+			}
+			filesByName[name] = path.Join(dir, name)
+		}
+	}
+	names := []string{}
+	for name := range filesByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := []string{}
+	for _, name := range names {
+		files = append(files, filesByName[name])
+	}
+	return files, nil
+}
+
+// sysctlOverrides returns the settings of the sysctl configuration file
+// conflicting with the kernel parameters configured by skuba
+func sysctlOverrides(file, contents string, parameters map[string]string) []string {
+	overrides := []string{}
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		attribute := strings.Replace(strings.TrimPrefix(strings.TrimSpace(parts[0]), "-"), "/", ".", -1)
+		value := strings.TrimSpace(parts[1])
+		if expected, found := parameters[attribute]; found && expected != value {
+			overrides = append(overrides, fmt.Sprintf("%s sets %s to %s, overriding the %s configured by skuba", file, attribute, value, expected))
+		}
+	}
+	return overrides
+}
+
+// requiredPorts returns the ports the kubernetes components of the role
+// listen on, of every role when nil
+func requiredPorts(role *deployments.Role) []int {
+	if role != nil && *role == deployments.WorkerRole {
+		return []int{10250}
+	}
+	return []int{2379, 2380, 6443, 10250}
+}
+
+func checkPorts(t *Target, data interface{}) error {
+	preflightConfiguration, err := preflightConfiguration(data)
+	if err != nil {
+		return err
+	}
+	result, err := t.silentSsh("ss", "--no-header", "--listening", "--tcp", "--numeric")
+	if err != nil {
+		return err
+	}
+	listening := listeningPorts(result.Stdout)
+	inUse := []string{}
+	for _, port := range requiredPorts(preflightConfiguration.Role) {
+		if listening[port] {
+			inUse = append(inUse, strconv.Itoa(port))
+		}
+	}
+	if len(inUse) > 0 {
+		return errors.Errorf("ports %s are already in use", strings.Join(inUse, ", "))
+	}
+	return nil
+}
+
+// listeningPorts returns the ports listed by ss
+func listeningPorts(ss string) map[int]bool {
+	ports := map[int]bool{}
+	for _, line := range strings.Split(ss, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		address := fields[3]
+		port, err := strconv.Atoi(address[strings.LastIndex(address, ":")+1:])
+		if err != nil {
+			continue
+		}
+		ports[port] = true
+	}
+	return ports
+}
+
+func checkTimeSync(t *Target, data interface{}) error {
+	result, err := t.silentSsh("timedatectl", "status")
+	if err != nil {
+		return err
+	}
+	if !timeSynchronized(result.Stdout) {
+		return errors.New("the system clock is not synchronized, chronyd or another NTP service has to be running")
+	}
+	return nil
+}
+
+// timeSynchronized returns whether the output of timedatectl reports the
+// system clock as synchronized
+func timeSynchronized(timedatectl string) bool {
+	for _, line := range strings.Split(timedatectl, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 && strings.HasSuffix(strings.TrimSpace(parts[0]), "synchronized") {
+			return strings.TrimSpace(parts[1]) == "yes"
+		}
+	}
+	return false
+}
+
+// controlPlaneHostAndPort returns the host and port of the control plane
+// endpoint, the API server port is used when the endpoint has none
+func controlPlaneHostAndPort(endpoint string) (string, string) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return strings.Trim(endpoint, "[]"), "6443"
+	}
+	return host, port
+}
+
+func checkDNS(t *Target, data interface{}) error {
+	preflightConfiguration, err := preflightConfiguration(data)
+	if err != nil {
+		return err
+	}
+	if preflightConfiguration.ControlPlaneEndpoint == "" {
+		return deployments.SkipCheck("the control plane endpoint is unknown")
+	}
+	host, _ := controlPlaneHostAndPort(preflightConfiguration.ControlPlaneEndpoint)
+	if net.ParseIP(host) != nil {
+		return deployments.SkipCheck("the control plane endpoint is an IP address")
+	}
+	if _, err := t.silentSsh("getent", "hosts", host); err != nil {
+		if _, ok := errors.Cause(err).(*deployments.CommandError); ok {
+			return errors.Errorf("the control plane endpoint %s cannot be resolved", host)
+		}
+		return err
+	}
+	return nil
+}
+
+func checkControlPlaneEndpoint(t *Target, data interface{}) error {
+	preflightConfiguration, err := preflightConfiguration(data)
+	if err != nil {
+		return err
+	}
+	if preflightConfiguration.ControlPlaneEndpoint == "" {
+		return deployments.SkipCheck("the control plane endpoint is unknown")
+	}
+	if !preflightConfiguration.ControlPlaneRunning {
+		return deployments.SkipCheck("the cluster has not been bootstrapped yet")
+	}
+	url := fmt.Sprintf("https://%s/healthz", net.JoinHostPort(controlPlaneHostAndPort(preflightConfiguration.ControlPlaneEndpoint)))
+	if _, err := t.silentSsh("curl", "--silent", "--insecure", "--max-time", "10", "--output", "/dev/null", url); err != nil {
+		if cmdErr, ok := errors.Cause(err).(*deployments.CommandError); ok {
+			return errors.Errorf("%s cannot be reached (curl exit code %d)", url, cmdErr.ExitCode)
+		}
+		return err
+	}
+	return nil
+}
+
+func checkRepositories(t *Target, data interface{}) error {
+	preflightConfiguration, err := preflightConfiguration(data)
+	if err != nil {
+		return err
+	}
+	profile, err := t.target.OSProfile()
+	if err != nil {
+		return err
+	}
+	if profile.PackageManager == "" {
+		return deployments.SkipCheck("packages cannot be installed on %s nodes", profile.Name)
+	}
+	kubernetesVersion, err := version.ParseSemantic(preflightConfiguration.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+	majorMinor := kubernetes.MajorMinorVersion(kubernetesVersion)
+	missing := []string{}
+	for _, pkg := range []string{"caasp-release", fmt.Sprintf("kubernetes-%s-kubeadm", majorMinor), fmt.Sprintf("kubernetes-%s-kubelet", majorMinor)} {
+		_, err := t.silentSsh("zypper", "--non-interactive", "search", "--match-exact", "--type", "package", pkg)
+		if err == nil {
+			continue
+		}
+		if cmdErr, ok := errors.Cause(err).(*deployments.CommandError); ok && cmdErr.ExitCode == zypperCapNotFound {
+			missing = append(missing, pkg)
+			continue
+		}
+		return err
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("packages %s are not available in the repositories of the node", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func checkDiskSpace(t *Target, data interface{}) error {
+	result, err := t.silentSsh("df", "--output=avail", "--block-size=1", "/var/lib")
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(result.Stdout)
+	if len(fields) != 2 {
+		return errors.Errorf("could not parse the free disk space of /var/lib: %q", result.Stdout)
+	}
+	available, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "could not parse the free disk space of /var/lib")
+	}
+	if available < minimumFreeDiskSpace {
+		return errors.Errorf("%.1fGiB free in /var/lib, at least %dGiB required", float64(available)/(1<<30), minimumFreeDiskSpace>>30)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kernel"
+)
+
+func TestSwapDevices(t *testing.T) {
+	tests := []struct {
+		name            string
+		swaps           string
+		expectedDevices []string
+	}{
+		{
+			name:            "swap disabled",
+			swaps:           "Filename\t\t\t\tType\t\tSize\tUsed\tPriority\n",
+			expectedDevices: []string{},
+		},
+		{
+			name:            "swap enabled",
+			swaps:           "Filename\t\t\t\tType\t\tSize\tUsed\tPriority\n/dev/vda3                               partition\t2097148\t0\t-2\n",
+			expectedDevices: []string{"/dev/vda3"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if devices := swapDevices(tt.swaps); !reflect.DeepEqual(devices, tt.expectedDevices) {
+				t.Errorf("expected devices %v, got %v", tt.expectedDevices, devices)
+			}
+		})
+	}
+}
+
+func TestSysctlOverrides(t *testing.T) {
+	tests := []struct {
+		name              string
+		contents          string
+		expectedOverrides int
+	}{
+		{
+			name:     "no conflicting setting",
+			contents: "# comment\nnet.ipv4.ip_forward = 1\nvm.swappiness=10\n",
+		},
+		{
+			name:              "conflicting settings",
+			contents:          "net.ipv4.ip_forward = 0\n; comment\n-net/bridge/bridge-nf-call-iptables=0\n",
+			expectedOverrides: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if overrides := sysctlOverrides("/etc/sysctl.d/99-custom.conf", tt.contents, kernel.RequiredParameters); len(overrides) != tt.expectedOverrides {
+				t.Errorf("expected %d overrides, got %v", tt.expectedOverrides, overrides)
+			}
+		})
+	}
+}
+
+func TestListeningPorts(t *testing.T) {
+	ss := `LISTEN 0      128          0.0.0.0:22        0.0.0.0:*
+LISTEN 0      4096       127.0.0.1:2379      0.0.0.0:*
+LISTEN 0      4096               *:6443            *:*
+LISTEN 0      128             [::]:22           [::]:*
+`
+	ports := listeningPorts(ss)
+	for _, port := range []int{22, 2379, 6443} {
+		if !ports[port] {
+			t.Errorf("expected port %d to be listening", port)
+		}
+	}
+	if ports[10250] {
+		t.Error("expected port 10250 not to be listening")
+	}
+}
+
+func TestRequiredPorts(t *testing.T) {
+	worker := deployments.WorkerRole
+	if ports := requiredPorts(&worker); !reflect.DeepEqual(ports, []int{10250}) {
+		t.Errorf("expected only the kubelet port for workers, got %v", ports)
+	}
+	if ports := requiredPorts(nil); len(ports) != 4 {
+		t.Errorf("expected the ports of every role, got %v", ports)
+	}
+}
+
+func TestTimeSynchronized(t *testing.T) {
+	tests := []struct {
+		name         string
+		timedatectl  string
+		synchronized bool
+	}{
+		{
+			name:         "synchronized",
+			timedatectl:  "               Local time: Fri 2020-10-16 10:00:00 UTC\nSystem clock synchronized: yes\n              NTP service: active\n",
+			synchronized: true,
+		},
+		{
+			name:         "synchronized with an older systemd",
+			timedatectl:  "      Local time: Fri 2020-10-16 10:00:00 UTC\n NTP synchronized: yes\n",
+			synchronized: true,
+		},
+		{
+			name:        "not synchronized",
+			timedatectl: "System clock synchronized: no\n              NTP service: inactive\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if synchronized := timeSynchronized(tt.timedatectl); synchronized != tt.synchronized {
+				t.Errorf("expected synchronized %v, got %v", tt.synchronized, synchronized)
+			}
+		})
+	}
+}
+
+func TestControlPlaneChecksSkipped(t *testing.T) {
+	target := &Target{local: true, target: &deployments.Target{Target: "node-0"}}
+	for _, check := range []string{"dns", "control-plane-endpoint"} {
+		err := target.Check(deployments.PreflightConfiguration{}, check)
+		if _, skipped := err.(*deployments.CheckSkippedError); !skipped {
+			t.Errorf("expected check %s to be skipped without a control plane endpoint, got %v", check, err)
+		}
+	}
+}
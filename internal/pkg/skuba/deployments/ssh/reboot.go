@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+const (
+	defRebootTimeout = 10 * time.Minute
+	// rebootDelay is the time after which a scheduled reboot happens, so that
+	// the command scheduling it returns before the connection is lost
+	rebootDelay = "5"
+)
+
+var (
+	// rebootPollInterval is the time between attempts to reach a rebooting
+	// target
+	rebootPollInterval = 10 * time.Second
+	// bootIDFile holds the random identifier of the current boot
+	bootIDFile = "/proc/sys/kernel/random/boot_id"
+)
+
+// isTransactional returns whether the root filesystem of the target is
+// read-only and updated with transactional-update
+func (t *Target) isTransactional() (bool, error) {
+	if t.transactional != nil {
+		return *t.transactional, nil
+	}
+	result, err := t.silentSsh("findmnt", "--noheadings", "--output", "OPTIONS", "/")
+	if err != nil {
+		return false, errors.Wrap(err, "could not get the mount options of the root filesystem")
+	}
+	transactional := false
+	for _, option := range strings.Split(strings.TrimSpace(result.Stdout), ",") {
+		if option != "ro" {
+			continue
+		}
+		_, err := t.silentSsh("test", "-x", "/usr/sbin/transactional-update")
+		if err == nil {
+			transactional = true
+		} else if cmdErr, ok := errors.Cause(err).(*deployments.CommandError); !ok || cmdErr.ExitCode != 1 {
+			return false, err
+		}
+		break
+	}
+	t.transactional = &transactional
+	return transactional, nil
+}
+
+// osProfile returns the profile of the operating system of the target, for
+// its read-only variant when the target runs a transactional system
+func (t *Target) osProfile() (*deployments.OSProfile, error) {
+	profile, err := t.target.OSProfile()
+	if err != nil {
+		return nil, err
+	}
+	if profile.PackageManager != deployments.ZypperPackageManager {
+		return profile, nil
+	}
+	transactional, err := t.isTransactional()
+	if err != nil {
+		return nil, err
+	}
+	if transactional {
+		return profile.Transactional(), nil
+	}
+	return profile, nil
+}
+
+// reboot schedules a reboot of the target and waits for it to be reachable
+// again, so that the following commands run on the new boot
+func (t *Target) reboot() error {
+	if t.dryRun {
+		_, err := t.ssh("systemd-run", "--on-active="+rebootDelay, "systemctl", "reboot")
+		return err
+	}
+	if t.local {
+		return errors.New("the node has to be rebooted to continue, which is not possible with --local")
+	}
+	bootID, err := t.bootID()
+	if err != nil {
+		return err
+	}
+	klog.Infof("rebooting %s", t)
+	if _, err := t.ssh("systemd-run", "--on-active="+rebootDelay, "systemctl", "reboot"); err != nil {
+		return errors.Wrap(err, "could not schedule the reboot")
+	}
+	t.resetClient()
+	return t.waitForReboot(bootID)
+}
+
+// waitForReboot waits until the target can be reached again on a boot other
+// than the given one
+func (t *Target) waitForReboot(previousBootID string) error {
+	start := time.Now()
+	for t.rebootTimeout == 0 || time.Since(start) < t.rebootTimeout {
+		time.Sleep(rebootPollInterval)
+		bootID, err := t.bootID()
+		if err != nil {
+			klog.V(2).Infof("%s is not reachable yet: %s", t, err)
+			t.resetClient()
+			continue
+		}
+		if bootID != previousBootID {
+			klog.Infof("%s is back after rebooting", t)
+			return nil
+		}
+	}
+	return errors.Errorf("%s did not come back within %s after rebooting", t, t.rebootTimeout)
+}
+
+func (t *Target) bootID() (string, error) {
+	result, err := t.silentSsh("cat", bootIDFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestWaitForReboot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-reboot")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(interval time.Duration, file string) {
+		rebootPollInterval = interval
+		bootIDFile = file
+	}(rebootPollInterval, bootIDFile)
+	rebootPollInterval = 10 * time.Millisecond
+	bootIDFile = filepath.Join(dir, "boot_id")
+
+	tests := []struct {
+		name          string
+		rebooted      bool
+		errorExpected bool
+	}{
+		{
+			name:     "target back on a new boot",
+			rebooted: true,
+		},
+		{
+			name:          "target not rebooted",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(bootIDFile, []byte("previous\n"), 0644); err != nil {
+				t.Fatalf("could not write boot id: %v", err)
+			}
+			if tt.rebooted {
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					ioutil.WriteFile(bootIDFile, []byte("next\n"), 0644) //nolint:errcheck
+				}()
+			}
+			target := &Target{local: true, rebootTimeout: time.Second, target: &deployments.Target{Target: "node-0"}}
+			err := target.waitForReboot("previous")
+			if tt.errorExpected && err == nil {
+				t.Error("expected an error but got none")
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+		})
+	}
+}
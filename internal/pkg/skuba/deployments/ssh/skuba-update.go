@@ -24,16 +24,16 @@ func init() {
 }
 
 func skubaUpdateStartNoBlock(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "start", "--no-block", "skuba-update")
+	_, err := t.ssh("systemctl", "start", "--no-block", "skuba-update")
 	return err
 }
 
 func skubaUpdateTimerEnable(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "enable", "--now", "skuba-update.timer")
+	_, err := t.ssh("systemctl", "enable", "--now", "skuba-update.timer")
 	return err
 }
 
 func skubaUpdateTimerDisable(t *Target, data interface{}) error {
-	_, _, err := t.ssh("systemctl", "disable", "--now", "skuba-update.timer")
+	_, err := t.ssh("systemctl", "disable", "--now", "skuba-update.timer")
 	return err
 }
@@ -20,20 +20,20 @@ package ssh
 import (
 	"bufio"
 	"bytes"
-	"crypto/md5"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
-	"github.com/spf13/cobra"
+	"github.com/pkg/sftp"
 	flag "github.com/spf13/pflag"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/knownhosts"
 	"k8s.io/klog"
 
@@ -44,6 +44,14 @@ const (
 	// defKnownHosts is the default `known_hosts` file
 	defKnownHosts = "known_hosts"
 	defSSHPort    = 22
+
+	// StrictHostKeyCheckingYes rejects hosts whose key is not already known
+	StrictHostKeyCheckingYes = "yes"
+	// StrictHostKeyCheckingAcceptNew adds unknown host keys to the known_hosts,
+	// but rejects hosts whose key has changed
+	StrictHostKeyCheckingAcceptNew = "accept-new"
+	// StrictHostKeyCheckingNo does not check host keys at all
+	StrictHostKeyCheckingNo = "no"
 )
 
 // trustHostMessage is the message printed when we don't know about a host
@@ -63,7 +71,7 @@ var fingerprintMismatchMessage = template.Must(template.New("id-chg").Parse(`
 IT IS POSSIBLE THAT SOMEONE IS DOING SOMETHING NASTY!
 Someone could be eavesdropping on you right now (man-in-the-middle attack)!
 It is also possible that the {{.Algorithm }} host key has just been changed.
-The fingerprint for the RSA key sent by the remote host is
+The fingerprint for the {{.Algorithm}} key sent by the remote host is
 {{.Fingerprint}}.
 Please contact your system administrator.
 Add correct host key in {{.Filename}} to get rid of this message, or remove the offending key with: 
@@ -82,6 +90,7 @@ var (
 		ssh.KeyAlgoECDSA256: "ECDSA",
 		ssh.KeyAlgoECDSA384: "ECDSA",
 		ssh.KeyAlgoECDSA521: "ECDSA",
+		ssh.KeyAlgoED25519:  "ED25519",
 	}
 )
 
@@ -96,6 +105,44 @@ type Target struct {
 	bastionPort  int
 	verboseLevel string
 	client       *ssh.Client
+	sftpClient   *sftp.Client
+	sftpErr      error
+
+	sshKey               string
+	sshKeyPassphraseFile string
+	sshPassword          bool
+	sshConfigFile        string
+	credentials          *credentials
+
+	strictHostKeyChecking string
+	knownHostsFile        string
+
+	connectTimeout    time.Duration
+	keepaliveInterval time.Duration
+	commandTimeout    time.Duration
+	stateTimeout      time.Duration
+	stateDeadline     time.Time
+	rebootTimeout     time.Duration
+
+	// transactional is set once it is known whether the root filesystem is
+	// read-only and updated with transactional-update
+	transactional *bool
+
+	// local runs the commands on the machine skuba is running on,
+	// without SSH
+	local bool
+	// executor runs the commands and file transfers instead of SSH when set
+	executor Executor
+	// dryRun prints the changes to the target instead of applying them
+	dryRun bool
+	// resume skips the states recorded as applied by a previous deployment
+	resume     bool
+	checkpoint *checkpoint
+
+	flags *flag.FlagSet
+	// overrides are the settings given for a specific node, which take
+	// precedence over the ssh config like explicit flags do
+	overrides map[string]bool
 }
 
 // GetFlags adds init flags bound to the config to the specified flagset
@@ -104,26 +151,60 @@ func (t *Target) GetFlags() *flag.FlagSet {
 	flagSet.StringVarP(&t.bastionUser, "bastion-user", "", "", "User identity used to connect to the bastion using SSH (default to target user)")
 	flagSet.IntVarP(&t.bastionPort, "bastion-port", "", defSSHPort, "Port to connect to the bastion using SSH")
 	flagSet.StringVarP(&t.bastion, "bastion", "", "", "IP or FQDN of the bastion to connect to the other nodes using SSH")
-	flagSet.StringVarP(&t.user, "user", "u", "", "User identity used to connect to target using SSH")
+	flagSet.StringVarP(&t.user, "user", "u", "", "User identity used to connect to target using SSH (default to ssh config User or current user)")
 	flagSet.BoolVarP(&t.sudo, "sudo", "s", false, "Run remote command via sudo")
-	flagSet.IntVarP(&t.port, "port", "p", defSSHPort, "Port to connect to using SSH")
+	flagSet.IntVarP(&t.port, "port", "p", defSSHPort, "Port to connect to using SSH (default to ssh config Port)")
 	flagSet.StringVarP(&t.targetName, "target", "t", "", "IP or FQDN of the node to connect to using SSH (required)")
-
-	_ = cobra.MarkFlagRequired(flagSet, "target")
-	_ = cobra.MarkFlagRequired(flagSet, "user")
-
+	flagSet.StringVarP(&t.sshKey, "ssh-key", "", "", "Path to a private key used to authenticate using SSH, in addition to the ssh-agent keys")
+	flagSet.StringVarP(&t.sshKeyPassphraseFile, "ssh-key-passphrase-file", "", "", "Path to a file containing the passphrase of the private key given with --ssh-key")
+	flagSet.BoolVarP(&t.sshPassword, "ssh-password", "", false, "Prompt for a password to authenticate using SSH if key based authentication fails")
+	flagSet.StringVarP(&t.strictHostKeyChecking, "strict-host-key-checking", "", StrictHostKeyCheckingAcceptNew, "Host key checking mode (yes|accept-new|no): reject unknown hosts, trust and record them, or do not check host keys at all")
+	flagSet.StringVarP(&t.knownHostsFile, "known-hosts", "", defKnownHosts, "Path to the known_hosts file where host keys are recorded, ~/.ssh/known_hosts is also trusted")
+	flagSet.DurationVarP(&t.connectTimeout, "ssh-connect-timeout", "", defConnectTimeout, "Time to wait for the SSH connection to be established")
+	flagSet.DurationVarP(&t.keepaliveInterval, "ssh-keepalive-interval", "", defKeepaliveInterval, "Interval between SSH keepalive requests, the connection is considered lost after 3 unanswered ones (0 disables keepalives)")
+	flagSet.DurationVarP(&t.commandTimeout, "command-timeout", "", 0, "Time to wait for each remote command to finish (0 waits indefinitely)")
+	flagSet.DurationVarP(&t.stateTimeout, "state-timeout", "", 0, "Time to wait for each deployment state to be applied (0 waits indefinitely)")
+	flagSet.DurationVarP(&t.rebootTimeout, "reboot-timeout", "", defRebootTimeout, "Time to wait for a node to be reachable again after a reboot required by transactional-update (0 waits indefinitely)")
+	flagSet.StringVarP(&t.sshConfigFile, "ssh-config", "F", "", "Path to the OpenSSH client configuration file used to resolve the target host (default ~/.ssh/config)")
+	flagSet.BoolVarP(&t.local, "local", "", false, "Run the commands on the machine skuba is running on instead of using SSH (--target defaults to the hostname)")
+	flagSet.BoolVarP(&t.dryRun, "dry-run", "", false, "Print the commands, file uploads and package transactions that would be applied to the target without changing it")
+	flagSet.BoolVarP(&t.resume, "resume", "", false, "Skip the states already applied on the target by a previous interrupted run, as recorded in the checkpoints folder, when they are still in effect")
+
+	t.flags = flagSet
+	t.credentials = &credentials{}
 	return flagSet
 }
 
+// Validate checks that the flags describe a target skuba can connect to
+func (t *Target) Validate() error {
+	if len(t.targetName) == 0 && !t.local {
+		return errors.New("required flag \"target\" not set, unless --local is used")
+	}
+	return nil
+}
+
 func (t Target) String() string {
+	if t.local {
+		return fmt.Sprintf("local (%s)", t.target.Target)
+	}
 	return fmt.Sprintf("%s@%s:%d", t.user, t.target.Target, t.port)
 }
 
 func (t *Target) GetDeployment(nodename string, role *deployments.Role, verboseLevel string) *deployments.Target {
+	targetName := t.targetName
+	if len(targetName) == 0 && t.local {
+		hostname, err := os.Hostname()
+		if err != nil {
+			klog.Fatalf("could not get the hostname of the local machine: %s", err)
+		}
+		targetName = hostname
+	}
 	res := deployments.Target{
-		Target:   t.targetName,
+		Target:   targetName,
 		Nodename: nodename,
 		Role:     role,
+		DryRun:   t.dryRun,
+		Resume:   t.resume,
 	}
 	res.Actionable = &Target{
 		target:       &res,
@@ -134,164 +215,321 @@ func (t *Target) GetDeployment(nodename string, role *deployments.Role, verboseL
 		bastionUser:  t.bastionUser,
 		bastionPort:  t.bastionPort,
 		verboseLevel: verboseLevel,
+
+		sshKey:               t.sshKey,
+		sshKeyPassphraseFile: t.sshKeyPassphraseFile,
+		sshPassword:          t.sshPassword,
+		sshConfigFile:        t.sshConfigFile,
+		credentials:          t.getCredentials(),
+
+		strictHostKeyChecking: t.strictHostKeyChecking,
+		knownHostsFile:        t.knownHostsFile,
+
+		connectTimeout:    t.connectTimeout,
+		keepaliveInterval: t.keepaliveInterval,
+		commandTimeout:    t.commandTimeout,
+		stateTimeout:      t.stateTimeout,
+		rebootTimeout:     t.rebootTimeout,
+
+		local:     t.local,
+		dryRun:    t.dryRun,
+		resume:    t.resume,
+		flags:     t.flags,
+		overrides: t.overrides,
 	}
 	return &res
 }
 
-func (t *Target) silentSsh(command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(true, "", command, args...)
+// GetNodeDeployment returns the deployment of another node, connecting to
+// targetName with the same settings
+func (t *Target) GetNodeDeployment(targetName, nodename string, role *deployments.Role, verboseLevel string) *deployments.Target {
+	nodeTarget := *t
+	nodeTarget.targetName = targetName
+	return nodeTarget.GetDeployment(nodename, role, verboseLevel)
 }
 
-func (t *Target) ssh(command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(false, "", command, args...)
+// WithConnection returns a copy of the target connecting with the given user
+// and port when they are set, and using sudo if requested, as described for
+// a node of an inventory
+func (t *Target) WithConnection(user string, port int, sudo bool) *Target {
+	nodeTarget := *t
+	nodeTarget.overrides = map[string]bool{}
+	for name := range t.overrides {
+		nodeTarget.overrides[name] = true
+	}
+	if user != "" {
+		nodeTarget.user = user
+		nodeTarget.overrides["user"] = true
+	}
+	if port != 0 {
+		nodeTarget.port = port
+		nodeTarget.overrides["port"] = true
+	}
+	nodeTarget.sudo = t.sudo || sudo
+	return &nodeTarget
+}
+
+// silent commands are internal plumbing (file transfers, checks) that can be
+// safely retried on a new connection if the connection is lost while running
+func (t *Target) silentSsh(command string, args ...string) (*deployments.CommandResult, error) {
+	return t.internalSshWithStdin(true, true, "", command, args...)
+}
+
+func (t *Target) ssh(command string, args ...string) (*deployments.CommandResult, error) {
+	if t.dryRun {
+		return t.dryRunCommand("", command, args...), nil
+	}
+	return t.internalSshWithStdin(false, false, "", command, args...)
 }
 
-func (t *Target) silentSshWithStdin(stdin string, command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(true, stdin, command, args...)
+func (t *Target) silentSshWithStdin(stdin string, command string, args ...string) (*deployments.CommandResult, error) {
+	return t.internalSshWithStdin(true, true, stdin, command, args...)
 }
 
-func (t *Target) sshWithStdin(stdin string, command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(false, stdin, command, args...)
+func (t *Target) sshWithStdin(stdin string, command string, args ...string) (*deployments.CommandResult, error) {
+	if t.dryRun {
+		return t.dryRunCommand(stdin, command, args...), nil
+	}
+	return t.internalSshWithStdin(false, false, stdin, command, args...)
 }
 
-func (t *Target) internalSshWithStdin(silent bool, stdin string, command string, args ...string) (stdout string, stderr string, error error) {
-	if t.client == nil {
-		if err := t.initClient(); err != nil {
-			return "", "", errors.Wrap(err, "failed to initialize client")
+// internalSshWithStdin runs the command on the target. If the connection is
+// lost, the command is retried on a new connection when it did not start
+// yet, or when it is idempotent. The returned result is never nil.
+func (t *Target) internalSshWithStdin(silent, idempotent bool, stdin string, command string, args ...string) (*deployments.CommandResult, error) {
+	finalCommand := strings.Join(append([]string{command}, args...), " ")
+	if t.sudo {
+		finalCommand = fmt.Sprintf("sudo sh -c '%s'", finalCommand)
+	}
+	for attempt := 0; ; attempt++ {
+		result := &deployments.CommandResult{Command: finalCommand, ExitCode: -1, Start: time.Now()}
+		if t.executor != nil {
+			return result, t.runExecutorCommand(result, stdin)
+		}
+		if t.local {
+			_, err := t.runLocalCommand(result, silent, stdin)
+			result.Duration = time.Since(result.Start)
+			return result, err
+		}
+		if t.client == nil {
+			if err := t.initClient(); err != nil {
+				return result, errors.Wrap(err, "failed to initialize client")
+			}
+		}
+		started, err := t.runCommand(result, silent, stdin)
+		result.Duration = time.Since(result.Start)
+		if err == nil || !isConnectionError(err) || attempt >= maxReconnectAttempts || (started && !idempotent) {
+			return result, err
 		}
+		klog.Warningf("connection to %s lost: %s, reconnecting (attempt %d/%d)", t, err, attempt+1, maxReconnectAttempts)
+		t.resetClient()
 	}
+}
+
+// runCommand runs the command of the result in a new session, filling the
+// result and returning whether the command was started on the target
+func (t *Target) runCommand(result *deployments.CommandResult, silent bool, stdin string) (started bool, err error) {
 	session, err := t.client.NewSession()
 	if err != nil {
-		return "", "", err
+		return false, err
 	}
+	defer session.Close()
 	if len(stdin) > 0 {
 		session.Stdin = bytes.NewBufferString(stdin)
 	}
 	stdoutReader, err := session.StdoutPipe()
 	if err != nil {
-		return "", "", err
+		return false, err
 	}
 	stderrReader, err := session.StderrPipe()
 	if err != nil {
-		return "", "", err
-	}
-	finalCommand := strings.Join(append([]string{command}, args...), " ")
-	if t.sudo {
-		finalCommand = fmt.Sprintf("sudo sh -c '%s'", finalCommand)
+		return false, err
 	}
 	if !silent {
-		klog.V(2).Infof("running command: %q", finalCommand)
+		klog.V(2).Infof("running command: %q", result.Command)
 	}
-	if err := session.Start(finalCommand); err != nil {
-		return "", "", err
+	if err := session.Start(result.Command); err != nil {
+		return false, err
 	}
-	stdoutChan := make(chan string)
-	stderrChan := make(chan string)
+	stdoutChan := make(chan string, 1)
+	stderrChan := make(chan string, 1)
 	go readerStreamer(stdoutReader, stdoutChan, "stdout", silent)
 	go readerStreamer(stderrReader, stderrChan, "stderr", silent)
-	if err := session.Wait(); err != nil {
-		return "", "", err
+
+	waitChan := make(chan error, 1)
+	go func() {
+		waitChan <- session.Wait()
+	}()
+	var timeoutChan <-chan time.Time
+	timeout := t.timeout()
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
 	}
-	stdout = <-stdoutChan
-	stderr = <-stderrChan
-	return stdout, stderr, nil
+
+	select {
+	case err = <-waitChan:
+		result.Stdout = <-stdoutChan
+		result.Stderr = <-stderrChan
+		result.Duration = time.Since(result.Start)
+		if err == nil {
+			result.ExitCode = 0
+		} else if exitErr, ok := err.(*ssh.ExitError); ok {
+			result.ExitCode = exitErr.ExitStatus()
+			err = &deployments.CommandError{CommandResult: result}
+		}
+	case <-timeoutChan:
+		// best effort: not every SSH server supports signals
+		_ = session.Signal(ssh.SIGKILL)
+		session.Close()
+		result.Stdout, result.Stderr = drainStreams(stdoutChan, stderrChan)
+		result.Duration = time.Since(result.Start)
+		err = &deployments.CommandError{CommandResult: result, TimedOut: true, Timeout: timeout}
+	}
+	return true, err
 }
 
 func readerStreamer(reader io.Reader, outputChan chan<- string, description string, silent bool) {
-	result := bytes.Buffer{}
+	lines := []string{}
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
-		result.Write([]byte(scanner.Text()))
+		lines = append(lines, scanner.Text())
 		if (description == "stdout" && !silent) || description == "stderr" {
 			klog.V(2).Infof("%s", scanner.Text())
 		}
 	}
-	outputChan <- result.String()
+	outputChan <- strings.Join(lines, "\n")
 }
 
-// initClient initializes the ssh client to the target
+// initClient initializes the ssh client to the target, going through the
+// bastion or the ProxyJump hosts of the ssh config if any
 func (t *Target) initClient() error {
-	socket := os.Getenv("SSH_AUTH_SOCK")
-	if len(socket) == 0 {
-		return errors.Errorf("SSH_AUTH_SOCK is undefined. Make sure ssh-agent is running")
-	}
-
-	agentConn, err := net.Dial("unix", socket)
+	hops, err := t.hops()
 	if err != nil {
 		return err
 	}
-	agentClient := agent.NewClient(agentConn)
 
-	// check a precondition: there must be some SSH keys loaded in the ssh agent
-	keys, err := agentClient.List()
+	hostKeyCallback, err := hostKeyChecker(t.strictHostKeyChecking, t.knownHostsFile)
 	if err != nil {
 		return err
 	}
-	if len(keys) == 0 {
-		klog.Errorf("no keys have been loaded in the ssh-agent.")
-		return errSSHNoKeysErr
+
+	var client *ssh.Client
+	for _, h := range hops {
+		if client, err = t.dialHop(client, h, hostKeyCallback); err != nil {
+			return err
+		}
 	}
+	t.client = client
+	t.startKeepalive(client)
+
+	return nil
+}
 
-	hostKeyCallback, err := hostKeyChecker()
+// hops returns the list of connections needed to reach the target, the
+// target itself being the last one. Explicit flags take precedence over the
+// ssh config, and --bastion takes precedence over any ProxyJump.
+// dialHop connects to the hop, through the previous one if any. The ssh-agent
+// connection used to authenticate is closed once the handshake is done.
+func (t *Target) dialHop(previous *ssh.Client, h hop, hostKeyCallback ssh.HostKeyCallback) (*ssh.Client, error) {
+	authMethods, closeAgent, err := t.authMethods(h.identityFiles)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	nodeConfig, err := createClientConfig(t.user, agentClient, hostKeyCallback)
+	defer closeAgent()
+	config, err := createClientConfig(h.user, authMethods, hostKeyCallback, t.connectTimeout)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	nodeAddr := fmt.Sprintf("%s:%d", t.target.Target, t.port)
 
-	// Use direct connection to the node
-	if t.bastion == "" {
-		t.client, err = ssh.Dial("tcp", nodeAddr, nodeConfig)
+	// Use direct connection to the first hop
+	if previous == nil {
+		klog.V(1).Infof("connecting to %s@%s", h.user, h.address())
+		client, err := ssh.Dial("tcp", h.address(), config)
 		if err != nil {
-			return checkDialError(err, t.target.Target)
+			return nil, checkDialError(err, h.alias)
 		}
-		return nil
+		return client, nil
 	}
 
-	if t.bastionUser == "" {
-		t.bastionUser = t.user
+	// Start a client connection from the previous hop to this one
+	klog.V(1).Infof("connecting to %s@%s through %s", h.user, h.address(), previous.RemoteAddr())
+	conn, err := previous.Dial("tcp", h.address())
+	if err != nil {
+		return nil, checkDialError(err, h.alias)
 	}
 
-	bastionConfig, err := createClientConfig(t.bastionUser, agentClient, hostKeyCallback)
+	// Establish an authenticated connection over the previous hop connection
+	sshConn, newChannelChan, requestChan, err := ssh.NewClientConn(conn, h.address(), config)
 	if err != nil {
-		return err
+		klog.Errorf("cannot establish an authenticated connection to %s", h.alias)
+		return nil, checkDialError(err, h.alias)
 	}
+	return ssh.NewClient(sshConn, newChannelChan, requestChan), nil
+}
 
-	// Start a client connection to the bastion
-	bastionClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", t.bastion, t.bastionPort), bastionConfig)
+func (t *Target) hops() ([]hop, error) {
+	sshConfigFile := t.sshConfigFile
+	if sshConfigFile == "" {
+		sshConfigFile = defaultSSHConfigFile()
+	}
+	config, err := loadSSHConfig(sshConfigFile, defSystemSSHConfig)
 	if err != nil {
-		return checkDialError(err, t.bastion)
+		return nil, err
 	}
 
-	// Start a client connection from the bastion to the node
-	bastionTargetConn, err := bastionClient.Dial("tcp", nodeAddr)
-	if err != nil {
-		return checkDialError(err, t.target.Target)
+	target := hop{alias: t.target.Target}
+	if t.isFlagSet("user") {
+		target.user = t.user
+	}
+	if t.isFlagSet("port") {
+		target.port = t.port
 	}
 
-	// Establish an authenticated connection to the node over the bastion connection
-	sshConn, newChannelChan, requestChan, err := ssh.NewClientConn(bastionTargetConn, nodeAddr, nodeConfig)
-	if err != nil {
-		klog.Errorf("cannot establish an authenticated connection to the node")
-		return err
+	var hops []hop
+	if t.bastion == "" {
+		hops, err = config.chain(target, 0)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		target, err = config.resolve(target)
+		if err != nil {
+			return nil, err
+		}
+		bastionUser := t.bastionUser
+		if bastionUser == "" {
+			bastionUser = target.user
+		}
+		bastion, err := config.resolve(hop{alias: t.bastion, user: bastionUser, port: t.bastionPort})
+		if err != nil {
+			return nil, err
+		}
+		hops = []hop{bastion, target}
 	}
 
-	t.client = ssh.NewClient(sshConn, newChannelChan, requestChan)
+	// keep the resolved values around for display purposes
+	t.user = hops[len(hops)-1].user
+	t.port = hops[len(hops)-1].port
 
-	return nil
+	return hops, nil
 }
 
-func createClientConfig(user string, agentClient agent.ExtendedAgent, hostKeyCallback ssh.HostKeyCallback) (*ssh.ClientConfig, error) {
+// isFlagSet returns whether the given flag was explicitly provided by the user
+func (t *Target) isFlagSet(name string) bool {
+	if t.flags == nil || t.overrides[name] {
+		return true
+	}
+	return t.flags.Changed(name)
+}
+
+func createClientConfig(user string, authMethods []ssh.AuthMethod, hostKeyCallback ssh.HostKeyCallback, timeout time.Duration) (*ssh.ClientConfig, error) {
 	return &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeysCallback(agentClient.Signers),
-		},
+		User:            user,
+		Auth:            authMethods,
 		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
 	}, nil
 }
 
@@ -300,29 +538,55 @@ func checkDialError(err error, host string) error {
 	// must "pattern match" the error strings in order to guess what failed
 	if strings.Contains(err.Error(), "unable to authenticate") {
 		klog.Errorf("ssh authentication error: please make sure you have added to "+
-			"your ssh-agent a ssh key that is authorized in %q.", host)
+			"your ssh-agent, or passed with --ssh-key, a ssh key that is authorized in %q, "+
+			"or use --ssh-password.", host)
 		return errSSHAuthErr
 	}
 	return err
 }
 
 // hostKeyChecker checks that the host fingerprint is stored in the known_hosts
-// if not present, it warns user (optionally asking for the key to be accepted or not)
-// adding the key to the `known_hosts` file. In case the key is found but there is a
-// mismatch (or the key has been rejected), it returns an error.
-func hostKeyChecker() (ssh.HostKeyCallback, error) {
+// files. If not present, depending on the strict host key checking mode, it
+// either rejects the host, or warns the user and adds the key to the
+// knownHostsFile. In case the key is found but there is a mismatch (or the key
+// has been revoked), it returns an error regardless of the mode, unless host
+// key checking has been disabled altogether.
+func hostKeyChecker(mode, knownHostsFile string) (ssh.HostKeyCallback, error) {
+	if mode == "" {
+		mode = StrictHostKeyCheckingAcceptNew
+	}
+	switch mode {
+	case StrictHostKeyCheckingYes, StrictHostKeyCheckingAcceptNew:
+	case StrictHostKeyCheckingNo:
+		klog.Warning("host key checking is disabled, connections are vulnerable to man-in-the-middle attacks")
+		return ssh.InsecureIgnoreHostKey(), nil
+	default:
+		return nil, errors.Errorf("invalid strict host key checking mode %q, 'yes', 'accept-new' or 'no' are the only accepted values", mode)
+	}
+
+	if knownHostsFile == "" {
+		knownHostsFile = defKnownHosts
+	}
+
 	// make sure the filename exists from the start
-	err := os.MkdirAll(path.Dir(defKnownHosts), 0700)
-	if err != nil {
+	if err := os.MkdirAll(path.Dir(knownHostsFile), 0700); err != nil {
 		return nil, err
 	}
-
-	out, err := os.OpenFile(defKnownHosts, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	f, err := os.OpenFile(knownHostsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
 	if err != nil {
 		return nil, err
 	}
+	f.Close()
+
+	// keys known by the user are trusted as well, but never written to
+	knownHostsFiles := []string{knownHostsFile}
+	if userKnownHosts := defaultUserKnownHostsFile(); userKnownHosts != "" {
+		if _, err := os.Stat(userKnownHosts); err == nil {
+			knownHostsFiles = append(knownHostsFiles, userKnownHosts)
+		}
+	}
 
-	hostKeyCallback, err := knownhosts.New(defKnownHosts)
+	hostKeyCallback, err := knownhosts.New(knownHostsFiles...)
 	if err != nil {
 		klog.Errorf("could not create callback function for checking hosts fingerprints: %s", err)
 		return nil, errSSHNoKeysErr
@@ -338,8 +602,10 @@ func hostKeyChecker() (ssh.HostKeyCallback, error) {
 			klog.Errorf("remote host identification for %q has been revoked", hostname)
 			return re
 		} else if ke, ok := err.(*knownhosts.KeyError); ok {
-			algoStr := algoToStr[key.Type()]
-			keyFingerprintStr := md5String(md5.Sum(key.Marshal()))
+			algoStr, found := algoToStr[key.Type()]
+			if !found {
+				algoStr = key.Type()
+			}
 
 			// process one of the error messages as a template, returning the
 			// text after replacing some vars...
@@ -353,8 +619,8 @@ func hostKeyChecker() (ssh.HostKeyCallback, error) {
 				}{
 					algoStr,
 					hostname,
-					keyFingerprintStr,
-					defKnownHosts,
+					ssh.FingerprintSHA256(key),
+					knownHostsFile,
 				}); err != nil {
 					klog.Fatal("could not perform replacements in template")
 				}
@@ -366,14 +632,15 @@ func hostKeyChecker() (ssh.HostKeyCallback, error) {
 			// unknown. If Want is non-empty, there was a mismatch, which
 			// can signify a MITM attack.
 			if len(ke.Want) == 0 {
+				if mode == StrictHostKeyCheckingYes {
+					klog.Error(replaceMessage(trustHostMessage))
+					return errors.Errorf("no %s host key is known for %q and strict host key checking is enabled, "+
+						"add the host key to %q", algoStr, hostname, knownHostsFile)
+				}
 				klog.Warning(replaceMessage(trustHostMessage))
 				klog.Infof("accepting SSH key for %q", hostname)
-				klog.Infof("adding fingerprint for %q to %q", hostname, defKnownHosts)
-				line := knownhosts.Line([]string{hostname}, key)
-				if _, err := out.WriteString(line + "\n"); err != nil {
-					return err
-				}
-				return nil
+				klog.Infof("adding fingerprint for %q to %q", hostname, knownHostsFile)
+				return appendKnownHost(knownHostsFile, hostname, key)
 			}
 			// fingerprint mismatch: print a big warning and return an error
 			klog.Error(replaceMessage(fingerprintMismatchMessage))
@@ -382,9 +649,22 @@ func hostKeyChecker() (ssh.HostKeyCallback, error) {
 	}), nil
 }
 
-// md5String returns a formatted string representing the given md5Sum in hex
-func md5String(md5Sum [16]byte) string {
-	md5Str := fmt.Sprintf("% x", md5Sum)
-	md5Str = strings.Replace(md5Str, " ", ":", -1)
-	return md5Str
+func appendKnownHost(knownHostsFile, hostname string, key ssh.PublicKey) error {
+	out, err := os.OpenFile(knownHostsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	line := knownhosts.Line([]string{hostname}, key)
+	_, err = out.WriteString(line + "\n")
+	return err
+}
+
+// defaultUserKnownHostsFile returns the path to the user OpenSSH known_hosts
+func defaultUserKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
 }
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate host key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("could not create host public key: %v", err)
+	}
+	return key
+}
+
+func TestHostKeyChecker(t *testing.T) {
+	home := os.Getenv("HOME")
+	defer func() {
+		if err := os.Setenv("HOME", home); err != nil {
+			t.Errorf("could not restore HOME: %v", err)
+		}
+	}()
+
+	const hostname = "10.0.0.1:22"
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+
+	tests := []struct {
+		name                 string
+		mode                 string
+		knownKey             bool
+		userKnownKey         bool
+		changedKey           bool
+		errorExpected        bool
+		expectedRecordedKeys int
+	}{
+		{
+			name:                 "accept-new records unknown host keys",
+			mode:                 StrictHostKeyCheckingAcceptNew,
+			expectedRecordedKeys: 1,
+		},
+		{
+			name:                 "default mode records unknown host keys",
+			mode:                 "",
+			expectedRecordedKeys: 1,
+		},
+		{
+			name:          "yes rejects unknown host keys",
+			mode:          StrictHostKeyCheckingYes,
+			errorExpected: true,
+		},
+		{
+			name:                 "yes accepts known host keys",
+			mode:                 StrictHostKeyCheckingYes,
+			knownKey:             true,
+			expectedRecordedKeys: 1,
+		},
+		{
+			name:         "yes accepts host keys known by the user",
+			mode:         StrictHostKeyCheckingYes,
+			userKnownKey: true,
+		},
+		{
+			name:                 "accept-new rejects changed host keys",
+			mode:                 StrictHostKeyCheckingAcceptNew,
+			knownKey:             true,
+			changedKey:           true,
+			errorExpected:        true,
+			expectedRecordedKeys: 1,
+		},
+		{
+			name:                 "no accepts changed host keys",
+			mode:                 StrictHostKeyCheckingNo,
+			knownKey:             true,
+			changedKey:           true,
+			expectedRecordedKeys: 1,
+		},
+		{
+			name:          "invalid mode",
+			mode:          "ask",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-ssh-known-hosts")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.Setenv("HOME", dir); err != nil {
+				t.Fatalf("could not set HOME: %v", err)
+			}
+			if err := os.MkdirAll(filepath.Join(dir, ".ssh"), 0700); err != nil {
+				t.Fatalf("could not create .ssh directory: %v", err)
+			}
+
+			knownHostsFile := filepath.Join(dir, "cluster", "known_hosts")
+			key := newTestHostKey(t)
+			if tt.knownKey {
+				if err := os.MkdirAll(filepath.Dir(knownHostsFile), 0700); err != nil {
+					t.Fatalf("could not create known_hosts directory: %v", err)
+				}
+				if err := appendKnownHost(knownHostsFile, hostname, key); err != nil {
+					t.Fatalf("could not write known_hosts: %v", err)
+				}
+			}
+			if tt.userKnownKey {
+				if err := appendKnownHost(defaultUserKnownHostsFile(), hostname, key); err != nil {
+					t.Fatalf("could not write user known_hosts: %v", err)
+				}
+			}
+			if tt.changedKey {
+				key = newTestHostKey(t)
+			}
+
+			callback, err := hostKeyChecker(tt.mode, knownHostsFile)
+			if err == nil {
+				err = callback(hostname, remote, key)
+			}
+			if tt.errorExpected && err == nil {
+				t.Error("expected an error but got none")
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+
+			contents, _ := ioutil.ReadFile(knownHostsFile)
+			recordedKeys := strings.Count(string(contents), "\n")
+			if recordedKeys != tt.expectedRecordedKeys {
+				t.Errorf("expected %d keys in %s, got %d", tt.expectedRecordedKeys, knownHostsFile, recordedKeys)
+			}
+		})
+	}
+}
+
+func TestWithConnection(t *testing.T) {
+	target := &Target{}
+	target.GetFlags()
+	target.user = "sles"
+
+	nodeTarget := target.WithConnection("root", 2222, true)
+	if nodeTarget.user != "root" || nodeTarget.port != 2222 || !nodeTarget.sudo {
+		t.Errorf("expected root@:2222 with sudo, got %s@:%d with sudo %v", nodeTarget.user, nodeTarget.port, nodeTarget.sudo)
+	}
+	if !nodeTarget.isFlagSet("user") || !nodeTarget.isFlagSet("port") {
+		t.Error("expected the node user and port to take precedence over the ssh config")
+	}
+	if target.isFlagSet("user") || target.isFlagSet("port") || target.sudo {
+		t.Error("expected the original target to be left untouched")
+	}
+
+	defaults := target.WithConnection("", 0, false)
+	if defaults.user != "sles" || defaults.port != defSSHPort || defaults.isFlagSet("user") || defaults.isFlagSet("port") {
+		t.Errorf("expected the flags to be used when the node has no SSH settings, got %s@:%d", defaults.user, defaults.port)
+	}
+}
@@ -19,28 +19,184 @@ package ssh
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
+
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
 )
 
 var (
 	stateMap = map[string]Runner{}
+	// inEffectMap holds, for the states that have one, a check of whether the
+	// state is still in effect on the target. When resuming, a state recorded
+	// as applied is only skipped if its check passes.
+	inEffectMap = map[string]InEffectChecker{}
+	// preparingMap holds the states that prepare the node for other states.
+	// When resuming, they are applied again until one of the states they
+	// prepare for completes: e.g. a kubeadm join that failed halfway leaves
+	// files and a running kubelet that make it fail again, unless the node
+	// is reset first.
+	preparingMap = map[string][]string{}
 )
 
 type Runner func(t *Target, data interface{}) error
 
+// InEffectChecker returns whether the changes made by a state are in effect
+type InEffectChecker func(t *Target, data interface{}) (bool, error)
+
 func (t *Target) Apply(data interface{}, states ...string) error {
+	checkpoint, err := t.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	// the data is only hashed when the states are checkpointed
+	hash := ""
+	hashData := func() (string, error) {
+		if hash != "" {
+			return hash, nil
+		}
+		var err error
+		hash, err = dataHash(data)
+		return hash, err
+	}
 	for _, stateName := range states {
-		klog.V(2).Infof("=== applying state %s ===", stateName)
 		if state, stateExists := stateMap[stateName]; stateExists {
-			if err := state(t, data); err != nil {
+			skip, err := t.skipState(checkpoint, stateName, data, hashData)
+			if err != nil {
+				return err
+			}
+			if skip {
+				klog.Infof("state %s already applied on %s, skipping", stateName, t.target.Target)
+				continue
+			}
+			klog.V(2).Infof("=== applying state %s ===", stateName)
+			if err := t.applyState(state, data); err != nil {
 				return errors.Wrapf(err, "failed to apply state %s", stateName)
 			}
 			klog.V(2).Infof("=== state %s applied successfully ===", stateName)
+			if t.dryRun {
+				continue
+			}
+			hash, err := hashData()
+			if err != nil {
+				return err
+			}
+			if err := checkpoint.record(stateName, hash); err != nil {
+				return err
+			}
 		} else {
 			return errors.New(fmt.Sprintf("state does not exist: %s", stateName))
 		}
 	}
 	return nil
 }
+
+// skipState returns whether a resumed deployment can skip the state: it has
+// been applied with the same data, and its check, if any, confirms it is
+// still in effect
+func (t *Target) skipState(checkpoint *checkpoint, stateName string, data interface{}, hashData func() (string, error)) (bool, error) {
+	if !t.resume || !checkpoint.recorded(stateName) {
+		return false, nil
+	}
+	if preparedStates, isPreparing := preparingMap[stateName]; isPreparing {
+		prepared, err := t.anyStateInEffect(checkpoint, preparedStates, data)
+		if err != nil {
+			return false, err
+		}
+		if !prepared {
+			klog.Infof("state %s was applied on %s but the states it prepares for did not complete, applying it again", stateName, t.target.Target)
+			return false, nil
+		}
+	}
+	hash, err := hashData()
+	if err != nil {
+		return false, err
+	}
+	if !checkpoint.applied(stateName, hash) {
+		return false, nil
+	}
+	check, hasCheck := inEffectMap[stateName]
+	if !hasCheck {
+		return true, nil
+	}
+	inEffect, err := check(t, data)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check state %s", stateName)
+	}
+	if !inEffect {
+		klog.Infof("state %s was applied on %s but is no longer in effect, applying it again", stateName, t.target.Target)
+	}
+	return inEffect, nil
+}
+
+// anyStateInEffect returns whether one of the states is recorded as applied
+// and, if it has a check, still in effect
+func (t *Target) anyStateInEffect(checkpoint *checkpoint, states []string, data interface{}) (bool, error) {
+	for _, stateName := range states {
+		if !checkpoint.recorded(stateName) {
+			continue
+		}
+		check, hasCheck := inEffectMap[stateName]
+		if !hasCheck {
+			return true, nil
+		}
+		inEffect, err := check(t, data)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to check state %s", stateName)
+		}
+		if inEffect {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// loadCheckpoint returns the record of the states applied on the target. It
+// is only read when resuming, a new deployment removes it.
+func (t *Target) loadCheckpoint() (*checkpoint, error) {
+	if t.checkpoint != nil {
+		return t.checkpoint, nil
+	}
+	path := skubaconstants.CheckpointFile(t.target.Target)
+	if t.resume {
+		c, err := loadCheckpoint(path)
+		if err != nil {
+			return nil, err
+		}
+		t.checkpoint = c
+		return t.checkpoint, nil
+	}
+	t.checkpoint = &checkpoint{path: path}
+	if !t.dryRun {
+		if err := t.checkpoint.remove(); err != nil {
+			return nil, err
+		}
+	}
+	return t.checkpoint, nil
+}
+
+// ClearCheckpoint removes the record of the states applied on the target,
+// once its deployment succeeded
+func (t *Target) ClearCheckpoint() error {
+	if t.dryRun {
+		return nil
+	}
+	if t.checkpoint == nil {
+		t.checkpoint = &checkpoint{path: skubaconstants.CheckpointFile(t.target.Target)}
+	}
+	return t.checkpoint.remove()
+}
+
+// applyState runs the state, bounding the remote commands it runs by the
+// state timeout, if any
+func (t *Target) applyState(state Runner, data interface{}) error {
+	if t.stateTimeout > 0 {
+		t.stateDeadline = time.Now().Add(t.stateTimeout)
+		defer func() {
+			t.stateDeadline = time.Time{}
+		}()
+	}
+	return state(t, data)
+}
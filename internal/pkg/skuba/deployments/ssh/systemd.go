@@ -18,18 +18,20 @@
 package ssh
 
 import (
-	"golang.org/x/crypto/ssh"
+	"github.com/pkg/errors"
 	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 )
 
 // IsServiceEnabled returns if a service is enabled
 func (t *Target) IsServiceEnabled(serviceName string) (bool, error) {
 	klog.V(2).Infof("checking if %s is enabled", serviceName)
 	isEnabled := true
-	_, _, err := t.silentSsh("systemctl", "is-enabled", serviceName)
+	_, err := t.silentSsh("systemctl", "is-enabled", serviceName)
 	if err != nil {
-		t, isExitError := err.(*ssh.ExitError)
-		if isExitError && t.ExitStatus() == 1 {
+		cmdErr, isCommandError := errors.Cause(err).(*deployments.CommandError)
+		if isCommandError && cmdErr.ExitCode == 1 {
 			isEnabled = false
 			// the error is sane
 			err = nil
@@ -37,3 +39,16 @@ func (t *Target) IsServiceEnabled(serviceName string) (bool, error) {
 	}
 	return isEnabled, err
 }
+
+// isServiceActive returns if a service is running
+func (t *Target) isServiceActive(serviceName string) (bool, error) {
+	klog.V(2).Infof("checking if %s is active", serviceName)
+	_, err := t.silentSsh("systemctl", "is-active", serviceName)
+	if err != nil {
+		if _, isCommandError := errors.Cause(err).(*deployments.CommandError); isCommandError {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
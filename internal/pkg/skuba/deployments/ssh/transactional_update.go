@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// TransactionalUpdateInstall installs an arbitrary list of packages in a new
+// snapshot of the read-only root with transactional-update. The snapshot
+// continues the latest one, so that several installs are kept until the node
+// reboots into it.
+func (t *Target) TransactionalUpdateInstall(packages ...string) (*deployments.CommandResult, error) {
+	var cliArgs []string
+	cliArgs = append(cliArgs, "--non-interactive", "--continue", "pkg", "install", "--auto-agree-with-licenses")
+	cliArgs = append(cliArgs, "--")
+	cliArgs = append(cliArgs, packages...)
+	return t.ssh("transactional-update", cliArgs...)
+}
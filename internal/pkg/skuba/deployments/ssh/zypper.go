@@ -17,11 +17,27 @@
 
 package ssh
 
+import (
+	"fmt"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
 // ZypperInstall runs a zypper command to install an arbitrary list of packages,
-// wrapped with the right userdata and parameters
-func (t *Target) ZypperInstall(packages ...string) (stdout string, stderr string, error error) {
+// wrapped with the right userdata and parameters. In dry-run mode, the package
+// transaction is computed by zypper and printed without being applied.
+func (t *Target) ZypperInstall(packages ...string) (*deployments.CommandResult, error) {
 	var cliArgs []string
-	cliArgs = append(cliArgs, "--userdata", "skuba", "-i", "--non-interactive", "install", "--auto-agree-with-licenses", "--")
+	cliArgs = append(cliArgs, "--userdata", "skuba", "-i", "--non-interactive", "install", "--auto-agree-with-licenses")
+	if t.dryRun {
+		cliArgs = append(cliArgs, "--dry-run")
+	}
+	cliArgs = append(cliArgs, "--")
 	cliArgs = append(cliArgs, packages...)
-	return t.ssh("zypper", cliArgs...)
+	if !t.dryRun {
+		return t.ssh("zypper", cliArgs...)
+	}
+	result, err := t.internalSshWithStdin(false, true, "", "zypper", cliArgs...)
+	fmt.Fprintf(dryRunOutput, "[dry-run] %s: package transaction for %q:\n%s\n", t.target.Target, result.Command, result.Stdout)
+	return result, err
 }
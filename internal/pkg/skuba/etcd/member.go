@@ -31,32 +31,90 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 )
 
-func RemoveMember(client clientset.Interface, node *v1.Node, clusterVersion *version.Version) error {
+// etcdctl runs etcdctl against the etcd member of the node it runs on, with
+// the certificates of the etcd health checks
+const etcdctl = "etcdctl --endpoints=https://[127.0.0.1]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key"
+
+// RemoveMember removes the etcd member of the node from the etcd cluster,
+// returning the number of members left. It fails when the node is not a
+// member, or when removing it would lose quorum.
+func RemoveMember(client clientset.Interface, node *v1.Node, clusterVersion *version.Version) (int, error) {
+	members, err := Status(client, clusterVersion)
+	if err != nil {
+		return 0, err
+	}
+	member, err := CheckRemoval(members, node.ObjectMeta.Name)
+	if err != nil {
+		return 0, err
+	}
+
 	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
 	if err != nil {
-		return errors.Wrap(err, "could not get the list of control plane nodes, aborting")
+		return 0, errors.Wrap(err, "could not get the list of control plane nodes, aborting")
 	}
 
-	// Remove etcd member if target is a control plane
-	klog.V(1).Info("removing etcd member from the etcd cluster")
+	klog.V(1).Infof("removing etcd member %x from the etcd cluster", member.ID)
+	err = errors.New("no other control plane node found")
 	for _, controlPlaneNode := range controlPlaneNodes.Items {
+		if controlPlaneNode.ObjectMeta.Name == node.ObjectMeta.Name {
+			continue
+		}
 		klog.V(1).Infof("trying to remove etcd member from control plane node %s", controlPlaneNode.ObjectMeta.Name)
-		if err := RemoveMemberFrom(client, node, &controlPlaneNode, clusterVersion); err == nil {
+		if err = RemoveMemberFrom(client, node, member.ID, &controlPlaneNode, clusterVersion); err == nil {
 			klog.V(1).Infof("etcd member for node %s removed from control plane node %s", node.ObjectMeta.Name, controlPlaneNode.ObjectMeta.Name)
 			break
-		} else {
-			klog.V(1).Infof("could not remove etcd member from control plane node %s", controlPlaneNode.ObjectMeta.Name)
+		}
+		klog.V(1).Infof("could not remove etcd member from control plane node %s: %s", controlPlaneNode.ObjectMeta.Name, err)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not remove etcd member %x", member.ID)
+	}
+
+	members, err = Status(client, clusterVersion)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not verify the etcd member removal")
+	}
+	for _, m := range members {
+		if m.ID == member.ID {
+			return 0, errors.Errorf("etcd member %x is still part of the etcd cluster", member.ID)
 		}
 	}
+	return len(members), nil
+}
 
-	return nil
+// CheckRemoval returns the etcd member of the node, failing when the node is
+// not a member, or when the members left would not be enough healthy members
+// to keep quorum
+func CheckRemoval(members []Member, nodeName string) (*Member, error) {
+	var member *Member
+	healthy := 0
+	for i := range members {
+		if members[i].Name == nodeName {
+			member = &members[i]
+		} else if members[i].Healthy {
+			healthy++
+		}
+	}
+	if member == nil {
+		return nil, errors.Errorf("node %s is not a member of the etcd cluster", nodeName)
+	}
+	left := len(members) - 1
+	if left == 0 {
+		return nil, errors.Errorf("could not remove the last etcd member %x", member.ID)
+	}
+	if quorum := left/2 + 1; healthy < quorum {
+		return nil, errors.Errorf("removing etcd member %x would lose quorum: %d healthy members would be left out of %d, %d are needed", member.ID, healthy, left, quorum)
+	}
+	return member, nil
 }
 
-func RemoveMemberFrom(client clientset.Interface, node, executorNode *v1.Node, clusterVersion *version.Version) error {
+// RemoveMemberFrom removes the etcd member of the node with the given ID from
+// the etcd cluster, through the executor node
+func RemoveMemberFrom(client clientset.Interface, node *v1.Node, memberID uint64, executorNode *v1.Node, clusterVersion *version.Version) error {
 	return kubernetes.CreateAndWaitForJob(
 		client,
 		removeMemberFromJobName(node, executorNode),
-		removeMemberFromJobSpec(node, executorNode, clusterVersion),
+		removeMemberFromJobSpec(node, memberID, executorNode, clusterVersion),
 		kubernetes.TimeoutWaitForJob,
 	)
 }
@@ -68,18 +126,28 @@ func removeMemberFromJobName(node, executorNode *v1.Node) string {
 	return fmt.Sprintf("caasp-remove-etcd-member-%.10s-from-%.10s", nodeName, executorNodeName)
 }
 
-func removeMemberFromJobSpec(node, executorNode *v1.Node, clusterVersion *version.Version) batchv1.JobSpec {
+func removeMemberFromJobSpec(node *v1.Node, memberID uint64, executorNode *v1.Node, clusterVersion *version.Version) batchv1.JobSpec {
+	return etcdctlJobSpec(
+		removeMemberFromJobName(node, executorNode),
+		executorNode,
+		clusterVersion,
+		fmt.Sprintf("%s member remove %x", etcdctl, memberID),
+	)
+}
+
+// etcdctlJobSpec returns the spec of a job running the etcdctl script on the
+// executor node, with access to the local etcd member
+func etcdctlJobSpec(name string, executorNode *v1.Node, clusterVersion *version.Version, script string) batchv1.JobSpec {
 	return batchv1.JobSpec{
 		Template: v1.PodTemplateSpec{
 			Spec: v1.PodSpec{
 				Containers: []v1.Container{
 					{
-						Name: removeMemberFromJobName(node, executorNode),
-						// FIXME: check that etcd member is part of the member list already
+						Name:  name,
 						Image: kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Etcd, clusterVersion),
 						Command: []string{
 							"/bin/sh", "-c",
-							fmt.Sprintf("etcdctl --endpoints=https://[127.0.0.1]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key member remove $(etcdctl --endpoints=https://[127.0.0.1]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key member list | grep ', %s,' | cut -d',' -f1)", node.ObjectMeta.Name),
+							script,
 						},
 						Env: []v1.EnvVar{
 							{
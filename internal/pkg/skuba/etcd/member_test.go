@@ -25,7 +25,6 @@ import (
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
-	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
@@ -123,14 +122,17 @@ apiServer:
 		},
 	}
 
+	statusJob := fmt.Sprintf("caasp-etcd-status-%.10s", fmt.Sprintf("%x", sha1.Sum([]byte(fakeMaster.ObjectMeta.Name))))
+
 	tests := []struct {
 		errExpected bool
 		errMessage  string
 		name        string
 	}{
 		{
-			name:        "should remove etcd member from etcd cluster",
-			errExpected: false,
+			name:        "should fail when the etcd members cannot be listed",
+			errExpected: true,
+			errMessage:  fmt.Sprintf("could not get the etcd status: jobs.batch %q already exists", statusJob),
 		},
 	}
 
@@ -143,16 +145,13 @@ apiServer:
 			//nolint:errcheck
 			clientset.AppsV1().DaemonSets(metav1.NamespaceSystem).Create(context.TODO(), fakeDaemonSet, metav1.CreateOptions{})
 
-			controlPlaneNodes, _ := kubernetes.GetControlPlaneNodes(clientset)
-			hashTarget := fmt.Sprintf("%x", sha1.Sum([]byte(fakeWorker.ObjectMeta.Name)))
-			hashExecutor := fmt.Sprintf("%x", sha1.Sum([]byte(controlPlaneNodes.Items[0].ObjectMeta.Name)))
-			job := fmt.Sprintf("caasp-remove-etcd-member-%.10s-from-%.10s", hashTarget, hashExecutor)
+			// the status job already exists, so that it fails right away
 			//nolint:errcheck
 			clientset.BatchV1().Jobs(metav1.NamespaceSystem).Create(
 				context.TODO(),
 				&batchv1.Job{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      job,
+						Name:      statusJob,
 						Namespace: metav1.NamespaceSystem,
 					},
 					Spec: fakeJobSpec,
@@ -162,7 +161,7 @@ apiServer:
 
 			controlPlaneComponentsVersion, _ := kubeadm.GetCurrentClusterVersion(clientset)
 
-			err := etcd.RemoveMember(clientset, &fakeWorker, controlPlaneComponentsVersion)
+			_, err := etcd.RemoveMember(clientset, &fakeWorker, controlPlaneComponentsVersion)
 			if tt.errExpected {
 				if err == nil {
 					t.Errorf("error expected on %s, but no error reported", tt.name)
@@ -181,3 +180,73 @@ apiServer:
 		})
 	}
 }
+
+func TestCheckRemoval(t *testing.T) {
+	member := func(id uint64, name string, healthy bool) etcd.Member {
+		return etcd.Member{ID: id, Name: name, Healthy: healthy}
+	}
+
+	tests := []struct {
+		name        string
+		members     []etcd.Member
+		node        string
+		errExpected bool
+		errMessage  string
+	}{
+		{
+			name:    "healthy cluster of three",
+			members: []etcd.Member{member(1, "master-0", true), member(2, "master-1", true), member(3, "master-2", true)},
+			node:    "master-2",
+		},
+		{
+			name:    "unhealthy member removed",
+			members: []etcd.Member{member(1, "master-0", true), member(2, "master-1", true), member(3, "master-2", false)},
+			node:    "master-2",
+		},
+		{
+			name:        "not a member",
+			members:     []etcd.Member{member(1, "master-0", true), member(2, "master-1", true)},
+			node:        "worker",
+			errExpected: true,
+			errMessage:  "node worker is not a member of the etcd cluster",
+		},
+		{
+			name:        "last member",
+			members:     []etcd.Member{member(1, "master-0", true)},
+			node:        "master-0",
+			errExpected: true,
+			errMessage:  "could not remove the last etcd member 1",
+		},
+		{
+			name:        "quorum lost",
+			members:     []etcd.Member{member(1, "master-0", true), member(2, "master-1", false), member(10, "master-2", true)},
+			node:        "master-2",
+			errExpected: true,
+			errMessage:  "removing etcd member a would lose quorum: 1 healthy members would be left out of 2, 2 are needed",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			removed, err := etcd.CheckRemoval(tt.members, tt.node)
+			if tt.errExpected {
+				if err == nil {
+					t.Errorf("error expected on %s, but no error reported", tt.name)
+					return
+				}
+				if err.Error() != tt.errMessage {
+					t.Errorf("returned error (%v) does not match the expected one (%v)", err.Error(), tt.errMessage)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err.Error())
+				return
+			}
+			if removed.Name != tt.node {
+				t.Errorf("expected the member of %s, got %s", tt.node, removed.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+// snapshotHostDir is the directory of the control plane nodes where the
+// snapshots are saved before being downloaded
+const snapshotHostDir = "/var/lib/etcd-snapshots"
+
+// Backup takes an etcd snapshot and downloads it into the etcd snapshots
+// directory of the cluster definition folder, returning the path of the
+// snapshot
+func Backup(client clientset.Interface, clusterVersion *version.Version) (string, error) {
+	_, config, err := kubernetes.GetAdminClientSetWithConfig()
+	if err != nil {
+		return "", errors.Wrap(err, "could not get the admin client configuration")
+	}
+	if err := os.MkdirAll(skuba.EtcdSnapshotDir(), 0700); err != nil {
+		return "", errors.Wrapf(err, "could not create directory %s", skuba.EtcdSnapshotDir())
+	}
+	file := skuba.EtcdSnapshotFile(time.Now())
+	if err := Snapshot(client, config, clusterVersion, file); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// Snapshot takes an etcd snapshot on the first control plane node able to
+// take it, and downloads it to file
+func Snapshot(client clientset.Interface, config *rest.Config, clusterVersion *version.Version, file string) error {
+	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
+	if err != nil {
+		return errors.Wrap(err, "could not get the list of control plane nodes, aborting")
+	}
+
+	klog.V(1).Info("taking an etcd snapshot")
+	err = errors.New("no control plane node found")
+	for _, controlPlaneNode := range controlPlaneNodes.Items {
+		klog.V(1).Infof("trying to take an etcd snapshot on control plane node %s", controlPlaneNode.ObjectMeta.Name)
+		if err = SnapshotFrom(client, config, &controlPlaneNode, clusterVersion, file); err == nil {
+			klog.V(1).Infof("etcd snapshot taken on control plane node %s", controlPlaneNode.ObjectMeta.Name)
+			return nil
+		}
+		klog.V(1).Infof("could not take an etcd snapshot on control plane node %s: %s", controlPlaneNode.ObjectMeta.Name, err)
+	}
+
+	return errors.Wrap(err, "could not take an etcd snapshot")
+}
+
+// SnapshotFrom takes an etcd snapshot on the executor node and downloads it to
+// file
+func SnapshotFrom(client clientset.Interface, config *rest.Config, executorNode *v1.Node, clusterVersion *version.Version, file string) error {
+	name := snapshotJobName(executorNode)
+	if err := kubernetes.CreateAndWaitForJob(client, name, snapshotJobSpec(executorNode, clusterVersion), kubernetes.TimeoutWaitForJob); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "could not create %s", file)
+	}
+	image := kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Etcd, clusterVersion)
+	err = kubernetes.CopyFromNode(client, config, executorNode, image, snapshotHostFile(name), true, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file)
+		return errors.Wrapf(err, "could not download the etcd snapshot from node %s", executorNode.ObjectMeta.Name)
+	}
+	return nil
+}
+
+func snapshotJobName(executorNode *v1.Node) string {
+	executorNodeName := fmt.Sprintf("%x", sha1.Sum([]byte(executorNode.ObjectMeta.Name)))
+
+	return fmt.Sprintf("caasp-etcd-snapshot-%.10s", executorNodeName)
+}
+
+func snapshotHostFile(name string) string {
+	return filepath.Join(snapshotHostDir, fmt.Sprintf("%s.db", name))
+}
+
+func snapshotJobSpec(executorNode *v1.Node, clusterVersion *version.Version) batchv1.JobSpec {
+	name := snapshotJobName(executorNode)
+	snapshotDir := kubernetes.HostMount("var-lib-etcd-snapshots", snapshotHostDir)
+	directoryOrCreate := v1.HostPathDirectoryOrCreate
+	snapshotDir.HostPath.Type = &directoryOrCreate
+
+	spec := etcdctlJobSpec(name, executorNode, clusterVersion, fmt.Sprintf("%s snapshot save %s", etcdctl, snapshotHostFile(name)))
+	podSpec := &spec.Template.Spec
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts,
+		kubernetes.VolumeMount("var-lib-etcd-snapshots", snapshotHostDir, kubernetes.VolumeMountReadWrite))
+	podSpec.Volumes = append(podSpec.Volumes, snapshotDir)
+	return spec
+}
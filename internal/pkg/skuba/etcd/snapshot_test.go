@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+func TestSnapshotJobSpec(t *testing.T) {
+	executorNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "master-0",
+		},
+	}
+	spec := snapshotJobSpec(executorNode, version.MustParseSemantic("v1.18.10"))
+	podSpec := spec.Template.Spec
+
+	if podSpec.NodeSelector["kubernetes.io/hostname"] != "master-0" {
+		t.Errorf("expected the job to run on master-0, got node selector %v", podSpec.NodeSelector)
+	}
+	command := strings.Join(podSpec.Containers[0].Command, " ")
+	expectedSave := "snapshot save " + snapshotHostFile(snapshotJobName(executorNode))
+	if !strings.HasSuffix(command, expectedSave) {
+		t.Errorf("expected command %q to end with %q", command, expectedSave)
+	}
+	if !strings.HasPrefix(snapshotHostFile(snapshotJobName(executorNode)), snapshotHostDir+"/") {
+		t.Errorf("expected the snapshot to be saved in %s", snapshotHostDir)
+	}
+
+	var snapshotDir *v1.Volume
+	for i, volume := range podSpec.Volumes {
+		if volume.HostPath != nil && volume.HostPath.Path == snapshotHostDir {
+			snapshotDir = &podSpec.Volumes[i]
+		}
+	}
+	if snapshotDir == nil {
+		t.Fatalf("expected %s to be mounted, got volumes %v", snapshotHostDir, podSpec.Volumes)
+	}
+	if snapshotDir.HostPath.Type == nil || *snapshotDir.HostPath.Type != v1.HostPathDirectoryOrCreate {
+		t.Errorf("expected %s to be created when missing", snapshotHostDir)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+// Member is the status of an etcd member
+type Member struct {
+	ID         uint64
+	Name       string
+	PeerURLs   []string
+	ClientURLs []string
+	// Leader, DBSize and RaftIndex are only known when the member answered
+	// the status request
+	Leader    bool
+	DBSize    int64
+	RaftIndex uint64
+	Healthy   bool
+	// Error is the reason the member is not healthy, if known
+	Error string
+}
+
+// statusOutput is the JSON emitted by the status job, holding the outputs of
+// etcdctl in JSON format, null when a command failed
+type statusOutput struct {
+	Members *struct {
+		Members []struct {
+			ID         uint64   `json:"ID"`
+			Name       string   `json:"name"`
+			PeerURLs   []string `json:"peerURLs"`
+			ClientURLs []string `json:"clientURLs"`
+		} `json:"members"`
+	} `json:"members"`
+	Status []struct {
+		Endpoint string `json:"Endpoint"`
+		Status   struct {
+			Header struct {
+				MemberID uint64 `json:"member_id"`
+			} `json:"header"`
+			DBSize    int64  `json:"dbSize"`
+			Leader    uint64 `json:"leader"`
+			RaftIndex uint64 `json:"raftIndex"`
+		} `json:"Status"`
+	} `json:"status"`
+	Health []struct {
+		Endpoint string `json:"endpoint"`
+		Health   bool   `json:"health"`
+		Error    string `json:"error"`
+	} `json:"health"`
+}
+
+// statusScript prints the member list, the status and the health of the
+// members as a single JSON object
+var statusScript = fmt.Sprintf(`run() { out=$(%s -w json "$@" 2>/dev/null); echo "${out:-null}"; }
+printf '{"members":%%s,"status":%%s,"health":%%s}\n' "$(run member list)" "$(run endpoint status --cluster)" "$(run endpoint health --cluster)"`, etcdctl)
+
+// Status returns the status of the members of the etcd cluster, as seen from
+// the first control plane node able to report it. It is replaced by the tests
+// of its callers, as the output of the status job cannot be faked.
+var Status = clusterStatus
+
+func clusterStatus(client clientset.Interface, clusterVersion *version.Version) ([]Member, error) {
+	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get the list of control plane nodes, aborting")
+	}
+
+	err = errors.New("no control plane node found")
+	for _, controlPlaneNode := range controlPlaneNodes.Items {
+		klog.V(1).Infof("trying to get the etcd status from control plane node %s", controlPlaneNode.ObjectMeta.Name)
+		var members []Member
+		if members, err = StatusFrom(client, &controlPlaneNode, clusterVersion); err == nil {
+			return members, nil
+		}
+		klog.V(1).Infof("could not get the etcd status from control plane node %s: %s", controlPlaneNode.ObjectMeta.Name, err)
+	}
+
+	return nil, errors.Wrap(err, "could not get the etcd status")
+}
+
+// StatusFrom returns the status of the members of the etcd cluster, as seen
+// from the executor node
+func StatusFrom(client clientset.Interface, executorNode *v1.Node, clusterVersion *version.Version) ([]Member, error) {
+	output, err := kubernetes.CreateAndWaitForJobOutput(
+		client,
+		statusJobName(executorNode),
+		statusJobSpec(executorNode, clusterVersion),
+		kubernetes.TimeoutWaitForJob,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(output)
+}
+
+func statusJobName(executorNode *v1.Node) string {
+	executorNodeName := fmt.Sprintf("%x", sha1.Sum([]byte(executorNode.ObjectMeta.Name)))
+
+	return fmt.Sprintf("caasp-etcd-status-%.10s", executorNodeName)
+}
+
+func statusJobSpec(executorNode *v1.Node, clusterVersion *version.Version) batchv1.JobSpec {
+	return etcdctlJobSpec(statusJobName(executorNode), executorNode, clusterVersion, statusScript)
+}
+
+// parseStatus returns the members described by the output of the status job,
+// sorted by name
+func parseStatus(output string) ([]Member, error) {
+	status := statusOutput{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &status); err != nil {
+		return nil, errors.Wrap(err, "could not parse the etcd status")
+	}
+	if status.Members == nil {
+		return nil, errors.New("could not list the etcd members")
+	}
+
+	members := []Member{}
+	for _, m := range status.Members.Members {
+		member := Member{
+			ID:         m.ID,
+			Name:       m.Name,
+			PeerURLs:   m.PeerURLs,
+			ClientURLs: m.ClientURLs,
+			Error:      "no status reported",
+		}
+		for _, s := range status.Status {
+			if s.Status.Header.MemberID != m.ID {
+				continue
+			}
+			member.Leader = s.Status.Leader == m.ID
+			member.DBSize = s.Status.DBSize
+			member.RaftIndex = s.Status.RaftIndex
+		}
+		for _, h := range status.Health {
+			for _, clientURL := range m.ClientURLs {
+				if h.Endpoint == clientURL {
+					member.Healthy = h.Health
+					member.Error = h.Error
+				}
+			}
+		}
+		if member.Healthy {
+			member.Error = ""
+		}
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		expected      []Member
+		errorExpected bool
+	}{
+		{
+			name: "healthy and unreachable members",
+			output: `{"members":{"header":{"cluster_id":14841639068965178418,"member_id":10276657743932975437,"raft_term":3},"members":[` +
+				`{"ID":10276657743932975437,"name":"master-1","peerURLs":["https://10.0.0.11:2380"],"clientURLs":["https://10.0.0.11:2379"]},` +
+				`{"ID":1609412215437412181,"name":"master-0","peerURLs":["https://10.0.0.10:2380"],"clientURLs":["https://10.0.0.10:2379"]}]},` +
+				`"status":[{"Endpoint":"https://10.0.0.11:2379","Status":{"header":{"cluster_id":14841639068965178418,"member_id":10276657743932975437,"revision":1200,"raft_term":3},"version":"3.4.13","dbSize":2461696,"leader":1609412215437412181,"raftIndex":1301,"raftTerm":3}},` +
+				`{"Endpoint":"https://10.0.0.10:2379","Status":{"header":{"cluster_id":14841639068965178418,"member_id":1609412215437412181,"revision":1200,"raft_term":3},"version":"3.4.13","dbSize":2465792,"leader":1609412215437412181,"raftIndex":1302,"raftTerm":3}}],` +
+				`"health":[{"endpoint":"https://10.0.0.10:2379","health":true,"took":"9.6ms"},{"endpoint":"https://10.0.0.11:2379","health":false,"took":"5s","error":"context deadline exceeded"}]}` + "\n",
+			expected: []Member{
+				{
+					ID:         1609412215437412181,
+					Name:       "master-0",
+					PeerURLs:   []string{"https://10.0.0.10:2380"},
+					ClientURLs: []string{"https://10.0.0.10:2379"},
+					Leader:     true,
+					DBSize:     2465792,
+					RaftIndex:  1302,
+					Healthy:    true,
+				},
+				{
+					ID:         10276657743932975437,
+					Name:       "master-1",
+					PeerURLs:   []string{"https://10.0.0.11:2380"},
+					ClientURLs: []string{"https://10.0.0.11:2379"},
+					DBSize:     2461696,
+					RaftIndex:  1301,
+					Error:      "context deadline exceeded",
+				},
+			},
+		},
+		{
+			name:   "status and health unavailable",
+			output: `{"members":{"members":[{"ID":1,"name":"master-0","peerURLs":["https://10.0.0.10:2380"],"clientURLs":["https://10.0.0.10:2379"]}]},"status":null,"health":null}`,
+			expected: []Member{
+				{
+					ID:         1,
+					Name:       "master-0",
+					PeerURLs:   []string{"https://10.0.0.10:2380"},
+					ClientURLs: []string{"https://10.0.0.10:2379"},
+					Error:      "no status reported",
+				},
+			},
+		},
+		{
+			name:          "member list unavailable",
+			output:        `{"members":null,"status":null,"health":null}`,
+			errorExpected: true,
+		},
+		{
+			name:          "invalid output",
+			output:        "Error: context deadline exceeded",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			members, err := parseStatus(tt.output)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			if !reflect.DeepEqual(members, tt.expected) {
+				t.Errorf("expected members %+v, got %+v", tt.expected, members)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package firewall describes how the host firewall of the nodes is handled.
+// The firewall configuration, firewall.yaml in the cluster definition
+// folder, either disables firewalld or opens the ports each role needs.
+package firewall
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// Mode is how firewalld is handled on the nodes
+type Mode string
+
+const (
+	// DisableMode stops and disables firewalld
+	DisableMode Mode = "disable"
+	// ConfigureMode opens the ports of the role of the node in firewalld
+	ConfigureMode Mode = "configure"
+
+	// ServiceName is the firewalld service holding the ports of the node
+	ServiceName = "skuba"
+	// DefaultNodePortRange is the range of the NodePort services when the
+	// API server does not set one
+	DefaultNodePortRange = "30000-32767"
+)
+
+var (
+	// ControlPlanePorts are the ports of the control plane components
+	ControlPlanePorts = []string{
+		"6443/tcp",      // API server
+		"2379-2380/tcp", // etcd clients and peers
+	}
+
+	// NodePorts are the ports of the components running on every node
+	NodePorts = []string{
+		"10250/tcp", // kubelet
+		"8472/udp",  // cilium VXLAN
+		"4240/tcp",  // cilium health
+	}
+
+	portRegexp = regexp.MustCompile(`^([0-9]+)(-([0-9]+))?/(tcp|udp|sctp)$`)
+	zoneRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// Configuration describes how firewalld is handled on the nodes
+type Configuration struct {
+	Mode Mode `json:"mode,omitempty"`
+	// Zone is the firewalld zone the ports are opened in, the default zone
+	// of the node when empty
+	Zone string `json:"zone,omitempty"`
+	// Ports are extra ports opened on every node, as port[-port]/protocol
+	Ports []string `json:"ports,omitempty"`
+}
+
+// Load reads the firewall configuration at path. Without it, firewalld is
+// disabled.
+func Load(path string) (*Configuration, error) {
+	configuration := &Configuration{Mode: DisableMode}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return configuration, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read firewall configuration %s", path)
+	}
+	if err := yaml.UnmarshalStrict(contents, configuration); err != nil {
+		return nil, errors.Wrapf(err, "could not parse firewall configuration %s", path)
+	}
+	if configuration.Mode == "" {
+		configuration.Mode = DisableMode
+	}
+	if err := configuration.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid firewall configuration %s", path)
+	}
+	return configuration, nil
+}
+
+// Validate checks the mode, the zone and the ports
+func (c *Configuration) Validate() error {
+	if c.Mode != DisableMode && c.Mode != ConfigureMode {
+		return errors.Errorf("invalid mode %q, %q or %q expected", c.Mode, DisableMode, ConfigureMode)
+	}
+	if c.Zone != "" && !zoneRegexp.MatchString(c.Zone) {
+		return errors.Errorf("invalid zone %q", c.Zone)
+	}
+	for _, port := range c.Ports {
+		if err := ValidatePort(port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// State returns the state handling firewalld in the mode
+func (c *Configuration) State() string {
+	if c.Mode == ConfigureMode {
+		return "firewalld.configure"
+	}
+	return "firewalld.disable"
+}
+
+// ValidatePort checks a port given as port[-port]/protocol
+func ValidatePort(port string) error {
+	matches := portRegexp.FindStringSubmatch(port)
+	if matches == nil {
+		return errors.Errorf("invalid port %q, expected port[-port]/protocol", port)
+	}
+	first, _ := strconv.Atoi(matches[1])
+	last := first
+	if matches[3] != "" {
+		last, _ = strconv.Atoi(matches[3])
+	}
+	if first < 1 || last > 65535 || first > last {
+		return errors.Errorf("invalid port range %q", port)
+	}
+	return nil
+}
+
+// RolePorts returns the ports opened on a node with the role, of every role
+// when nil, followed by the extra ports of the configuration
+func (c *Configuration) RolePorts(role *deployments.Role, nodePortRange string) []string {
+	ports := []string{}
+	if role == nil || *role == deployments.MasterRole {
+		ports = append(ports, ControlPlanePorts...)
+	}
+	ports = append(ports, NodePorts...)
+	if nodePortRange == "" {
+		nodePortRange = DefaultNodePortRange
+	}
+	ports = append(ports, nodePortRange+"/tcp", nodePortRange+"/udp")
+	return append(ports, c.Ports...)
+}
+
+type service struct {
+	XMLName     xml.Name      `xml:"service"`
+	Short       string        `xml:"short"`
+	Description string        `xml:"description"`
+	Ports       []servicePort `xml:"port"`
+}
+
+type servicePort struct {
+	Protocol string `xml:"protocol,attr"`
+	Port     string `xml:"port,attr"`
+}
+
+// ServiceFile returns the definition of the firewalld service opening the
+// ports
+func ServiceFile(ports []string) (string, error) {
+	s := service{
+		Short:       "skuba",
+		Description: "Ports of the kubernetes components deployed by skuba",
+	}
+	for _, port := range ports {
+		if err := ValidatePort(port); err != nil {
+			return "", err
+		}
+		parts := strings.SplitN(port, "/", 2)
+		s.Ports = append(s.Ports, servicePort{Protocol: parts[1], Port: parts[0]})
+	}
+	contents := bytes.NewBufferString(xml.Header)
+	encoder := xml.NewEncoder(contents)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(s); err != nil {
+		return "", err
+	}
+	contents.WriteString("\n")
+	return contents.String(), nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package firewall
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name          string
+		contents      string
+		expectedMode  Mode
+		expectedState string
+		errorExpected bool
+	}{
+		{
+			name:          "disable",
+			contents:      "mode: disable\n",
+			expectedMode:  DisableMode,
+			expectedState: "firewalld.disable",
+		},
+		{
+			name:          "configure",
+			contents:      "mode: configure\nzone: internal\nports:\n- 9100/tcp\n- 60000-61000/udp\n",
+			expectedMode:  ConfigureMode,
+			expectedState: "firewalld.configure",
+		},
+		{
+			name:          "mode not set",
+			contents:      "zone: internal\n",
+			expectedMode:  DisableMode,
+			expectedState: "firewalld.disable",
+		},
+		{
+			name:          "invalid mode",
+			contents:      "mode: enable\n",
+			errorExpected: true,
+		},
+		{
+			name:          "invalid zone",
+			contents:      "mode: configure\nzone: \"public; reboot\"\n",
+			errorExpected: true,
+		},
+		{
+			name:          "port without protocol",
+			contents:      "mode: configure\nports:\n- 9100\n",
+			errorExpected: true,
+		},
+		{
+			name:          "invalid port range",
+			contents:      "mode: configure\nports:\n- 9200-9100/tcp\n",
+			errorExpected: true,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "skuba-firewall")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "firewall.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.contents), 0600); err != nil {
+				t.Fatalf("could not write firewall configuration: %v", err)
+			}
+			configuration, err := Load(path)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			if configuration.Mode != tt.expectedMode {
+				t.Errorf("expected mode %q, got %q", tt.expectedMode, configuration.Mode)
+			}
+			if state := configuration.State(); state != tt.expectedState {
+				t.Errorf("expected state %q, got %q", tt.expectedState, state)
+			}
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	configuration, err := Load(filepath.Join(os.TempDir(), "skuba-missing-firewall.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if configuration.Mode != DisableMode {
+		t.Errorf("expected firewalld to be disabled, got mode %q", configuration.Mode)
+	}
+}
+
+func TestRolePorts(t *testing.T) {
+	master := deployments.MasterRole
+	worker := deployments.WorkerRole
+	configuration := Configuration{Mode: ConfigureMode, Ports: []string{"9100/tcp"}}
+
+	tests := []struct {
+		name          string
+		role          *deployments.Role
+		nodePortRange string
+		expectedPorts []string
+	}{
+		{
+			name:          "master",
+			role:          &master,
+			expectedPorts: []string{"6443/tcp", "2379-2380/tcp", "10250/tcp", "8472/udp", "4240/tcp", "30000-32767/tcp", "30000-32767/udp", "9100/tcp"},
+		},
+		{
+			name:          "worker with a custom NodePort range",
+			role:          &worker,
+			nodePortRange: "20000-22767",
+			expectedPorts: []string{"10250/tcp", "8472/udp", "4240/tcp", "20000-22767/tcp", "20000-22767/udp", "9100/tcp"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if ports := configuration.RolePorts(tt.role, tt.nodePortRange); !reflect.DeepEqual(ports, tt.expectedPorts) {
+				t.Errorf("expected ports %v, got %v", tt.expectedPorts, ports)
+			}
+		})
+	}
+}
+
+func TestServiceFile(t *testing.T) {
+	contents, err := ServiceFile([]string{"6443/tcp", "30000-32767/udp"})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	for _, expected := range []string{`<?xml version="1.0" encoding="UTF-8"?>`, `<port protocol="tcp" port="6443"></port>`, `<port protocol="udp" port="30000-32767"></port>`} {
+		if !strings.Contains(contents, expected) {
+			t.Errorf("expected service to contain %q, got:\n%s", expected, contents)
+		}
+	}
+	if _, err := ServiceFile([]string{"6443"}); err == nil {
+		t.Error("expected an error for a port without protocol")
+	}
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package inventory describes sets of nodes operated on at once, and runs
+// node operations on them concurrently. The cluster inventory, cluster.yaml in
+// the cluster definition folder, describes every node the cluster should have.
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/kubernetes/pkg/util/taints"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/pkg/skuba/actions/validate"
+)
+
+// SSH holds the parameters used to connect to nodes, overriding the ones of
+// the command line and of the ssh config when set
+type SSH struct {
+	// User is the user identity used to connect to the node
+	User string `json:"user,omitempty"`
+	// Port is the port to connect to
+	Port int `json:"port,omitempty"`
+	// Sudo runs the remote commands via sudo
+	Sudo bool `json:"sudo,omitempty"`
+}
+
+// Node is a node of the inventory
+type Node struct {
+	// Name is the name of the node in the cluster
+	Name string `json:"name"`
+	// Target is the IP or FQDN to connect to
+	Target string `json:"target"`
+	// Role is the role of the node in the cluster (master|worker)
+	Role string `json:"role"`
+	// SSH overrides the SSH parameters of the inventory for this node
+	SSH SSH `json:"ssh,omitempty"`
+	// Labels are the labels the node should have
+	Labels map[string]string `json:"labels,omitempty"`
+	// Taints are the taints the node should have, as key[=value]:effect
+	Taints []string `json:"taints,omitempty"`
+}
+
+// Inventory is a set of nodes
+type Inventory struct {
+	// SSH holds the SSH parameters shared by all the nodes
+	SSH   SSH    `json:"ssh,omitempty"`
+	Nodes []Node `json:"nodes"`
+}
+
+// Load reads and validates the inventory file
+func Load(path string) (*Inventory, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read inventory %s", path)
+	}
+	inventory := &Inventory{}
+	if err := yaml.UnmarshalStrict(contents, inventory); err != nil {
+		return nil, errors.Wrapf(err, "could not parse inventory %s", path)
+	}
+	if err := inventory.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid inventory %s", path)
+	}
+	return inventory, nil
+}
+
+// Validate checks that the nodes of the inventory are complete and unique
+func (i *Inventory) Validate() error {
+	if len(i.Nodes) == 0 {
+		return errors.New(`no nodes defined, list them under "nodes" in the inventory`)
+	}
+	names := map[string]bool{}
+	targets := map[string]bool{}
+	for _, node := range i.Nodes {
+		if err := validate.NodeName(node.Name); err != nil {
+			return err
+		}
+		if node.Target == "" {
+			return errors.Errorf("node %q has no target", node.Name)
+		}
+		if _, err := node.DeploymentRole(); err != nil {
+			return err
+		}
+		if names[node.Name] {
+			return errors.Errorf("node %q is defined more than once", node.Name)
+		}
+		if targets[node.Target] {
+			return errors.Errorf("target %q is defined more than once", node.Target)
+		}
+		if err := node.validateLabels(); err != nil {
+			return err
+		}
+		if _, err := node.ParsedTaints(); err != nil {
+			return err
+		}
+		names[node.Name] = true
+		targets[node.Target] = true
+	}
+	return nil
+}
+
+// ConnectionSSH returns the SSH parameters of the node, falling back to the
+// ones shared by all the nodes of the inventory
+func (i *Inventory) ConnectionSSH(node Node) SSH {
+	ssh := node.SSH
+	if ssh.User == "" {
+		ssh.User = i.SSH.User
+	}
+	if ssh.Port == 0 {
+		ssh.Port = i.SSH.Port
+	}
+	ssh.Sudo = ssh.Sudo || i.SSH.Sudo
+	return ssh
+}
+
+// Node returns the node of the inventory with the given name
+func (i *Inventory) Node(name string) (Node, bool) {
+	for _, node := range i.Nodes {
+		if node.Name == name {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
+
+func (n Node) validateLabels() error {
+	for key, value := range n.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return errors.Errorf("invalid label key %q for node %q: %s", key, n.Name, errs[0])
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return errors.Errorf("invalid label value %q for node %q: %s", value, n.Name, errs[0])
+		}
+	}
+	return nil
+}
+
+// ParsedTaints returns the taints of the node
+func (n Node) ParsedTaints() ([]v1.Taint, error) {
+	if len(n.Taints) == 0 {
+		return nil, nil
+	}
+	toAdd, toRemove, err := taints.ParseTaints(n.Taints)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid taints for node %q", n.Name)
+	}
+	if len(toRemove) > 0 {
+		return nil, errors.Errorf("invalid taints for node %q: taints cannot be removed from the inventory", n.Name)
+	}
+	return toAdd, nil
+}
+
+// DeploymentRole returns the role of the node
+func (n Node) DeploymentRole() (deployments.Role, error) {
+	switch n.Role {
+	case "master":
+		return deployments.MasterRole, nil
+	case "worker":
+		return deployments.WorkerRole, nil
+	}
+	return deployments.WorkerRole, errors.Errorf("invalid role %q for node %q, 'master' or 'worker' are the only accepted roles", n.Role, n.Name)
+}
+
+// IsControlPlane returns whether the node is a control plane node
+func (n Node) IsControlPlane() bool {
+	role, err := n.DeploymentRole()
+	return err == nil && role == deployments.MasterRole
+}
+
+// Result is the outcome of an operation on a node
+type Result struct {
+	Node     Node
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+// Run runs the operation on the nodes. Control plane nodes are handled first,
+// one at a time, as changing several etcd members at once could make etcd
+// lose quorum: the remaining ones are skipped after a failure. Workers are
+// then handled concurrently, at most parallel at a time. The results are in
+// the order of the nodes.
+func Run(nodes []Node, parallel int, operation func(Node) error) []Result {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]Result, len(nodes))
+	run := func(i int) {
+		start := time.Now()
+		err := operation(nodes[i])
+		results[i] = Result{Node: nodes[i], Err: err, Duration: time.Since(start)}
+	}
+
+	controlPlaneFailed := false
+	for i, node := range nodes {
+		if !node.IsControlPlane() {
+			continue
+		}
+		if controlPlaneFailed {
+			results[i] = Result{Node: node, Skipped: true}
+			continue
+		}
+		run(i)
+		controlPlaneFailed = results[i].Err != nil
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, parallel)
+	for i, node := range nodes {
+		if node.IsControlPlane() {
+			continue
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// PrintSummary prints the outcome of the operation on each node
+func PrintSummary(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tTARGET\tROLE\tRESULT\tDURATION")
+	for _, result := range results {
+		outcome := "success"
+		if result.Skipped {
+			outcome = "skipped"
+		} else if result.Err != nil {
+			outcome = fmt.Sprintf("failed: %s", result.Err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.Node.Name, result.Node.Target, result.Node.Role, outcome, result.Duration.Round(time.Second))
+	}
+	tw.Flush()
+}
+
+// Failed returns an error if the operation failed or was skipped on any node
+func Failed(results []Result) error {
+	failed := 0
+	for _, result := range results {
+		if result.Skipped || result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d out of %d nodes failed or were skipped", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package inventory
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name          string
+		contents      string
+		expectedNodes int
+		errorExpected bool
+		expectedError string
+	}{
+		{
+			name: "valid inventory",
+			contents: `
+nodes:
+- name: master-0
+  target: 10.0.0.10
+  role: master
+- name: worker-0
+  target: 10.0.0.20
+  role: worker
+`,
+			expectedNodes: 2,
+		},
+		{
+			name:          "no nodes",
+			contents:      "nodes: []",
+			errorExpected: true,
+			expectedError: `no nodes defined, list them under "nodes" in the inventory`,
+		},
+		{
+			name: "invalid role",
+			contents: `
+nodes:
+- name: worker-0
+  target: 10.0.0.20
+  role: etcd
+`,
+			errorExpected: true,
+		},
+		{
+			name: "missing target",
+			contents: `
+nodes:
+- name: worker-0
+  role: worker
+`,
+			errorExpected: true,
+		},
+		{
+			name: "duplicated node",
+			contents: `
+nodes:
+- name: worker-0
+  target: 10.0.0.20
+  role: worker
+- name: worker-0
+  target: 10.0.0.21
+  role: worker
+`,
+			errorExpected: true,
+		},
+		{
+			name: "unknown field",
+			contents: `
+nodes:
+- name: worker-0
+  target: 10.0.0.20
+  role: worker
+  port: 22
+`,
+			errorExpected: true,
+		},
+		{
+			name: "cluster inventory",
+			contents: `
+ssh:
+  user: sles
+  sudo: true
+nodes:
+- name: master-0
+  target: 10.0.0.10
+  role: master
+- name: worker-0
+  target: 10.0.0.20
+  role: worker
+  ssh:
+    user: root
+    port: 2222
+  labels:
+    example.com/storage: "true"
+  taints:
+  - example.com/storage=true:NoSchedule
+  - example.com/dedicated:PreferNoSchedule
+`,
+			expectedNodes: 2,
+		},
+		{
+			name: "invalid label",
+			contents: `
+nodes:
+- name: worker-0
+  target: 10.0.0.20
+  role: worker
+  labels:
+    example.com/storage: "not a value"
+`,
+			errorExpected: true,
+		},
+		{
+			name: "invalid taint",
+			contents: `
+nodes:
+- name: worker-0
+  target: 10.0.0.20
+  role: worker
+  taints:
+  - example.com/storage=true:Sometimes
+`,
+			errorExpected: true,
+		},
+		{
+			name: "taint removal",
+			contents: `
+nodes:
+- name: worker-0
+  target: 10.0.0.20
+  role: worker
+  taints:
+  - example.com/storage:NoSchedule-
+`,
+			errorExpected: true,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "skuba-inventory")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "nodes.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.contents), 0600); err != nil {
+				t.Fatalf("could not write inventory: %v", err)
+			}
+			inventory, err := Load(path)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an error but got none")
+				} else if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("expected error to contain %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			if len(inventory.Nodes) != tt.expectedNodes {
+				t.Errorf("expected %d nodes, got %d", tt.expectedNodes, len(inventory.Nodes))
+			}
+		})
+	}
+}
+
+func TestConnectionSSH(t *testing.T) {
+	inventory := &Inventory{SSH: SSH{User: "sles", Port: 22}}
+	tests := []struct {
+		name     string
+		node     SSH
+		expected SSH
+	}{
+		{
+			name:     "inventory defaults",
+			expected: SSH{User: "sles", Port: 22},
+		},
+		{
+			name:     "node overrides",
+			node:     SSH{User: "root", Port: 2222, Sudo: true},
+			expected: SSH{User: "root", Port: 2222, Sudo: true},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if ssh := inventory.ConnectionSSH(Node{Name: "worker-0", SSH: tt.node}); ssh != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, ssh)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	nodes := []Node{
+		{Name: "master-0", Target: "10.0.0.10", Role: "master"},
+		{Name: "worker-0", Target: "10.0.0.20", Role: "worker"},
+		{Name: "master-1", Target: "10.0.0.11", Role: "master"},
+		{Name: "worker-1", Target: "10.0.0.21", Role: "worker"},
+		{Name: "master-2", Target: "10.0.0.12", Role: "master"},
+		{Name: "worker-2", Target: "10.0.0.22", Role: "worker"},
+		{Name: "worker-3", Target: "10.0.0.23", Role: "worker"},
+	}
+
+	var mu sync.Mutex
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	order := []string{}
+	results := Run(nodes, 2, func(node Node) error {
+		mu.Lock()
+		running[node.Role]++
+		if running[node.Role] > maxRunning[node.Role] {
+			maxRunning[node.Role] = running[node.Role]
+		}
+		if running["master"] > 0 && running["worker"] > 0 {
+			t.Error("control planes and workers ran concurrently")
+		}
+		order = append(order, node.Name)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running[node.Role]--
+		mu.Unlock()
+		if node.Name == "master-1" || node.Name == "worker-1" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	if maxRunning["master"] != 1 {
+		t.Errorf("expected control planes to run one at a time, got %d", maxRunning["master"])
+	}
+	if maxRunning["worker"] != 2 {
+		t.Errorf("expected 2 workers to run concurrently, got %d", maxRunning["worker"])
+	}
+	if strings.Join(order[:2], ",") != "master-0,master-1" {
+		t.Errorf("expected control planes to run first, got %v", order)
+	}
+
+	expected := map[string]string{
+		"master-0": "success",
+		"master-1": "failed",
+		"master-2": "skipped",
+		"worker-0": "success",
+		"worker-1": "failed",
+		"worker-2": "success",
+		"worker-3": "success",
+	}
+	for i, result := range results {
+		if result.Node.Name != nodes[i].Name {
+			t.Errorf("expected result %d to be for %s, got %s", i, nodes[i].Name, result.Node.Name)
+		}
+		outcome := "success"
+		if result.Skipped {
+			outcome = "skipped"
+		} else if result.Err != nil {
+			outcome = "failed"
+		}
+		if outcome != expected[result.Node.Name] {
+			t.Errorf("expected %s to be %s, got %s", result.Node.Name, expected[result.Node.Name], outcome)
+		}
+	}
+
+	if err := Failed(results); err == nil || err.Error() != "3 out of 7 nodes failed or were skipped" {
+		t.Errorf("unexpected failure summary: %v", err)
+	}
+	summary := &bytes.Buffer{}
+	PrintSummary(summary, results)
+	if lines := strings.Count(summary.String(), "\n"); lines != len(nodes)+1 {
+		t.Errorf("expected %d summary lines, got %d:\n%s", len(nodes)+1, lines, summary)
+	}
+}
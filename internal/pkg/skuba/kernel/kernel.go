@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package kernel describes the kernel modules and parameters configured on
+// the nodes of the cluster. The kernel configuration, kernel.yaml in the
+// cluster definition folder, adds entries to the ones kubernetes requires.
+package kernel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// RequiredModules are the kernel modules loaded on every node
+	RequiredModules = []string{
+		"br_netfilter",
+		"vxlan",
+	}
+
+	// RequiredParameters are the kernel parameters set on every node
+	RequiredParameters = map[string]string{
+		"net.ipv4.ip_forward":                "1",
+		"net.ipv4.conf.all.forwarding":       "1",
+		"net.bridge.bridge-nf-call-iptables": "1",
+	}
+
+	moduleRegexp    = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	parameterRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)+$`)
+)
+
+// ParameterValue is the value of a kernel parameter, given either as a
+// string or as a number
+type ParameterValue string
+
+// UnmarshalJSON accepts strings as well as numbers
+func (v *ParameterValue) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*v = ParameterValue(value)
+		return nil
+	}
+	*v = ParameterValue(data)
+	return nil
+}
+
+// Configuration holds the kernel modules and parameters configured on the
+// nodes in addition to the required ones
+type Configuration struct {
+	Modules    []string                  `json:"modules,omitempty"`
+	Parameters map[string]ParameterValue `json:"parameters,omitempty"`
+}
+
+// Load reads the kernel configuration at path, an empty configuration is
+// returned if it does not exist
+func Load(path string) (*Configuration, error) {
+	configuration := &Configuration{}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return configuration, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read kernel configuration %s", path)
+	}
+	if err := yaml.UnmarshalStrict(contents, configuration); err != nil {
+		return nil, errors.Wrapf(err, "could not parse kernel configuration %s", path)
+	}
+	if err := configuration.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid kernel configuration %s", path)
+	}
+	return configuration, nil
+}
+
+// Validate checks the modules and parameters, which cannot change the
+// required parameters
+func (c *Configuration) Validate() error {
+	for _, module := range c.Modules {
+		if !moduleRegexp.MatchString(module) {
+			return errors.Errorf("invalid module name %q", module)
+		}
+	}
+	for parameter, value := range c.Parameters {
+		if !parameterRegexp.MatchString(parameter) {
+			return errors.Errorf("invalid parameter name %q", parameter)
+		}
+		if strings.ContainsAny(string(value), "\n\"'`$\\") {
+			return errors.Errorf("invalid value for parameter %s, values cannot contain new lines, quotes, backquotes, backslashes or dollar signs", parameter)
+		}
+		if required, found := RequiredParameters[parameter]; found && required != string(value) {
+			return errors.Errorf("parameter %s cannot be changed, kubernetes requires it to be %s", parameter, required)
+		}
+	}
+	return nil
+}
+
+// AllModules returns the required modules followed by the configured ones,
+// without duplicates
+func (c *Configuration) AllModules() []string {
+	modules := []string{}
+	seen := map[string]bool{}
+	for _, module := range append(append([]string{}, RequiredModules...), c.Modules...) {
+		if !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+	return modules
+}
+
+// AllParameters returns the required parameters and the configured ones
+func (c *Configuration) AllParameters() map[string]string {
+	parameters := map[string]string{}
+	for parameter, value := range RequiredParameters {
+		parameters[parameter] = value
+	}
+	for parameter, value := range c.Parameters {
+		parameters[parameter] = string(value)
+	}
+	return parameters
+}
+
+// ParameterNames returns the names of the parameters in order
+func ParameterNames(parameters map[string]string) []string {
+	names := []string{}
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ModuleFile returns the modules-load.d file loading the module at boot
+func ModuleFile(module string) string {
+	return fmt.Sprintf("/etc/modules-load.d/skuba-%s.conf", module)
+}
+
+// ParameterFile returns the sysctl.d file setting the parameter at boot
+func ParameterFile(parameter string) string {
+	return fmt.Sprintf("/etc/sysctl.d/90-skuba-%s.conf", strings.NewReplacer(".", "-", "_", "-").Replace(parameter))
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kernel
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name               string
+		contents           string
+		expectedModules    []string
+		expectedParameters map[string]string
+		errorExpected      bool
+	}{
+		{
+			name:               "scaffold",
+			contents:           "modules: []\nparameters: {}\n",
+			expectedModules:    RequiredModules,
+			expectedParameters: RequiredParameters,
+		},
+		{
+			name:            "extra modules and parameters",
+			contents:        "modules:\n- ip_vs\n- overlay\n- vxlan\nparameters:\n  net.netfilter.nf_conntrack_max: 1048576\n  net.ipv4.tcp_rmem: \"4096 87380 6291456\"\n  net.ipv4.ip_forward: 1\n",
+			expectedModules: []string{"br_netfilter", "vxlan", "ip_vs", "overlay"},
+			expectedParameters: map[string]string{
+				"net.ipv4.ip_forward":                "1",
+				"net.ipv4.conf.all.forwarding":       "1",
+				"net.bridge.bridge-nf-call-iptables": "1",
+				"net.netfilter.nf_conntrack_max":     "1048576",
+				"net.ipv4.tcp_rmem":                  "4096 87380 6291456",
+			},
+		},
+		{
+			name:          "unknown field",
+			contents:      "module:\n- ip_vs\n",
+			errorExpected: true,
+		},
+		{
+			name:          "invalid module",
+			contents:      "modules:\n- ip_vs; reboot\n",
+			errorExpected: true,
+		},
+		{
+			name:          "invalid parameter",
+			contents:      "parameters:\n  net/ipv4/ip_forward: 1\n",
+			errorExpected: true,
+		},
+		{
+			name:          "invalid parameter value",
+			contents:      "parameters:\n  kernel.core_pattern: \"$(reboot)\"\n",
+			errorExpected: true,
+		},
+		{
+			name:          "required parameter changed",
+			contents:      "parameters:\n  net.ipv4.ip_forward: 0\n",
+			errorExpected: true,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "skuba-kernel")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "kernel.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.contents), 0600); err != nil {
+				t.Fatalf("could not write kernel configuration: %v", err)
+			}
+			configuration, err := Load(path)
+			if tt.errorExpected {
+				if err == nil {
+					t.Error("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			if modules := configuration.AllModules(); !reflect.DeepEqual(modules, tt.expectedModules) {
+				t.Errorf("expected modules %v, got %v", tt.expectedModules, modules)
+			}
+			if parameters := configuration.AllParameters(); !reflect.DeepEqual(parameters, tt.expectedParameters) {
+				t.Errorf("expected parameters %v, got %v", tt.expectedParameters, parameters)
+			}
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	configuration, err := Load(filepath.Join(os.TempDir(), "skuba-missing-kernel.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if modules := configuration.AllModules(); !reflect.DeepEqual(modules, RequiredModules) {
+		t.Errorf("expected the required modules, got %v", modules)
+	}
+}
+
+func TestParameterFile(t *testing.T) {
+	if file := ParameterFile("net.ipv4.ip_forward"); file != "/etc/sysctl.d/90-skuba-net-ipv4-ip-forward.conf" {
+		t.Errorf("expected the file written by previous releases, got %s", file)
+	}
+}
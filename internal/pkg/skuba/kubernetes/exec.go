@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog"
+)
+
+// ExecInPod runs the command in the given container of a pod of the
+// kube-system namespace, writing its standard output to stdout
+func ExecInPod(client clientset.Interface, config *rest.Config, pod, container string, command []string, stdout io.Writer) error {
+	req := client.CoreV1().RESTClient().Post().Resource("pods").Name(pod).
+		Namespace(metav1.NamespaceSystem).SubResource("exec")
+	req.VersionedParams(
+		&v1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		},
+		scheme.ParameterCodec,
+	)
+	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	if err := exec.Stream(remotecommand.StreamOptions{Stdout: stdout, Stderr: &stderr}); err != nil {
+		return errors.Wrapf(err, "could not run %q in pod %s: %s", strings.Join(command, " "), pod, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// CopyFromNode writes the contents of a file of the node to w, and removes
+// the file from the node afterwards when remove is set. The file is read
+// from a short lived pod running the given image with the directory of the
+// file mounted.
+func CopyFromNode(client clientset.Interface, config *rest.Config, node *v1.Node, image, path string, remove bool, w io.Writer) error {
+	name := nodePodName("copy", node, path)
+	return runOnNode(client, name, node, image, []string{filepath.Dir(path)}, VolumeMountReadWrite, func() error {
+		if err := ExecInPod(client, config, name, name, []string{"cat", path}, w); err != nil {
+			return err
+		}
+		if remove {
+			if err := ExecInPod(client, config, name, name, []string{"rm", "-f", path}, ioutil.Discard); err != nil {
+				klog.Warningf("could not remove %s from node %s: %s", path, node.ObjectMeta.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ExecOnNode runs the command on the node, writing its standard output to
+// w. The command runs in a short lived pod running the given image with
+// the given directories of the node mounted read-only.
+func ExecOnNode(client clientset.Interface, config *rest.Config, node *v1.Node, image string, dirs, command []string, w io.Writer) error {
+	name := nodePodName("exec", node, strings.Join(command, " "))
+	return runOnNode(client, name, node, image, dirs, VolumeMountReadOnly, func() error {
+		return ExecInPod(client, config, name, name, command, w)
+	})
+}
+
+// runOnNode creates the pod with the given name on the node, calls run once
+// it is running and deletes it afterwards
+func runOnNode(client clientset.Interface, name string, node *v1.Node, image string, dirs []string, mode VolumeMountMode, run func() error) error {
+	if _, err := client.CoreV1().Pods(metav1.NamespaceSystem).Create(context.TODO(), nodePod(name, node, image, dirs, mode), metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "could not create pod %s", name)
+	}
+	defer func() {
+		if err := client.CoreV1().Pods(metav1.NamespaceSystem).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil {
+			fmt.Printf("error deleting pod %s\n", name)
+		}
+	}()
+	if err := waitForPodRunning(client, name, TimeoutWaitForJob); err != nil {
+		return err
+	}
+	return run()
+}
+
+func nodePodName(action string, node *v1.Node, subject string) string {
+	nodeName := fmt.Sprintf("%x", sha1.Sum([]byte(node.ObjectMeta.Name)))
+	subjectName := fmt.Sprintf("%x", sha1.Sum([]byte(subject)))
+
+	return fmt.Sprintf("caasp-%s-%.10s-on-%.10s", action, subjectName, nodeName)
+}
+
+func nodePod(name string, node *v1.Node, image string, dirs []string, mode VolumeMountMode) *v1.Pod {
+	var volumeMounts []v1.VolumeMount
+	var volumes []v1.Volume
+	for i, dir := range dirs {
+		volumeName := fmt.Sprintf("host-dir-%d", i)
+		volumeMounts = append(volumeMounts, VolumeMount(volumeName, dir, mode))
+		volumes = append(volumes, HostMount(volumeName, dir))
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: metav1.NamespaceSystem,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:         name,
+					Image:        image,
+					Command:      []string{"/bin/sh", "-c", "sleep 3600"},
+					VolumeMounts: volumeMounts,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			Volumes:       volumes,
+			NodeSelector: map[string]string{
+				"kubernetes.io/hostname": node.ObjectMeta.Name,
+			},
+			Tolerations: []v1.Toleration{
+				{
+					Operator: v1.TolerationOpExists,
+				},
+			},
+		},
+	}
+}
+
+// waitForPodRunning waits until the pod of the kube-system namespace is
+// running, for at most timeout seconds
+func waitForPodRunning(client clientset.Interface, name string, timeout int) error {
+	for i := 0; i < timeout; i++ {
+		pod, err := client.CoreV1().Pods(metav1.NamespaceSystem).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			klog.V(1).Infof("failed to get status for pod %s, continuing...", name)
+		} else {
+			switch pod.Status.Phase {
+			case v1.PodRunning:
+				return nil
+			case v1.PodSucceeded, v1.PodFailed:
+				return errors.Errorf("pod %s is not running anymore: %s", name, pod.Status.Phase)
+			}
+			klog.V(3).Infof("pod %s is %s, waiting...", name, pod.Status.Phase)
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return errors.Errorf("failed waiting for pod %s", name)
+}
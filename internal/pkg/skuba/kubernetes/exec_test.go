@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kubernetes
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWaitForPodRunning(t *testing.T) {
+	tests := []struct {
+		name          string
+		phase         v1.PodPhase
+		errorExpected bool
+	}{
+		{
+			name:  "running pod",
+			phase: v1.PodRunning,
+		},
+		{
+			name:          "failed pod",
+			phase:         v1.PodFailed,
+			errorExpected: true,
+		},
+		{
+			name:          "pending pod",
+			phase:         v1.PodPending,
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "copy",
+					Namespace: metav1.NamespaceSystem,
+				},
+				Status: v1.PodStatus{Phase: tt.phase},
+			})
+			err := waitForPodRunning(client, "copy", 1)
+			if tt.errorExpected && err == nil {
+				t.Errorf("error expected on %s, but no error reported", tt.name)
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
@@ -65,6 +66,45 @@ func CreateAndWaitForJob(client clientset.Interface, name string, spec batchv1.J
 			fmt.Printf("error deleting job %s\n", name)
 		}
 	}()
+	return waitForJob(client, name, timeout)
+}
+
+// CreateAndWaitForJobOutput creates job, waits until it succeeds and returns
+// the logs of its succeeded pod
+func CreateAndWaitForJobOutput(client clientset.Interface, name string, spec batchv1.JobSpec, timeout int) (string, error) {
+	_, err := CreateJob(client, name, spec)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := DeleteJob(client, name); err != nil {
+			fmt.Printf("error deleting job %s\n", name)
+		}
+	}()
+	if err := waitForJob(client, name, timeout); err != nil {
+		return "", err
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceSystem).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", name),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "could not list the pods of job %s", name)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodSucceeded {
+			continue
+		}
+		logs, err := client.CoreV1().Pods(metav1.NamespaceSystem).GetLogs(pod.Name, &v1.PodLogOptions{}).DoRaw(context.TODO())
+		if err != nil {
+			return "", errors.Wrapf(err, "could not get the logs of pod %s", pod.Name)
+		}
+		return string(logs), nil
+	}
+	return "", errors.Errorf("could not find a succeeded pod of job %s", name)
+}
+
+// waitForJob waits until the job succeeds, for at most timeout seconds
+func waitForJob(client clientset.Interface, name string, timeout int) error {
 	for i := 0; i < timeout; i++ {
 		job, err := client.BatchV1().Jobs(metav1.NamespaceSystem).Get(context.TODO(), name, metav1.GetOptions{})
 
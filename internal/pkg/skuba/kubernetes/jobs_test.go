@@ -263,3 +263,45 @@ func TestCreateAndWaitForJob(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateAndWaitForJobOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		pods         []runtime.Object
+		expectErrMsg string
+	}{
+		{
+			name: "no succeeded pod",
+			pods: []runtime.Object{
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-failed", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"job-name": "test"}},
+					Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+				},
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"job-name": "other"}},
+					Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+				},
+			},
+			expectErrMsg: "could not find a succeeded pod of job test",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClientset := fake.NewSimpleClientset(tt.pods...)
+			fakeClientset.PrependReactor("get", "jobs", func(action ktest.Action) (bool, runtime.Object, error) {
+				return true, &batchv1.Job{Status: batchv1.JobStatus{Succeeded: 1}}, nil
+			})
+
+			_, err := CreateAndWaitForJobOutput(fakeClientset, "test", batchv1.JobSpec{}, 5)
+			if err == nil {
+				t.Errorf("error expected on %s, but no error reported", tt.name)
+				return
+			}
+			if err.Error() != tt.expectErrMsg {
+				t.Errorf("returned error (%v) does not match the expected one (%v)", err.Error(), tt.expectErrMsg)
+			}
+		})
+	}
+}
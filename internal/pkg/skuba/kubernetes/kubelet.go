@@ -20,6 +20,7 @@ package kubernetes
 import (
 	"crypto/sha1"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -54,6 +55,13 @@ const (
 	KubeletServerKeyName = "kubelet.key"
 )
 
+// KubeletRootCertExists returns whether the kubelet root CA certificate has
+// been generated, or imported, in the pki folder
+func KubeletRootCertExists() bool {
+	_, err := os.Stat(filepath.Join(skuba.PkiDir(), KubeletCACertName))
+	return err == nil
+}
+
 // GenerateKubeletRootCert generates kubelet root CA certificate and key
 // and save the generated file locally.
 func GenerateKubeletRootCert() error {
@@ -63,6 +71,12 @@ func GenerateKubeletRootCert() error {
 		klog.V(1).Info("kubelet root ca cert and key already exists")
 		return nil
 	}
+	if KubeletCAKeyMissing() {
+		// an external CA was imported without its key, the kubelet server
+		// certificates are signed by the external PKI
+		klog.V(1).Info("kubelet root ca cert already exists without key")
+		return nil
+	}
 
 	certCfg := certutil.Config{
 		CommonName: "kubelet-ca",
@@ -82,6 +96,25 @@ func GenerateKubeletRootCert() error {
 	return nil
 }
 
+// ExternalKubeletServerCertAndKeyBaseName returns the base name of the
+// certificate, key and CSR files of the kubelet server certificate of the node
+// signed by the external CA, in the pki folder
+func ExternalKubeletServerCertAndKeyBaseName(node string) string {
+	return fmt.Sprintf("%s-%s", KubeletServerCertAndKeyBaseName, node)
+}
+
+// KubeletCAKeyMissing returns whether the kubelet CA certificate of the
+// cluster definition comes without its key, as when an external CA is imported
+// without key. The kubelet server certificates cannot be signed in the cluster
+// then, they are signed by the external PKI.
+func KubeletCAKeyMissing() bool {
+	if _, err := os.Stat(filepath.Join(skuba.PkiDir(), KubeletCACertName)); err != nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(skuba.PkiDir(), KubeletCAKeyName))
+	return os.IsNotExist(err)
+}
+
 func DisarmKubelet(client clientset.Interface, node *v1.Node, clusterVersion *version.Version) error {
 	return CreateAndWaitForJob(
 		client,
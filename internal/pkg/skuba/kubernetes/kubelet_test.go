@@ -18,9 +18,12 @@
 package kubernetes
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -49,6 +52,32 @@ func TestGenerateKubeletRootCert(t *testing.T) {
 			t.Errorf("error not expected, but error reported when removing %v: %v", pkiDir, err)
 		}
 	})
+
+	t.Run("keep kubelet root cert imported without key", func(t *testing.T) {
+		defer os.RemoveAll(skuba.PkiDir())
+		if err := GenerateKubeletRootCert(); err != nil {
+			t.Fatalf("error not expected, but error reported generating pki: %v", err)
+		}
+		caKeyFilePath := filepath.Join(skuba.PkiDir(), KubeletCAKeyName)
+		if err := os.Remove(caKeyFilePath); err != nil {
+			t.Fatalf("error not expected, but error reported removing %v: %v", caKeyFilePath, err)
+		}
+		caCertFilePath := filepath.Join(skuba.PkiDir(), KubeletCACertName)
+		caCert, err := ioutil.ReadFile(caCertFilePath)
+		if err != nil {
+			t.Fatalf("error not expected, but error reported reading %v: %v", caCertFilePath, err)
+		}
+
+		if err := GenerateKubeletRootCert(); err != nil {
+			t.Errorf("error not expected, but error reported when the cert exists without key: %v", err)
+		}
+		if current, _ := ioutil.ReadFile(caCertFilePath); !bytes.Equal(current, caCert) {
+			t.Error("expected the kubelet root cert to be kept")
+		}
+		if _, err := os.Stat(caKeyFilePath); !os.IsNotExist(err) {
+			t.Errorf("expected no kubelet root key to be generated, got %v", err)
+		}
+	})
 }
 
 func TestDisarmKubelet(t *testing.T) {
@@ -107,7 +107,7 @@ var (
 				Dex:           &AddonVersion{"2.23.0-rev3", 4540},
 				Gangway:       &AddonVersion{"3.1.0-rev7", 4540},
 				MetricsServer: &AddonVersion{"0.3.6-rev3", 4540},
-				Kucero:        &AddonVersion{"1.3.0-rev6", 4540},
+				Kucero:        &AddonVersion{"1.3.0-rev6", 4541},
 				PSP:           &AddonVersion{"", 4540},
 			},
 		},
@@ -132,7 +132,7 @@ var (
 				Dex:           &AddonVersion{"2.23.0-rev3", 4540},
 				Gangway:       &AddonVersion{"3.1.0-rev7", 4540},
 				MetricsServer: &AddonVersion{"0.3.6-rev3", 4540},
-				Kucero:        &AddonVersion{"1.3.0-rev6", 4540},
+				Kucero:        &AddonVersion{"1.3.0-rev6", 4541},
 				PSP:           &AddonVersion{"", 4540},
 			},
 		},
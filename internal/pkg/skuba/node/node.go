@@ -34,11 +34,11 @@ func AddTargetInformationToInitConfigurationWithClusterVersion(target *deploymen
 	initConfiguration.NodeRegistration.CRISocket = skuba.CRISocket
 	initConfiguration.NodeRegistration.KubeletExtraArgs["hostname-override"] = target.Nodename
 	initConfiguration.NodeRegistration.KubeletExtraArgs["pod-infra-container-image"] = kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, clusterVersion)
-	isSUSE, err := target.IsSUSEOS()
+	profile, err := target.OSProfile()
 	if err != nil {
 		return err
 	}
-	if isSUSE {
+	if profile.SUSECNIDir {
 		initConfiguration.NodeRegistration.KubeletExtraArgs["cni-bin-dir"] = skuba.SUSECNIDir
 	}
 	return nil
@@ -36,8 +36,8 @@ import (
 const (
 	// caCertAndKeyBaseFileName defines OIDC's CA certificate and key base file name
 	caCertAndKeyBaseFileName = "oidc-ca"
-	// caKeyFileName defines OIDC's CA key file name
-	caKeyFileName = "oidc-ca.key"
+	// CAKeyFileName defines OIDC's CA key file name
+	CAKeyFileName = "oidc-ca.key"
 	// CACertFileName defines OIDC's CA certificate file name
 	CACertFileName = "oidc-ca.crt"
 
@@ -57,7 +57,7 @@ func IsCACertAndKeyExist() (bool, bool) {
 		certExist = true
 	}
 
-	key := filepath.Join(skuba.PkiDir(), caKeyFileName)
+	key := filepath.Join(skuba.PkiDir(), CAKeyFileName)
 	f, err = os.Stat(key)
 	if !os.IsNotExist(err) && !f.IsDir() {
 		keyExist = true
@@ -383,7 +383,7 @@ func TestIsCACertAndKeyExist(t *testing.T) {
 		return
 	}
 
-	setupCertAndKey(t, &clusterCACert, &clusterCAKey, CACertFileName, caKeyFileName)
+	setupCertAndKey(t, &clusterCACert, &clusterCAKey, CACertFileName, CAKeyFileName)
 	defer teardownCertAndKey(t)
 
 	gotCert, gotKey = IsCACertAndKeyExist()
@@ -436,7 +436,7 @@ func TestTryToUseLocalServerCert(t *testing.T) {
 				return
 			}
 
-			setupCertAndKey(t, &customOIDCCACert, &customOIDCCAKey, CACertFileName, caKeyFileName)
+			setupCertAndKey(t, &customOIDCCACert, &customOIDCCAKey, CACertFileName, CAKeyFileName)
 			if err := TryToUseLocalServerCert(client, tt.localServerFileName, tt.certSecretName); err != nil {
 				t.Errorf("expected no error but an error reported: %v", err)
 				return
@@ -494,14 +494,14 @@ func TestSignServerWithLocalCACertAndKey(t *testing.T) {
 				return
 			}
 
-			setupCertAndKey(t, &customOIDCCACert, nil, CACertFileName, caKeyFileName)
+			setupCertAndKey(t, &customOIDCCACert, nil, CACertFileName, CAKeyFileName)
 			if err := SignServerWithLocalCACertAndKey(client, tt.certCN, tt.controlPlaneHost, tt.certSecretName); err == nil {
 				t.Error("expected got err but not error reported")
 				return
 			}
 			teardownCertAndKey(t)
 
-			setupCertAndKey(t, &customOIDCCACert, &customOIDCCAKey, CACertFileName, caKeyFileName)
+			setupCertAndKey(t, &customOIDCCACert, &customOIDCCAKey, CACertFileName, CAKeyFileName)
 			if err := SignServerWithLocalCACertAndKey(client, tt.certCN, tt.controlPlaneHost, tt.certSecretName); err != nil {
 				t.Errorf("expected no error but an error reported: %v", err)
 				return
@@ -18,9 +18,11 @@
 package util
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/x509"
 	"net"
+	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
@@ -119,3 +121,58 @@ func CreateOrUpdateCertToSecret(
 
 	return nil
 }
+
+// ValidateCAChain checks that the first certificate of the chain is a CA
+// allowed to sign certificates, that each certificate is signed by the next
+// one and that all of them are valid at the given time. The chain does not
+// have to end with a root CA. When a key is given, it has to be the key of
+// the first certificate.
+func ValidateCAChain(chain []*x509.Certificate, key crypto.Signer, now time.Time) error {
+	if len(chain) == 0 {
+		return errors.New("no certificate found")
+	}
+
+	ca := chain[0]
+	if !ca.BasicConstraintsValid || !ca.IsCA {
+		return errors.Errorf("certificate %q is not a CA", ca.Subject.CommonName)
+	}
+	if ca.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.Errorf("certificate %q is not allowed to sign certificates", ca.Subject.CommonName)
+	}
+
+	for i, cert := range chain {
+		if now.Before(cert.NotBefore) {
+			return errors.Errorf("certificate %q is not valid before %s", cert.Subject.CommonName, cert.NotBefore)
+		}
+		if now.After(cert.NotAfter) {
+			return errors.Errorf("certificate %q expired on %s", cert.Subject.CommonName, cert.NotAfter)
+		}
+		if i+1 < len(chain) {
+			if err := cert.CheckSignatureFrom(chain[i+1]); err != nil {
+				return errors.Wrapf(err, "certificate %q is not signed by %q", cert.Subject.CommonName, chain[i+1].Subject.CommonName)
+			}
+		}
+	}
+
+	if key != nil {
+		return CheckPublicKeyMatch(ca, key)
+	}
+	return nil
+}
+
+// CheckPublicKeyMatch checks that the private key is the key of the
+// certificate
+func CheckPublicKeyMatch(cert *x509.Certificate, key crypto.Signer) error {
+	certPublicKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return errors.Wrapf(err, "could not read the public key of certificate %q", cert.Subject.CommonName)
+	}
+	keyPublicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return errors.Wrap(err, "could not read the public key of the private key")
+	}
+	if !bytes.Equal(certPublicKey, keyPublicKey) {
+		return errors.Errorf("the private key does not match certificate %q", cert.Subject.CommonName)
+	}
+	return nil
+}
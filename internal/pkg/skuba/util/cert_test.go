@@ -19,9 +19,13 @@ package util
 
 import (
 	"crypto"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"net"
 	"testing"
+	"time"
 
 	"k8s.io/client-go/kubernetes/fake"
 	certutil "k8s.io/client-go/util/cert"
@@ -261,3 +265,108 @@ func TestCreateOrUpdateCertToSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCAChain(t *testing.T) {
+	rootCert, rootKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "corporate-root"}})
+	if err != nil {
+		t.Fatalf("generate root CA failed: %v", err)
+	}
+	otherRootCert, _, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "other-root"}})
+	if err != nil {
+		t.Fatalf("generate root CA failed: %v", err)
+	}
+	// the roots are valid from their creation time, truncated to the second
+	now := time.Now()
+	newCert := func(commonName string, isCA bool, keyUsage x509.KeyUsage, notAfter time.Time) (*x509.Certificate, crypto.Signer) {
+		key, err := pkiutil.NewPrivateKey(x509.RSA)
+		if err != nil {
+			t.Fatalf("generate key failed: %v", err)
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(2),
+			Subject:               pkix.Name{CommonName: commonName},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              notAfter,
+			KeyUsage:              keyUsage,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, rootCert, key.Public(), rootKey)
+		if err != nil {
+			t.Fatalf("sign %s failed: %v", commonName, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("parse %s failed: %v", commonName, err)
+		}
+		return cert, key
+	}
+	intermediateCert, intermediateKey := newCert("kubernetes-intermediate", true, x509.KeyUsageCertSign|x509.KeyUsageDigitalSignature, now.AddDate(1, 0, 0))
+	leafCert, _ := newCert("not-a-ca", false, x509.KeyUsageDigitalSignature, now.AddDate(1, 0, 0))
+	noCertSignCert, _ := newCert("no-cert-sign", true, x509.KeyUsageDigitalSignature, now.AddDate(1, 0, 0))
+	expiredCert, _ := newCert("expired", true, x509.KeyUsageCertSign, now.Add(-time.Minute))
+
+	tests := []struct {
+		name          string
+		chain         []*x509.Certificate
+		key           crypto.Signer
+		expectedError bool
+	}{
+		{
+			name:  "intermediate with its key",
+			chain: []*x509.Certificate{intermediateCert, rootCert},
+			key:   intermediateKey,
+		},
+		{
+			name:  "intermediate without key",
+			chain: []*x509.Certificate{intermediateCert},
+		},
+		{
+			name:  "self signed root",
+			chain: []*x509.Certificate{rootCert},
+			key:   rootKey,
+		},
+		{
+			name:          "empty chain",
+			expectedError: true,
+		},
+		{
+			name:          "not a CA",
+			chain:         []*x509.Certificate{leafCert, rootCert},
+			expectedError: true,
+		},
+		{
+			name:          "CA not allowed to sign certificates",
+			chain:         []*x509.Certificate{noCertSignCert, rootCert},
+			expectedError: true,
+		},
+		{
+			name:          "expired CA",
+			chain:         []*x509.Certificate{expiredCert, rootCert},
+			expectedError: true,
+		},
+		{
+			name:          "broken chain",
+			chain:         []*x509.Certificate{intermediateCert, otherRootCert},
+			expectedError: true,
+		},
+		{
+			name:          "key of another certificate",
+			chain:         []*x509.Certificate{intermediateCert, rootCert},
+			key:           rootKey,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCAChain(tt.chain, tt.key, now)
+			if tt.expectedError && err == nil {
+				t.Errorf("error expected on %s, but no error reported", tt.name)
+			} else if !tt.expectedError && err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err)
+			}
+		})
+	}
+}
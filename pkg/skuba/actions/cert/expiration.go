@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+const (
+	// fileHeaderPrefix and fileHeaderSuffix surround the path of each file
+	// listed on the nodes, printed before its contents
+	fileHeaderPrefix = "==> "
+	fileHeaderSuffix = " <=="
+	// listCertificatesScript prints the certificates and kubeconfig files of
+	// the node, each one preceded by its header
+	listCertificatesScript = `for f in /etc/kubernetes/pki/*.crt /etc/kubernetes/pki/etcd/*.crt /etc/kubernetes/*.conf /var/lib/kubelet/pki/*.crt /var/lib/kubelet/pki/kubelet-client-current.pem; do
+  if [ -f "$f" ]; then echo "==> $f <=="; cat "$f"; fi
+done`
+)
+
+// certificateDirs are the directories of the nodes holding certificates
+var certificateDirs = []string{"/etc/kubernetes", kubernetes.KubeletCertAndKeyDir}
+
+// Certificate is a certificate of the cluster
+type Certificate struct {
+	// Location is where the certificate is stored, a file of a node or a
+	// secret
+	Location   string
+	CommonName string
+	NotAfter   time.Time
+	IsCA       bool
+}
+
+// CheckExpiration prints the expiration date of the certificates of every
+// node of the cluster, read from a short lived pod on each node, and of the
+// OIDC server certificates
+func CheckExpiration(client clientset.Interface, config *rest.Config, w io.Writer) error {
+	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the current cluster version")
+	}
+	nodes, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "could not list the nodes")
+	}
+
+	// kube-proxy runs on every node, its image is already present
+	image := kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Proxy, currentClusterVersion)
+	var certificates []Certificate
+	failed := 0
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		var output bytes.Buffer
+		if err := kubernetes.ExecOnNode(client, config, node, image, certificateDirs, []string{"/bin/sh", "-c", listCertificatesScript}, &output); err != nil {
+			klog.Warningf("could not read the certificates of node %s: %s", node.ObjectMeta.Name, err)
+			failed++
+			continue
+		}
+		certificates = append(certificates, parseNodeCertificates(node.ObjectMeta.Name, output.String())...)
+	}
+
+	for _, certificate := range oidcCertificates {
+		secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(context.TODO(), certificate.secretName, metav1.GetOptions{})
+		if err != nil {
+			klog.V(1).Infof("could not get secret %s: %s", certificate.secretName, err)
+			continue
+		}
+		certs, err := certutil.ParseCertsPEM(secret.Data[v1.TLSCertKey])
+		if err != nil {
+			klog.Warningf("could not parse the certificate of secret %s: %s", certificate.secretName, err)
+			continue
+		}
+		certificates = append(certificates, newCertificate(fmt.Sprintf("secret %s/%s", metav1.NamespaceSystem, certificate.secretName), certs[0]))
+	}
+
+	PrintExpiration(w, certificates, time.Now())
+	if failed > 0 {
+		return errors.Errorf("could not read the certificates of %d out of %d nodes", failed, len(nodes.Items))
+	}
+	return nil
+}
+
+// PrintExpiration prints the certificates sorted by location, with the time
+// left before they expire
+func PrintExpiration(w io.Writer, certificates []Certificate, now time.Time) {
+	sort.Slice(certificates, func(i, j int) bool {
+		return certificates[i].Location < certificates[j].Location
+	})
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "CERTIFICATE\tCOMMON-NAME\tEXPIRES\tRESIDUAL-TIME\tCA")
+	for _, certificate := range certificates {
+		residual := "expired"
+		if certificate.NotAfter.After(now) {
+			residual = duration.ShortHumanDuration(certificate.NotAfter.Sub(now))
+		}
+		ca := "no"
+		if certificate.IsCA {
+			ca = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", certificate.Location, certificate.CommonName, certificate.NotAfter.UTC().Format("Jan 02, 2006 15:04 MST"), residual, ca)
+	}
+	tw.Flush()
+}
+
+// parseNodeCertificates parses the output of listCertificatesScript on the
+// node. Kubeconfig files hold the certificates of their users, the other
+// files hold PEM encoded certificates of which the first one is reported.
+func parseNodeCertificates(nodeName, output string) []Certificate {
+	var certificates []Certificate
+	add := func(path string, contents []byte) {
+		if path == "" {
+			return
+		}
+		location := fmt.Sprintf("%s:%s", nodeName, path)
+		if filepath.Ext(path) != ".conf" {
+			certs, err := certutil.ParseCertsPEM(contents)
+			if err != nil {
+				klog.V(1).Infof("could not parse %s: %s", location, err)
+				return
+			}
+			certificates = append(certificates, newCertificate(location, certs[0]))
+			return
+		}
+		kubeconfig, err := clientcmd.Load(contents)
+		if err != nil {
+			klog.V(1).Infof("could not parse %s: %s", location, err)
+			return
+		}
+		for _, authInfo := range kubeconfig.AuthInfos {
+			if len(authInfo.ClientCertificateData) == 0 {
+				continue
+			}
+			certs, err := certutil.ParseCertsPEM(authInfo.ClientCertificateData)
+			if err != nil {
+				klog.V(1).Infof("could not parse %s: %s", location, err)
+				continue
+			}
+			certificates = append(certificates, newCertificate(location, certs[0]))
+		}
+	}
+
+	path := ""
+	var contents bytes.Buffer
+	for _, line := range strings.SplitAfter(output, "\n") {
+		header := strings.TrimRight(line, "\n")
+		if strings.HasPrefix(header, fileHeaderPrefix) && strings.HasSuffix(header, fileHeaderSuffix) {
+			add(path, contents.Bytes())
+			path = strings.TrimSuffix(strings.TrimPrefix(header, fileHeaderPrefix), fileHeaderSuffix)
+			contents.Reset()
+			continue
+		}
+		contents.WriteString(line)
+	}
+	add(path, contents.Bytes())
+	return certificates
+}
+
+func newCertificate(location string, cert *x509.Certificate) Certificate {
+	return Certificate{
+		Location:   location,
+		CommonName: cert.Subject.CommonName,
+		NotAfter:   cert.NotAfter,
+		IsCA:       cert.IsCA,
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+)
+
+func TestParseNodeCertificates(t *testing.T) {
+	caCert, caKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "kubernetes"}})
+	if err != nil {
+		t.Fatalf("could not create the CA: %v", err)
+	}
+	serverCert, _, err := pkiutil.NewCertAndKey(caCert, caKey, &pkiutil.CertConfig{Config: certutil.Config{
+		CommonName: "kube-apiserver",
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}})
+	if err != nil {
+		t.Fatalf("could not create the server certificate: %v", err)
+	}
+	clientCert, _, err := pkiutil.NewCertAndKey(caCert, caKey, &pkiutil.CertConfig{Config: certutil.Config{
+		CommonName:   "kubernetes-admin",
+		Organization: []string{"system:masters"},
+		Usages:       []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}})
+	if err != nil {
+		t.Fatalf("could not create the client certificate: %v", err)
+	}
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+users:
+- name: kubernetes-admin
+  user:
+    client-certificate-data: %s
+`, base64.StdEncoding.EncodeToString(pkiutil.EncodeCertPEM(clientCert)))
+
+	output := strings.Join([]string{
+		"==> /etc/kubernetes/pki/ca.crt <==",
+		string(pkiutil.EncodeCertPEM(caCert)),
+		"==> /etc/kubernetes/pki/apiserver.crt <==",
+		string(pkiutil.EncodeCertPEM(serverCert)),
+		"==> /etc/kubernetes/admin.conf <==",
+		kubeconfig,
+		"==> /etc/kubernetes/pki/sa.crt <==",
+		"not a certificate",
+	}, "\n")
+
+	expected := []Certificate{
+		{Location: "master-0:/etc/kubernetes/pki/ca.crt", CommonName: "kubernetes", NotAfter: caCert.NotAfter, IsCA: true},
+		{Location: "master-0:/etc/kubernetes/pki/apiserver.crt", CommonName: "kube-apiserver", NotAfter: serverCert.NotAfter},
+		{Location: "master-0:/etc/kubernetes/admin.conf", CommonName: "kubernetes-admin", NotAfter: clientCert.NotAfter},
+	}
+	certificates := parseNodeCertificates("master-0", output)
+	if !reflect.DeepEqual(certificates, expected) {
+		t.Errorf("expected certificates %v, got %v", expected, certificates)
+	}
+}
+
+func TestPrintExpiration(t *testing.T) {
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	certificates := []Certificate{
+		{Location: "secret kube-system/oidc-dex-cert", CommonName: "oidc-dex", NotAfter: now.Add(-time.Hour)},
+		{Location: "master-0:/etc/kubernetes/pki/ca.crt", CommonName: "kubernetes", NotAfter: now.AddDate(10, 0, 0), IsCA: true},
+		{Location: "master-0:/etc/kubernetes/pki/apiserver.crt", CommonName: "kube-apiserver", NotAfter: now.AddDate(0, 0, 30)},
+	}
+
+	var output bytes.Buffer
+	PrintExpiration(&output, certificates, now)
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 certificates, got %q", output.String())
+	}
+	for i, expected := range [][]string{
+		{"CERTIFICATE", "COMMON-NAME", "EXPIRES", "RESIDUAL-TIME", "CA"},
+		{"master-0:/etc/kubernetes/pki/apiserver.crt", "kube-apiserver", "Jul 01, 2020 12:00 UTC", "30d", "no"},
+		{"master-0:/etc/kubernetes/pki/ca.crt", "kubernetes", "Jun 01, 2030 12:00 UTC", "10y", "yes"},
+		{"secret kube-system/oidc-dex-cert", "oidc-dex", "Jun 01, 2020 11:00 UTC", "expired", "no"},
+	} {
+		got := strings.Join(strings.Fields(lines[i]), " ")
+		if want := strings.Join(expected, " "); got != want {
+			t.Errorf("expected line %d to be %q, got %q", i, want, got)
+		}
+	}
+}
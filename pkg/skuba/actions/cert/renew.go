@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cert
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/oidc"
+	"github.com/SUSE/skuba/internal/pkg/skuba/util"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+const (
+	// KubeadmCertificates are the certificates managed by kubeadm on the
+	// control plane nodes
+	KubeadmCertificates = "kubeadm"
+	// KubeletCertificates are the kubelet server certificates signed by the
+	// kubelet CA of the cluster definition folder
+	KubeletCertificates = "kubelet"
+	// OIDCCertificates are the dex and gangway server certificates
+	OIDCCertificates = "oidc"
+)
+
+// renewStates are the states renewing the certificates of each kind on a
+// node, and restarting the components using them
+var renewStates = map[string][]string{
+	KubeadmCertificates: {"kubeadm.certs.renew"},
+	KubeletCertificates: {"kubelet.servercert.create-and-upload", "kubelet.restart"},
+}
+
+// oidcCertificates are the secrets holding the OIDC server certificates,
+// with the deployment using them and how they are signed
+var oidcCertificates = []struct {
+	commonName        string
+	localCertBaseName string
+	secretName        string
+	deploymentName    string
+}{
+	{oidc.DexCertCN, oidc.DexServerCertAndKeyBaseFileName, oidc.DexCertSecretName, "oidc-dex"},
+	{oidc.GangwayCertCN, oidc.GangwayServerCertAndKeyBaseFileName, oidc.GangwayCertSecretName, "oidc-gangway"},
+}
+
+// Renew renews the certificates of the given kind over SSH on the node of the
+// inventory with the given name, or on all the nodes of the inventory when
+// no name is given. kubeadm certificates only exist on control plane nodes.
+func Renew(clusterInventory *inventory.Inventory, kind, nodeName string, parallel int, deployment func(inventory.Node) *deployments.Target) error {
+	states, ok := renewStates[kind]
+	if !ok {
+		return errors.Errorf("unknown certificates %q, expected %s or %s", kind, KubeadmCertificates, KubeletCertificates)
+	}
+
+	var nodes []inventory.Node
+	if nodeName != "" {
+		node, found := clusterInventory.Node(nodeName)
+		if !found {
+			return errors.Errorf("node %s is not listed in the inventory", nodeName)
+		}
+		if kind == KubeadmCertificates && !node.IsControlPlane() {
+			return errors.Errorf("node %s is not a control plane node, it has no %s certificates", nodeName, kind)
+		}
+		nodes = append(nodes, node)
+	} else {
+		for _, node := range clusterInventory.Nodes {
+			if kind == KubeadmCertificates && !node.IsControlPlane() {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+	}
+
+	// without the kubelet CA key, the kubelet server certificates are renewed
+	// from new CSRs signed by the external CA
+	externalKubeletCA := kind == KubeletCertificates && kubernetes.KubeletCAKeyMissing()
+	results := inventory.Run(nodes, parallel, func(node inventory.Node) error {
+		fmt.Printf("[cert] renewing the %s certificates of node %s\n", kind, node.Name)
+		target := deployment(node)
+		if externalKubeletCA && !target.DryRun {
+			if err := startExternalKubeletServerCertRenewal(target.Nodename); err != nil {
+				return err
+			}
+		}
+		if err := target.Apply(nil, states...); err != nil {
+			return err
+		}
+		return target.Complete()
+	})
+	inventory.PrintSummary(os.Stdout, results)
+	return inventory.Failed(results)
+}
+
+// startExternalKubeletServerCertRenewal removes the kubelet server
+// certificate of the node signed by the external CA, and its key, from the
+// pki folder when no CSR is waiting to be signed, so that a new CSR is
+// generated instead of uploading the current certificate again. The current
+// certificate and key are still used by the node until the new certificate
+// is uploaded.
+func startExternalKubeletServerCertRenewal(node string) error {
+	baseName := kubernetes.ExternalKubeletServerCertAndKeyBaseName(node)
+	if _, err := os.Stat(filepath.Join(skuba.PkiDir(), baseName+".csr")); err == nil {
+		// a CSR is waiting to be signed, or was signed and is uploaded now
+		return nil
+	}
+	certPath, keyPath := pkiutil.PathsForCertAndKey(skuba.PkiDir(), baseName)
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "could not remove %s", path)
+		}
+	}
+	return nil
+}
+
+// RenewOIDC signs new dex and gangway server certificates, with the OIDC CA
+// of the cluster definition folder when present or with the cluster CA, and
+// restarts dex and gangway for them to use the new certificates. Server
+// certificates provided in the cluster definition folder are used as they
+// are instead.
+func RenewOIDC(client clientset.Interface) error {
+	clusterConfiguration, err := kubeadm.GetClusterConfiguration(client)
+	if err != nil {
+		return errors.Wrap(err, "could not fetch the cluster configuration")
+	}
+	controlPlaneHost := util.ControlPlaneHost(clusterConfiguration.ControlPlaneEndpoint)
+
+	for _, certificate := range oidcCertificates {
+		exist, err := oidc.IsSecretExist(client, certificate.secretName)
+		if err != nil {
+			return errors.Wrapf(err, "unable to determine if secret %s exists", certificate.secretName)
+		}
+		if !exist {
+			fmt.Printf("[cert] skipping %s, secret %s does not exist\n", certificate.commonName, certificate.secretName)
+			continue
+		}
+
+		fmt.Printf("[cert] renewing the %s server certificate\n", certificate.commonName)
+		if err := oidc.TryToUseLocalServerCert(client, certificate.localCertBaseName, certificate.secretName); err != nil {
+			if err := oidc.SignServerWithLocalCACertAndKey(client, certificate.commonName, controlPlaneHost, certificate.secretName); err != nil {
+				return errors.Wrapf(err, "could not renew the %s server certificate", certificate.commonName)
+			}
+		}
+
+		if err := restartDeployment(client, certificate.deploymentName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartDeployment rolls out the pods of the kube-system deployment again,
+// as kubectl rollout restart does
+func restartDeployment(client clientset.Interface, name string) error {
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`, time.Now().Format(time.RFC3339))
+	if _, err := client.AppsV1().Deployments(metav1.NamespaceSystem).Patch(context.TODO(), name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "could not restart deployment %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cert
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/fake"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+func TestRenew(t *testing.T) {
+	clusterInventory := &inventory.Inventory{
+		Nodes: []inventory.Node{
+			{Name: "master-0", Target: "10.0.0.10", Role: "master"},
+			{Name: "master-1", Target: "10.0.0.11", Role: "master"},
+			{Name: "worker-0", Target: "10.0.0.20", Role: "worker"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		kind          string
+		node          string
+		errors        map[string]map[string]error
+		expected      map[string]string
+		errorExpected bool
+	}{
+		{
+			name: "kubeadm certificates of all the control planes",
+			kind: KubeadmCertificates,
+			expected: map[string]string{
+				"master-0": "apply kubeadm.certs.renew\n",
+				"master-1": "apply kubeadm.certs.renew\n",
+				"worker-0": "",
+			},
+		},
+		{
+			name: "kubelet certificates of a single node",
+			kind: KubeletCertificates,
+			node: "worker-0",
+			expected: map[string]string{
+				"master-0": "",
+				"master-1": "",
+				"worker-0": "apply kubelet.servercert.create-and-upload\napply kubelet.restart\n",
+			},
+		},
+		{
+			name:   "failed control plane skips the next ones",
+			kind:   KubeletCertificates,
+			errors: map[string]map[string]error{"master-0": {"apply kubelet.servercert.create-and-upload": errors.New("unreachable")}},
+			expected: map[string]string{
+				"master-0": "apply kubelet.servercert.create-and-upload\n",
+				"master-1": "",
+				"worker-0": "apply kubelet.servercert.create-and-upload\napply kubelet.restart\n",
+			},
+			errorExpected: true,
+		},
+		{
+			name:          "kubeadm certificates of a worker",
+			kind:          KubeadmCertificates,
+			node:          "worker-0",
+			errorExpected: true,
+		},
+		{
+			name:          "unknown node",
+			kind:          KubeletCertificates,
+			node:          "worker-1",
+			errorExpected: true,
+		},
+		{
+			name:          "oidc certificates are not renewed over SSH",
+			kind:          OIDCCertificates,
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			actionables := map[string]*fake.Actionable{}
+			err := Renew(clusterInventory, tt.kind, tt.node, 1, func(n inventory.Node) *deployments.Target {
+				role, _ := n.DeploymentRole()
+				target, actionable := fake.NewTarget(n.Target, n.Name, &role)
+				for operation, err := range tt.errors[n.Name] {
+					actionable.Errors[operation] = err
+				}
+				actionables[n.Name] = actionable
+				return target
+			})
+			if tt.errorExpected && err == nil {
+				t.Errorf("error expected on %s, but no error reported", tt.name)
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err)
+			}
+			for name, expected := range tt.expected {
+				transcript := ""
+				if actionable, ok := actionables[name]; ok {
+					transcript = actionable.Transcript()
+				}
+				if transcript != expected {
+					t.Errorf("expected operations %q on %s, got %q", expected, name, transcript)
+				}
+			}
+		})
+	}
+}
+
+func TestStartExternalKubeletServerCertRenewal(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           []string
+		expectedRemoved []string
+		expectedKept    []string
+	}{
+		{
+			name:            "certificate in use",
+			files:           []string{"kubelet-node-0.crt", "kubelet-node-0.key"},
+			expectedRemoved: []string{"kubelet-node-0.crt", "kubelet-node-0.key"},
+		},
+		{
+			name:         "CSR waiting to be signed",
+			files:        []string{"kubelet-node-0.csr", "kubelet-node-0.key"},
+			expectedKept: []string{"kubelet-node-0.csr", "kubelet-node-0.key"},
+		},
+		{
+			name:         "CSR signed",
+			files:        []string{"kubelet-node-0.csr", "kubelet-node-0.crt", "kubelet-node-0.key"},
+			expectedKept: []string{"kubelet-node-0.csr", "kubelet-node-0.crt", "kubelet-node-0.key"},
+		},
+		{
+			name:            "other nodes are left alone",
+			files:           []string{"kubelet-node-0.crt", "kubelet-node-1.crt"},
+			expectedRemoved: []string{"kubelet-node-0.crt"},
+			expectedKept:    []string{"kubelet-node-1.crt"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-cert-renew")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			wd, _ := os.Getwd()
+			if err := os.Chdir(dir); err != nil {
+				t.Fatalf("could not change to %s: %v", dir, err)
+			}
+			defer os.Chdir(wd) //nolint:errcheck
+
+			if err := os.MkdirAll(skuba.PkiDir(), 0700); err != nil {
+				t.Fatalf("could not create the pki directory: %v", err)
+			}
+			for _, file := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(skuba.PkiDir(), file), []byte{}, 0600); err != nil {
+					t.Fatalf("could not write %s: %v", file, err)
+				}
+			}
+
+			if err := startExternalKubeletServerCertRenewal("node-0"); err != nil {
+				t.Errorf("error not expected, but an error was reported (%v)", err)
+			}
+			for _, file := range tt.expectedRemoved {
+				if _, err := os.Stat(filepath.Join(skuba.PkiDir(), file)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed", file)
+				}
+			}
+			for _, file := range tt.expectedKept {
+				if _, err := os.Stat(filepath.Join(skuba.PkiDir(), file)); err != nil {
+					t.Errorf("expected %s to be kept: %v", file, err)
+				}
+			}
+		})
+	}
+}
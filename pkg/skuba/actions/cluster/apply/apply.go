@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/pkg/skuba"
+	bootstrap "github.com/SUSE/skuba/pkg/skuba/actions/node/bootstrap"
+	join "github.com/SUSE/skuba/pkg/skuba/actions/node/join"
+	remove "github.com/SUSE/skuba/pkg/skuba/actions/node/remove"
+	"github.com/SUSE/skuba/pkg/skuba/actions/validate"
+)
+
+// Options configures how the cluster is reconciled with its inventory
+type Options struct {
+	// Parallel is the number of workers joined concurrently
+	Parallel int
+	// RemoveExtra removes the nodes of the cluster missing from the inventory
+	RemoveExtra bool
+	// DrainTimeout is the time to wait for removed nodes to drain
+	DrainTimeout time.Duration
+	// SkipEtcdBackup removes control plane nodes without taking an etcd
+	// snapshot first
+	SkipEtcdBackup bool
+	// IgnorePreflightErrors is passed to kubeadm when bootstrapping and
+	// joining nodes
+	IgnorePreflightErrors string
+	// DryRun prints the changes instead of applying them
+	DryRun bool
+}
+
+// Plan holds the changes needed for the cluster to match its inventory
+type Plan struct {
+	// Bootstrap is the master node bootstrapping the cluster, when it does
+	// not exist yet
+	Bootstrap *inventory.Node
+	// Join are the nodes missing from the cluster
+	Join []inventory.Node
+	// Update are the nodes of the cluster missing some labels or taints
+	Update []inventory.Node
+	// Remove are the names of the nodes of the cluster missing from the
+	// inventory, workers first
+	Remove []string
+}
+
+// Empty returns whether the cluster already matches its inventory
+func (p *Plan) Empty() bool {
+	return p.Bootstrap == nil && len(p.Join) == 0 && len(p.Update) == 0 && len(p.Remove) == 0
+}
+
+// Print describes the changes of the plan
+func (p *Plan) Print(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintln(w, "[apply] the cluster matches its inventory")
+		return
+	}
+	if p.Bootstrap != nil {
+		fmt.Fprintf(w, "[apply] bootstrap %s (%s)\n", p.Bootstrap.Name, p.Bootstrap.Target)
+	}
+	for _, node := range p.Join {
+		fmt.Fprintf(w, "[apply] join %s %s (%s)\n", node.Role, node.Name, node.Target)
+	}
+	for _, node := range p.Update {
+		fmt.Fprintf(w, "[apply] update labels and taints of %s\n", node.Name)
+	}
+	for _, name := range p.Remove {
+		fmt.Fprintf(w, "[apply] remove %s\n", name)
+	}
+}
+
+// NewPlan compares the inventory with the nodes of the cluster. A nil client
+// means that the cluster has not been bootstrapped yet.
+func NewPlan(client clientset.Interface, clusterInventory *inventory.Inventory, removeExtra bool) (*Plan, error) {
+	plan := &Plan{}
+	if client == nil {
+		for i, node := range clusterInventory.Nodes {
+			if node.IsControlPlane() && plan.Bootstrap == nil {
+				plan.Bootstrap = &clusterInventory.Nodes[i]
+				continue
+			}
+			plan.Join = append(plan.Join, node)
+		}
+		if plan.Bootstrap == nil {
+			return nil, errors.New("the cluster has not been bootstrapped and the inventory has no master node")
+		}
+		return plan, nil
+	}
+
+	nodeList, err := kubernetes.GetAllNodes(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve node list")
+	}
+	clusterNodes := map[string]*v1.Node{}
+	for i := range nodeList.Items {
+		clusterNodes[nodeList.Items[i].Name] = &nodeList.Items[i]
+	}
+
+	for _, node := range clusterInventory.Nodes {
+		clusterNode, found := clusterNodes[node.Name]
+		if !found {
+			plan.Join = append(plan.Join, node)
+			continue
+		}
+		needsUpdate, err := mergeMetadata(clusterNode.DeepCopy(), node)
+		if err != nil {
+			return nil, err
+		}
+		if needsUpdate {
+			plan.Update = append(plan.Update, node)
+		}
+	}
+
+	if removeExtra {
+		var workers, controlPlanes []string
+		for name, clusterNode := range clusterNodes {
+			if _, found := clusterInventory.Node(name); found {
+				continue
+			}
+			if kubernetes.IsControlPlane(clusterNode) {
+				controlPlanes = append(controlPlanes, name)
+			} else {
+				workers = append(workers, name)
+			}
+		}
+		sort.Strings(workers)
+		sort.Strings(controlPlanes)
+		plan.Remove = append(workers, controlPlanes...)
+	}
+	return plan, nil
+}
+
+// Apply reconciles the cluster with its inventory: the cluster is
+// bootstrapped if needed, the missing nodes are joined, the labels and taints
+// of the nodes are updated, and the nodes missing from the inventory are
+// removed when requested. Nodes are only removed once all the others have
+// been joined successfully.
+func Apply(clusterInventory *inventory.Inventory, options Options, deployment func(inventory.Node) *deployments.Target) error {
+	var client clientset.Interface
+	if _, err := os.Stat(skuba.KubeConfigAdminFile()); err == nil {
+		if client, err = kubernetes.GetAdminClientSet(); err != nil {
+			return errors.Wrap(err, "unable to get admin client set")
+		}
+	}
+
+	plan, err := NewPlan(client, clusterInventory, options.RemoveExtra)
+	if err != nil {
+		return err
+	}
+	plan.Print(os.Stdout)
+	if plan.Empty() {
+		return nil
+	}
+
+	if plan.Bootstrap != nil {
+		bootstrapConfiguration := deployments.BootstrapConfiguration{
+			KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": options.IgnorePreflightErrors},
+		}
+		if err := bootstrap.Bootstrap(bootstrapConfiguration, deployment(*plan.Bootstrap)); err != nil {
+			return errors.Wrapf(err, "error bootstrapping node %s", plan.Bootstrap.Name)
+		}
+		if options.DryRun {
+			fmt.Println("[apply] dry run: the cluster does not exist, the other nodes cannot be joined")
+			return nil
+		}
+		if client, err = kubernetes.GetAdminClientSet(); err != nil {
+			return errors.Wrap(err, "unable to get admin client set")
+		}
+		// the bootstrapped node is labelled and tainted with the others
+		plan.Update = append(plan.Update, *plan.Bootstrap)
+	}
+
+	if len(plan.Join) > 0 {
+		results := inventory.Run(plan.Join, options.Parallel, func(node inventory.Node) error {
+			role, err := node.DeploymentRole()
+			if err != nil {
+				return err
+			}
+			taints, err := node.ParsedTaints()
+			if err != nil {
+				return err
+			}
+			joinConfiguration := deployments.JoinConfiguration{
+				Role:             role,
+				KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": options.IgnorePreflightErrors},
+				Labels:           map[string]string{},
+				Taints:           taints,
+			}
+			// the labels kubelet is not allowed to set are added once the
+			// node has joined
+			for key, value := range node.Labels {
+				if validate.IsKubeletLabel(key) {
+					joinConfiguration.Labels[key] = value
+				}
+			}
+			return join.Join(client, joinConfiguration, deployment(node))
+		})
+		fmt.Println()
+		inventory.PrintSummary(os.Stdout, results)
+		if err := inventory.Failed(results); err != nil {
+			return errors.Wrap(err, "error joining nodes")
+		}
+		plan.Update = append(plan.Update, plan.Join...)
+	}
+
+	if options.DryRun {
+		fmt.Println("[apply] dry run: labels, taints and node removals were not applied")
+		return nil
+	}
+
+	for _, node := range plan.Update {
+		if err := EnsureMetadata(client, node); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range plan.Remove {
+		if err := remove.Remove(client, name, options.DrainTimeout, options.SkipEtcdBackup); err != nil {
+			return errors.Wrapf(err, "error removing node %s", name)
+		}
+	}
+
+	fmt.Println("[apply] the cluster matches its inventory")
+	return nil
+}
+
+// EnsureMetadata adds the labels and taints of the inventory node to the
+// node of the cluster, keeping the ones set by other means
+func EnsureMetadata(client clientset.Interface, node inventory.Node) error {
+	clusterNode, err := client.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "could not get node %s", node.Name)
+	}
+	changed, err := mergeMetadata(clusterNode, node)
+	if err != nil || !changed {
+		return err
+	}
+	if _, err := client.CoreV1().Nodes().Update(context.TODO(), clusterNode, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrapf(err, "could not update labels and taints of node %s", node.Name)
+	}
+	fmt.Printf("[apply] labels and taints of node %s updated\n", node.Name)
+	return nil
+}
+
+// mergeMetadata sets the labels and taints of the inventory node on the
+// cluster node, returning whether it changed. A taint replaces the one with
+// the same key and effect.
+func mergeMetadata(clusterNode *v1.Node, node inventory.Node) (bool, error) {
+	taints, err := node.ParsedTaints()
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for key, value := range node.Labels {
+		if current, found := clusterNode.Labels[key]; found && current == value {
+			continue
+		}
+		if clusterNode.Labels == nil {
+			clusterNode.Labels = map[string]string{}
+		}
+		clusterNode.Labels[key] = value
+		changed = true
+	}
+	for _, taint := range taints {
+		found := false
+		for i, current := range clusterNode.Spec.Taints {
+			if current.Key != taint.Key || current.Effect != taint.Effect {
+				continue
+			}
+			found = true
+			if current.Value != taint.Value {
+				clusterNode.Spec.Taints[i].Value = taint.Value
+				changed = true
+			}
+		}
+		if !found {
+			clusterNode.Spec.Taints = append(clusterNode.Spec.Taints, taint)
+			changed = true
+		}
+	}
+	return changed, nil
+}
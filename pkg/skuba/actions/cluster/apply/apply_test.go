@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+)
+
+func clusterNode(name string, controlPlane bool, labels map[string]string, taints ...v1.Taint) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{},
+		},
+		Spec: v1.NodeSpec{Taints: taints},
+	}
+	if controlPlane {
+		node.Labels["node-role.kubernetes.io/master"] = ""
+	}
+	for key, value := range labels {
+		node.Labels[key] = value
+	}
+	return node
+}
+
+func names(nodes []inventory.Node) []string {
+	var result []string
+	for _, node := range nodes {
+		result = append(result, node.Name)
+	}
+	return result
+}
+
+func TestNewPlan(t *testing.T) {
+	clusterInventory := &inventory.Inventory{
+		Nodes: []inventory.Node{
+			{Name: "master-0", Target: "10.0.0.10", Role: "master"},
+			{Name: "master-1", Target: "10.0.0.11", Role: "master"},
+			{Name: "worker-0", Target: "10.0.0.20", Role: "worker", Labels: map[string]string{"example.com/storage": "true"}},
+			{Name: "worker-1", Target: "10.0.0.21", Role: "worker", Taints: []string{"example.com/storage=true:NoSchedule"}},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		bootstrapped      bool
+		clusterNodes      []*v1.Node
+		removeExtra       bool
+		expectedBootstrap string
+		expectedJoin      []string
+		expectedUpdate    []string
+		expectedRemove    []string
+	}{
+		{
+			name:              "cluster not bootstrapped",
+			expectedBootstrap: "master-0",
+			expectedJoin:      []string{"master-1", "worker-0", "worker-1"},
+		},
+		{
+			name:         "missing nodes are joined",
+			bootstrapped: true,
+			clusterNodes: []*v1.Node{
+				clusterNode("master-0", true, nil),
+				clusterNode("worker-0", false, map[string]string{"example.com/storage": "true"}),
+			},
+			expectedJoin: []string{"master-1", "worker-1"},
+		},
+		{
+			name:         "missing labels and taints are updated",
+			bootstrapped: true,
+			clusterNodes: []*v1.Node{
+				clusterNode("master-0", true, nil),
+				clusterNode("master-1", true, nil),
+				clusterNode("worker-0", false, map[string]string{"example.com/storage": "false"}),
+				clusterNode("worker-1", false, nil, v1.Taint{Key: "example.com/storage", Value: "true", Effect: v1.TaintEffectNoSchedule}),
+			},
+			expectedUpdate: []string{"worker-0"},
+		},
+		{
+			name:         "extra nodes are kept by default",
+			bootstrapped: true,
+			clusterNodes: []*v1.Node{
+				clusterNode("master-0", true, nil),
+				clusterNode("master-1", true, nil),
+				clusterNode("master-2", true, nil),
+				clusterNode("worker-0", false, map[string]string{"example.com/storage": "true"}),
+				clusterNode("worker-1", false, nil, v1.Taint{Key: "example.com/storage", Value: "true", Effect: v1.TaintEffectNoSchedule}),
+				clusterNode("worker-2", false, nil),
+			},
+		},
+		{
+			name:         "extra nodes are removed, workers first",
+			bootstrapped: true,
+			clusterNodes: []*v1.Node{
+				clusterNode("master-0", true, nil),
+				clusterNode("master-1", true, nil),
+				clusterNode("master-2", true, nil),
+				clusterNode("worker-0", false, map[string]string{"example.com/storage": "true"}),
+				clusterNode("worker-1", false, nil, v1.Taint{Key: "example.com/storage", Value: "true", Effect: v1.TaintEffectNoSchedule}),
+				clusterNode("worker-3", false, nil),
+				clusterNode("worker-2", false, nil),
+			},
+			removeExtra:    true,
+			expectedRemove: []string{"worker-2", "worker-3", "master-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var client clientset.Interface
+			if tt.bootstrapped {
+				fakeClient := fake.NewSimpleClientset()
+				for _, node := range tt.clusterNodes {
+					fakeClient.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}) //nolint:errcheck
+				}
+				client = fakeClient
+			}
+
+			plan, err := NewPlan(client, clusterInventory, tt.removeExtra)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			bootstrap := ""
+			if plan.Bootstrap != nil {
+				bootstrap = plan.Bootstrap.Name
+			}
+			if bootstrap != tt.expectedBootstrap {
+				t.Errorf("expected bootstrap of %q, got %q", tt.expectedBootstrap, bootstrap)
+			}
+			if !reflect.DeepEqual(names(plan.Join), tt.expectedJoin) {
+				t.Errorf("expected join of %v, got %v", tt.expectedJoin, names(plan.Join))
+			}
+			if !reflect.DeepEqual(names(plan.Update), tt.expectedUpdate) {
+				t.Errorf("expected update of %v, got %v", tt.expectedUpdate, names(plan.Update))
+			}
+			if !reflect.DeepEqual(plan.Remove, tt.expectedRemove) {
+				t.Errorf("expected removal of %v, got %v", tt.expectedRemove, plan.Remove)
+			}
+			if plan.Empty() != (tt.expectedBootstrap == "" && tt.expectedJoin == nil && tt.expectedUpdate == nil && tt.expectedRemove == nil) {
+				t.Errorf("unexpected empty plan: %v", plan.Empty())
+			}
+		})
+	}
+}
+
+func TestNewPlanWithoutMaster(t *testing.T) {
+	clusterInventory := &inventory.Inventory{
+		Nodes: []inventory.Node{
+			{Name: "worker-0", Target: "10.0.0.20", Role: "worker"},
+		},
+	}
+	if _, err := NewPlan(nil, clusterInventory, false); err == nil {
+		t.Error("expected an error bootstrapping a cluster without master nodes")
+	}
+}
+
+func TestEnsureMetadata(t *testing.T) {
+	client := fake.NewSimpleClientset(clusterNode("worker-0", false,
+		map[string]string{"example.com/zone": "a", "example.com/storage": "false"},
+		v1.Taint{Key: "example.com/storage", Value: "false", Effect: v1.TaintEffectNoSchedule},
+		v1.Taint{Key: "example.com/gpu", Effect: v1.TaintEffectNoExecute},
+	))
+	node := inventory.Node{
+		Name:   "worker-0",
+		Target: "10.0.0.20",
+		Role:   "worker",
+		Labels: map[string]string{"example.com/storage": "true"},
+		Taints: []string{"example.com/storage=true:NoSchedule", "example.com/dedicated:PreferNoSchedule"},
+	}
+
+	if err := EnsureMetadata(client, node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated, err := client.CoreV1().Nodes().Get(context.TODO(), "worker-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedLabels := map[string]string{"example.com/zone": "a", "example.com/storage": "true"}
+	if !reflect.DeepEqual(updated.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, updated.Labels)
+	}
+	expectedTaints := []v1.Taint{
+		{Key: "example.com/storage", Value: "true", Effect: v1.TaintEffectNoSchedule},
+		{Key: "example.com/gpu", Effect: v1.TaintEffectNoExecute},
+		{Key: "example.com/dedicated", Effect: v1.TaintEffectPreferNoSchedule},
+	}
+	if !reflect.DeepEqual(updated.Spec.Taints, expectedTaints) {
+		t.Errorf("expected taints %v, got %v", expectedTaints, updated.Spec.Taints)
+	}
+}
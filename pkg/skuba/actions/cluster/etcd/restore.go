@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/apply"
+)
+
+const (
+	apiServerTimeout      = 5 * time.Minute
+	apiServerPollInterval = 5 * time.Second
+)
+
+// Restore restores the etcd snapshot on the control plane node of the
+// inventory with the given name, as a single member etcd cluster. The
+// snapshot is uploaded and checked first, then the other control plane nodes
+// of the inventory are reset, and joined again once the API server is back,
+// as cluster apply does.
+func Restore(clusterInventory *inventory.Inventory, nodeName, snapshotFile string, options cluster.Options, deployment func(inventory.Node) *deployments.Target) error {
+	restoreNode, found := clusterInventory.Node(nodeName)
+	if !found {
+		return errors.Errorf("node %s is not listed in the inventory", nodeName)
+	}
+	if !restoreNode.IsControlPlane() {
+		return errors.Errorf("node %s is not a control plane node", nodeName)
+	}
+	if _, err := os.Stat(snapshotFile); err != nil {
+		return errors.Wrap(err, "could not read the etcd snapshot")
+	}
+
+	var others []inventory.Node
+	for _, node := range clusterInventory.Nodes {
+		if node.IsControlPlane() && node.Name != nodeName {
+			others = append(others, node)
+		}
+	}
+
+	// the snapshot is checked on the node it is restored on before any
+	// control plane node is reset, so that a bad snapshot leaves the cluster
+	// as it is
+	fmt.Printf("[etcd] uploading and checking %s on control plane node %s\n", snapshotFile, nodeName)
+	restoreConfiguration := deployments.EtcdRestoreConfiguration{SnapshotFile: snapshotFile}
+	restoreTarget := deployment(restoreNode)
+	if err := restoreTarget.Apply(restoreConfiguration, "etcd.snapshot.upload"); err != nil {
+		return errors.Wrapf(err, "could not upload the etcd snapshot to control plane node %s", nodeName)
+	}
+
+	for _, node := range others {
+		fmt.Printf("[etcd] resetting control plane node %s\n", node.Name)
+		if err := deployment(node).Apply(nil, "kubeadm.reset"); err != nil {
+			return errors.Wrapf(err, "could not reset control plane node %s", node.Name)
+		}
+	}
+
+	fmt.Printf("[etcd] restoring %s on control plane node %s\n", snapshotFile, nodeName)
+	if err := restoreTarget.Apply(restoreConfiguration, "etcd.restore"); err != nil {
+		return errors.Wrapf(err, "could not restore etcd on control plane node %s", nodeName)
+	}
+	if err := restoreTarget.Complete(); err != nil {
+		return err
+	}
+	if options.DryRun {
+		fmt.Println("[etcd] dry run: the control plane nodes were not joined again")
+		return nil
+	}
+
+	client, err := kubernetes.GetAdminClientSet()
+	if err != nil {
+		return errors.Wrap(err, "unable to get admin client set")
+	}
+	if err := waitForAPIServer(client); err != nil {
+		return err
+	}
+
+	// the restored data still holds the nodes that were reset, which have
+	// to be removed for them to join again
+	for _, node := range others {
+		if err := forgetNode(client, node.Name); err != nil {
+			return err
+		}
+	}
+
+	options.RemoveExtra = false
+	if err := cluster.Apply(clusterInventory, options, deployment); err != nil {
+		return errors.Wrap(err, "could not join the control plane nodes again")
+	}
+
+	fmt.Printf("[etcd] etcd restored on control plane node %s\n", nodeName)
+	return nil
+}
+
+// forgetNode removes the node and its API endpoint from the cluster, when
+// it is still registered
+func forgetNode(client clientset.Interface, name string) error {
+	node, err := client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not get node %s", name)
+	}
+	if err := kubeadm.RemoveAPIEndpointFromConfigMap(client, node); err != nil {
+		return errors.Wrapf(err, "could not remove the APIEndpoint for %s from the kubeadm-config configmap", name)
+	}
+	if err := client.CoreV1().Nodes().Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "could not remove node %s", name)
+	}
+	return nil
+}
+
+// waitForAPIServer waits until the API server answers after etcd was
+// restored
+func waitForAPIServer(client clientset.Interface) error {
+	start := time.Now()
+	for time.Since(start) < apiServerTimeout {
+		_, err := client.Discovery().ServerVersion()
+		if err == nil {
+			return nil
+		}
+		klog.V(2).Infof("the API server is not reachable yet: %s", err)
+		time.Sleep(apiServerPollInterval)
+	}
+	return errors.Errorf("the API server did not come back within %s after restoring etcd", apiServerTimeout)
+}
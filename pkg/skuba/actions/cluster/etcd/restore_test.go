@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/fake"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/apply"
+)
+
+func TestRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-etcd-restore")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	snapshotFile := filepath.Join(dir, "etcd-snapshot.db")
+	if err := ioutil.WriteFile(snapshotFile, []byte("snapshot"), 0600); err != nil {
+		t.Fatalf("could not write %s: %v", snapshotFile, err)
+	}
+
+	clusterInventory := &inventory.Inventory{
+		Nodes: []inventory.Node{
+			{Name: "master-0", Target: "10.0.0.10", Role: "master"},
+			{Name: "master-1", Target: "10.0.0.11", Role: "master"},
+			{Name: "worker-0", Target: "10.0.0.20", Role: "worker"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		node          string
+		snapshotFile  string
+		errors        map[string]map[string]error
+		expected      map[string]string
+		errorExpected bool
+	}{
+		{
+			name:         "restore checks the snapshot and resets the other control planes first",
+			node:         "master-0",
+			snapshotFile: snapshotFile,
+			expected: map[string]string{
+				"master-0": "apply etcd.snapshot.upload\napply etcd.restore\n",
+				"master-1": "apply kubeadm.reset\n",
+				"worker-0": "",
+			},
+		},
+		{
+			name:         "failed reset stops the restore",
+			node:         "master-0",
+			snapshotFile: snapshotFile,
+			errors:       map[string]map[string]error{"master-1": {"apply kubeadm.reset": errors.New("unreachable")}},
+			expected: map[string]string{
+				"master-0": "apply etcd.snapshot.upload\n",
+				"master-1": "apply kubeadm.reset\n",
+				"worker-0": "",
+			},
+			errorExpected: true,
+		},
+		{
+			name:         "invalid snapshot resets nothing",
+			node:         "master-0",
+			snapshotFile: snapshotFile,
+			errors:       map[string]map[string]error{"master-0": {"apply etcd.snapshot.upload": errors.New("invalid snapshot")}},
+			expected: map[string]string{
+				"master-0": "apply etcd.snapshot.upload\n",
+				"master-1": "",
+				"worker-0": "",
+			},
+			errorExpected: true,
+		},
+		{
+			name:          "unknown node",
+			node:          "master-2",
+			snapshotFile:  snapshotFile,
+			errorExpected: true,
+		},
+		{
+			name:          "worker node",
+			node:          "worker-0",
+			snapshotFile:  snapshotFile,
+			errorExpected: true,
+		},
+		{
+			name:          "missing snapshot",
+			node:          "master-0",
+			snapshotFile:  filepath.Join(dir, "missing.db"),
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			actionables := map[string]*fake.Actionable{}
+			err := Restore(clusterInventory, tt.node, tt.snapshotFile, cluster.Options{DryRun: true}, func(n inventory.Node) *deployments.Target {
+				role, _ := n.DeploymentRole()
+				target, actionable := fake.NewTarget(n.Target, n.Name, &role)
+				for operation, err := range tt.errors[n.Name] {
+					actionable.Errors[operation] = err
+				}
+				if previous, ok := actionables[n.Name]; ok {
+					target.Actionable = previous
+				} else {
+					actionables[n.Name] = actionable
+				}
+				return target
+			})
+			if tt.errorExpected && err == nil {
+				t.Errorf("error expected on %s, but no error reported", tt.name)
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err)
+			}
+			for name, expected := range tt.expected {
+				transcript := ""
+				if actionable, ok := actionables[name]; ok {
+					transcript = actionable.Transcript()
+				}
+				if transcript != expected {
+					t.Errorf("expected operations %q on %s, got %q", expected, name, transcript)
+				}
+			}
+			if tt.errorExpected {
+				return
+			}
+			data := actionables[tt.node].Operations()[0].Data
+			if data != (deployments.EtcdRestoreConfiguration{SnapshotFile: tt.snapshotFile}) {
+				t.Errorf("expected the snapshot %s to be restored, got %v", tt.snapshotFile, data)
+			}
+		})
+	}
+}
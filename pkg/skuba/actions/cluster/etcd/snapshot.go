@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	skubaetcd "github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+// Snapshot takes an etcd snapshot on the given control plane node, or on the
+// first control plane node able to take it when none is given, and downloads
+// it to file
+func Snapshot(client clientset.Interface, config *rest.Config, nodeName, file string) error {
+	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the current cluster version")
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return errors.Wrapf(err, "could not create directory %s", filepath.Dir(file))
+	}
+
+	if nodeName == "" {
+		fmt.Println("[etcd] taking an etcd snapshot")
+		if err := skubaetcd.Snapshot(client, config, currentClusterVersion, file); err != nil {
+			return err
+		}
+	} else {
+		node, err := client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "could not get node %s", nodeName)
+		}
+		if !kubernetes.IsControlPlane(node) {
+			return errors.Errorf("node %s is not a control plane node", nodeName)
+		}
+		fmt.Printf("[etcd] taking an etcd snapshot on node %s\n", nodeName)
+		if err := skubaetcd.SnapshotFrom(client, config, node, currentClusterVersion, file); err != nil {
+			return errors.Wrapf(err, "could not take an etcd snapshot on node %s", nodeName)
+		}
+	}
+
+	fmt.Printf("[etcd] etcd snapshot saved to %s\n", file)
+	return nil
+}
@@ -212,5 +212,87 @@ as these names will be used by services to reconcile node metadata.
     "useManagedIdentityExtension": true,
     "useInstanceMetadata": true
 }
+`
+
+	clusterInventory = `# Nodes of the {{.ClusterName}} cluster, reconciled with the cluster by
+# "skuba cluster apply": missing nodes are joined, the first master node is
+# bootstrapped when the cluster does not exist yet, and nodes not listed here
+# are removed when --remove-extra is given. List the nodes below before
+# running it, an inventory without nodes is refused.
+#
+# SSH parameters shared by all the nodes, they take precedence over the
+# command line flags and the ssh config:
+#
+# ssh:
+#   user: sles
+#   port: 22
+#   sudo: true
+#
+# Each node has a name, a target to connect to, and a role (master|worker).
+# The SSH parameters can be overridden per node, and the node is given the
+# labels and taints listed:
+#
+# nodes:
+# - name: master-0
+#   target: 10.0.0.10
+#   role: master
+# - name: worker-0
+#   target: worker-0.example.com
+#   role: worker
+#   ssh:
+#     user: root
+#     port: 2222
+#   labels:
+#     example.com/storage: "true"
+#   taints:
+#   - example.com/storage=true:NoSchedule
+nodes: []
+`
+
+	kernelConf = `# Kernel modules loaded and parameters set on every node of the cluster when
+# it is bootstrapped, joined or upgraded, in addition to the ones kubernetes
+# requires: the br_netfilter and vxlan modules, and the net.ipv4.ip_forward,
+# net.ipv4.conf.all.forwarding and net.bridge.bridge-nf-call-iptables
+# parameters, set to 1.
+#
+# Modules and parameters removed from this file are no longer configured at
+# boot. Modules stay loaded and parameters keep their value until the node
+# reboots.
+#
+# modules:
+# - ip_vs
+# - ip_vs_rr
+# - overlay
+#
+# parameters:
+#   net.netfilter.nf_conntrack_max: 1048576
+#   fs.inotify.max_user_watches: 524288
+modules: []
+parameters: {}
+`
+
+	firewallConf = `# How the host firewall of the nodes is handled when they are bootstrapped,
+# joined or upgraded:
+#
+# - disable: firewalld is stopped and disabled
+# - configure: the ports required by the role of the node are opened in
+#   firewalld, with the "skuba" firewalld service:
+#   - control plane nodes: 6443/tcp (API server), 2379-2380/tcp (etcd)
+#   - every node: 10250/tcp (kubelet), 8472/udp (cilium VXLAN),
+#     4240/tcp (cilium health), and the NodePort range in tcp and udp
+#     (30000-32767 unless the API server sets service-node-port-range)
+#
+# mode: configure
+#
+# The firewalld zone the ports are opened in, the default zone of the node
+# when not set:
+#
+# zone: public
+#
+# Extra ports opened on every node, as port[-port]/protocol:
+#
+# ports:
+# - 9100/tcp
+mode: disable
 `
 )
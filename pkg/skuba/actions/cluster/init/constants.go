@@ -47,6 +47,21 @@ var (
 		},
 	}
 
+	clusterScaffoldFiles = []ScaffoldFile{
+		{
+			Location: skuba.ClusterInventoryFile(),
+			Content:  clusterInventory,
+		},
+		{
+			Location: skuba.KernelConfFile(),
+			Content:  kernelConf,
+		},
+		{
+			Location: skuba.FirewallConfFile(),
+			Content:  firewallConf,
+		},
+	}
+
 	cloudScaffoldFiles = map[string][]ScaffoldFile{
 		"openstack": {
 			{
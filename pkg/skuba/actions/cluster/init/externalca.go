@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/oidc"
+	"github.com/SUSE/skuba/internal/pkg/skuba/util"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+// externalOIDCCAFile is where the API server reads the external CA from to
+// verify the dex certificate, next to the cluster CA it must not replace
+const externalOIDCCAFile = "/etc/kubernetes/pki/oidc-ca.crt"
+
+// externalCA is a CA chain issued by an external PKI, used as the kubelet CA
+// and the OIDC CA of the cluster instead of generated ones
+type externalCA struct {
+	// chain holds the PEM encoded CA certificate, followed by the
+	// certificates of the CAs which issued it
+	chain []byte
+	// key holds the PEM encoded key of the CA certificate, it is empty when
+	// no key is provided and the certificates are signed by the external PKI
+	key []byte
+}
+
+// loadExternalCA reads and validates the CA chain and its optional key
+func loadExternalCA(certFile, keyFile string) (*externalCA, error) {
+	contents, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the external CA")
+	}
+	chain, err := certutil.ParseCertsPEM(contents)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse the external CA %s", certFile)
+	}
+
+	ca := &externalCA{}
+	var key crypto.Signer
+	if keyFile != "" {
+		ca.key, err = ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read the external CA key")
+		}
+		parsedKey, err := keyutil.ParsePrivateKeyPEM(ca.key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse the external CA key %s", keyFile)
+		}
+		var ok bool
+		if key, ok = parsedKey.(crypto.Signer); !ok {
+			return nil, errors.Errorf("the external CA key %s cannot sign certificates", keyFile)
+		}
+	}
+	if err := util.ValidateCAChain(chain, key, time.Now()); err != nil {
+		return nil, errors.Wrapf(err, "invalid external CA %s", certFile)
+	}
+
+	for _, cert := range chain {
+		ca.chain = append(ca.chain, pkiutil.EncodeCertPEM(cert)...)
+	}
+	return ca, nil
+}
+
+// write writes the CA as the kubelet CA and the OIDC CA into the pki folder
+// of the cluster definition folder. Without key, the CSRs of the dex and
+// gangway server certificates are generated to be signed by the external PKI.
+func (ca *externalCA) write(initConfiguration InitConfiguration) error {
+	if err := os.MkdirAll(skuba.PkiDir(), 0700); err != nil {
+		return errors.Wrapf(err, "could not create directory %q", skuba.PkiDir())
+	}
+	for _, names := range [][2]string{
+		{kubernetes.KubeletCACertName, kubernetes.KubeletCAKeyName},
+		{oidc.CACertFileName, oidc.CAKeyFileName},
+	} {
+		if err := certutil.WriteCert(filepath.Join(skuba.PkiDir(), names[0]), ca.chain); err != nil {
+			return errors.Wrapf(err, "could not write %s", names[0])
+		}
+		if len(ca.key) == 0 {
+			continue
+		}
+		if err := keyutil.WriteKey(filepath.Join(skuba.PkiDir(), names[1]), ca.key); err != nil {
+			return errors.Wrapf(err, "could not write %s", names[1])
+		}
+	}
+	if len(ca.key) > 0 {
+		fmt.Println("[init] external CA imported, it signs the kubelet and OIDC server certificates")
+		return nil
+	}
+
+	sans := []string{initConfiguration.ControlPlaneHost()}
+	if err := oidc.GenerateServerCSRAndKey(oidc.DexCertCN, sans, oidc.DexServerCertAndKeyBaseFileName); err != nil {
+		return errors.Wrap(err, "could not generate the dex server CSR")
+	}
+	if err := oidc.GenerateServerCSRAndKey(oidc.GangwayCertCN, sans, oidc.GangwayServerCertAndKeyBaseFileName); err != nil {
+		return errors.Wrap(err, "could not generate the gangway server CSR")
+	}
+	fmt.Println("[init] external CA imported without key, the CSRs in the pki folder have to be signed by the external CA before bootstrapping")
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
+)
+
+func TestLoadExternalCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "external-ca")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"ca", "other"} {
+		cert, key, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: name}})
+		if err != nil {
+			t.Fatalf("generate CA failed: %v", err)
+		}
+		keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+		if err != nil {
+			t.Fatalf("marshal key failed: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), pkiutil.EncodeCertPEM(cert), 0600); err != nil {
+			t.Fatalf("write certificate failed: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+			t.Fatalf("write key failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		certFile      string
+		keyFile       string
+		expectedKey   bool
+		expectedError bool
+	}{
+		{
+			name:        "CA with its key",
+			certFile:    "ca.crt",
+			keyFile:     "ca.key",
+			expectedKey: true,
+		},
+		{
+			name:     "CA without key",
+			certFile: "ca.crt",
+		},
+		{
+			name:          "key of another CA",
+			certFile:      "ca.crt",
+			keyFile:       "other.key",
+			expectedError: true,
+		},
+		{
+			name:          "missing CA",
+			certFile:      "missing.crt",
+			expectedError: true,
+		},
+		{
+			name:          "key instead of certificate",
+			certFile:      "ca.key",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			keyFile := ""
+			if tt.keyFile != "" {
+				keyFile = filepath.Join(dir, tt.keyFile)
+			}
+			ca, err := loadExternalCA(filepath.Join(dir, tt.certFile), keyFile)
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("error expected on %s, but no error reported", tt.name)
+				}
+				return
+			} else if err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err)
+				return
+			}
+			if len(ca.chain) == 0 {
+				t.Errorf("expected the CA chain to be loaded")
+			}
+			if gotKey := len(ca.key) > 0; gotKey != tt.expectedKey {
+				t.Errorf("expected key loaded %v, got %v", tt.expectedKey, gotKey)
+			}
+		})
+	}
+}
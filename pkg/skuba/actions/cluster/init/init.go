@@ -57,6 +57,10 @@ type InitConfiguration struct {
 	// provisioning clusters of version 1.17.
 	UseHyperKube bool
 	CniPlugin    kubernetes.Addon
+	// ExternalCACertFile and ExternalCAKeyFile are the CA chain, and its
+	// optional key, used as the kubelet CA and the OIDC CA of the cluster
+	ExternalCACertFile string
+	ExternalCAKeyFile  string
 }
 
 func (initConfiguration InitConfiguration) ControlPlaneHost() string {
@@ -109,6 +113,15 @@ func Init(initConfiguration InitConfiguration) error {
 	if addon, found := addons.Addons[initConfiguration.CniPlugin]; !found || addon.AddOnType != addons.CniAddOn {
 		return fmt.Errorf("unknown CNI plugin provided: %s", initConfiguration.CniPlugin)
 	}
+	var ca *externalCA
+	if initConfiguration.ExternalCACertFile != "" {
+		var err error
+		if ca, err = loadExternalCA(initConfiguration.ExternalCACertFile, initConfiguration.ExternalCAKeyFile); err != nil {
+			return err
+		}
+	} else if initConfiguration.ExternalCAKeyFile != "" {
+		return errors.New("the external CA key cannot be given without the external CA")
+	}
 
 	// write configuration files
 	if err := writeScaffoldFiles(initConfiguration); err != nil {
@@ -117,6 +130,12 @@ func Init(initConfiguration InitConfiguration) error {
 	if err := writeKubeadmFiles(initConfiguration); err != nil {
 		return err
 	}
+	// the addon manifests depend on whether the kubelet CA key is available
+	if ca != nil {
+		if err := ca.write(initConfiguration); err != nil {
+			return err
+		}
+	}
 	if err := writeAddonConfigFiles(initConfiguration); err != nil {
 		return err
 	}
@@ -142,7 +161,7 @@ func isAddonRequired(addon addons.Addon, initConfiguration InitConfiguration) bo
 }
 
 func writeScaffoldFiles(initConfiguration InitConfiguration) error {
-	scaffoldFilesToWrite := CriScaffoldFiles["criconfig"]
+	scaffoldFilesToWrite := append(CriScaffoldFiles["criconfig"], clusterScaffoldFiles...)
 
 	if len(initConfiguration.CloudProvider) > 0 {
 		if cloudScaffoldFiles, found := cloudScaffoldFiles[initConfiguration.CloudProvider]; found {
@@ -247,6 +266,10 @@ func RenderTemplate(templateContents string, initConfiguration InitConfiguration
 }
 
 func writeKubeadmInitConf(initConfiguration InitConfiguration) error {
+	oidcCAFile := "/etc/kubernetes/pki/ca.crt"
+	if initConfiguration.ExternalCACertFile != "" {
+		oidcCAFile = externalOIDCCAFile
+	}
 	initCfg := kubeadmapi.InitConfiguration{
 		ClusterConfiguration: kubeadmapi.ClusterConfiguration{
 			APIServer: kubeadmapi.APIServer{
@@ -255,7 +278,7 @@ func writeKubeadmInitConf(initConfiguration InitConfiguration) error {
 					ExtraArgs: map[string]string{
 						"oidc-issuer-url":                  fmt.Sprintf("https://%s:32000", initConfiguration.ControlPlaneHost()),
 						"oidc-client-id":                   "oidc",
-						"oidc-ca-file":                     "/etc/kubernetes/pki/ca.crt",
+						"oidc-ca-file":                     oidcCAFile,
 						"oidc-username-claim":              "email",
 						"oidc-groups-claim":                "groups",
 						"service-account-issuer":           "kubernetes.default.svc",
@@ -24,6 +24,11 @@ import (
 	"path/filepath"
 	"testing"
 
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/firewall"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kernel"
 	"github.com/SUSE/skuba/internal/pkg/skuba/node"
 	constants "github.com/SUSE/skuba/pkg/skuba"
 )
@@ -148,6 +153,18 @@ func TestInitCloudProvider(t *testing.T) {
 					t.Errorf("error while inspecting file %s: %v", f, err)
 				}
 			}
+
+			if err := checkClusterInventory(ctx, clusterName); err != nil {
+				t.Errorf("error while inspecting file %s: %v", constants.ClusterInventoryFile(), err)
+			}
+
+			if err := checkKernelConf(ctx, clusterName); err != nil {
+				t.Errorf("error while inspecting file %s: %v", constants.KernelConfFile(), err)
+			}
+
+			if err := checkFirewallConf(ctx, clusterName); err != nil {
+				t.Errorf("error while inspecting file %s: %v", constants.FirewallConfFile(), err)
+			}
 		})
 	}
 }
@@ -254,6 +271,46 @@ func checkJoinConfig(ctx TestFilesystemContext, clusterName, file, provider stri
 	return checkMapEntry(provider, value, expected, found)
 }
 
+// check the cluster inventory scaffold is a valid inventory without nodes
+func checkClusterInventory(ctx TestFilesystemContext, clusterName string) error {
+	contents, err := ioutil.ReadFile(filepath.Join(ctx.WorkDirectory, clusterName, constants.ClusterInventoryFile()))
+	if err != nil {
+		return err
+	}
+	clusterInventory := inventory.Inventory{}
+	if err := yaml.UnmarshalStrict(contents, &clusterInventory); err != nil {
+		return err
+	}
+	if len(clusterInventory.Nodes) != 0 {
+		return fmt.Errorf("expected no nodes, got %d", len(clusterInventory.Nodes))
+	}
+	return nil
+}
+
+// check the kernel configuration scaffold only holds the required entries
+func checkKernelConf(ctx TestFilesystemContext, clusterName string) error {
+	kernelConfiguration, err := kernel.Load(filepath.Join(ctx.WorkDirectory, clusterName, constants.KernelConfFile()))
+	if err != nil {
+		return err
+	}
+	if len(kernelConfiguration.Modules) != 0 || len(kernelConfiguration.Parameters) != 0 {
+		return fmt.Errorf("expected no extra modules nor parameters, got %v", kernelConfiguration)
+	}
+	return nil
+}
+
+// check the firewall configuration scaffold disables firewalld
+func checkFirewallConf(ctx TestFilesystemContext, clusterName string) error {
+	firewallConfiguration, err := firewall.Load(filepath.Join(ctx.WorkDirectory, clusterName, constants.FirewallConfFile()))
+	if err != nil {
+		return err
+	}
+	if firewallConfiguration.Mode != firewall.DisableMode {
+		return fmt.Errorf("expected mode %q, got %q", firewall.DisableMode, firewallConfiguration.Mode)
+	}
+	return nil
+}
+
 // Compares the `value` found inside of a map against its `expectedValue`
 // return an error when the check fails
 func checkMapEntry(expectedValue, value string, expected, found bool) error {
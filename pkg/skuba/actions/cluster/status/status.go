@@ -19,18 +19,26 @@ package cluster
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	kubectlget "k8s.io/kubectl/pkg/cmd/get"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 )
 
 // Status prints the status of the cluster on the standard output by reading the
-// admin configuration file from the current folder
-func Status(client clientset.Interface) error {
+// admin configuration file from the current folder. The status of the etcd
+// members is printed as well when etcdStatus is set.
+func Status(client clientset.Interface, etcdStatus bool) error {
 	nodeList, err := client.CoreV1().Nodes().List(
 		context.TODO(),
 		metav1.ListOptions{})
@@ -79,5 +87,59 @@ func Status(client clientset.Interface) error {
 	if err := printer.PrintObj(nodeList, os.Stdout); err != nil {
 		return errors.Wrap(err, "could not print to stdout")
 	}
+
+	if !etcdStatus {
+		return nil
+	}
+	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the current cluster version")
+	}
+	members, err := etcd.Status(client, currentClusterVersion)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	PrintEtcdStatus(os.Stdout, members, nodeList.Items)
 	return nil
 }
+
+// PrintEtcdStatus prints the status of the etcd members, and warns about the
+// members without a matching node
+func PrintEtcdStatus(w io.Writer, members []etcd.Member, nodes []v1.Node) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "ETCD-MEMBER-ID\tNAME\tPEER-URLS\tLEADER\tDB-SIZE\tRAFT-INDEX\tHEALTH")
+	for _, member := range members {
+		health := "healthy"
+		if !member.Healthy {
+			health = fmt.Sprintf("unhealthy: %s", member.Error)
+		}
+		fmt.Fprintf(tw, "%x\t%s\t%s\t%t\t%s\t%d\t%s\n", member.ID, member.Name, strings.Join(member.PeerURLs, ","),
+			member.Leader, formatBytes(member.DBSize), member.RaftIndex, health)
+	}
+	tw.Flush()
+
+	nodeNames := map[string]bool{}
+	for _, node := range nodes {
+		nodeNames[node.ObjectMeta.Name] = true
+	}
+	for _, member := range members {
+		if !nodeNames[member.Name] {
+			fmt.Fprintf(w, "WARNING: etcd member %x (%s) has no matching node, it may be left over from a failed node removal\n", member.ID, member.Name)
+		}
+	}
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
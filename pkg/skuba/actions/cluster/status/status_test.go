@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+)
+
+func TestPrintEtcdStatus(t *testing.T) {
+	members := []etcd.Member{
+		{
+			ID:        0x1655b8a6d6e4b255,
+			Name:      "master-0",
+			PeerURLs:  []string{"https://10.0.0.10:2380"},
+			Leader:    true,
+			DBSize:    2465792,
+			RaftIndex: 1302,
+			Healthy:   true,
+		},
+		{
+			ID:       0x8e9e05c52164694d,
+			Name:     "master-1",
+			PeerURLs: []string{"https://10.0.0.11:2380"},
+			Error:    "context deadline exceeded",
+		},
+	}
+	nodes := []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "master-0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+	}
+
+	var output bytes.Buffer
+	PrintEtcdStatus(&output, members, nodes)
+
+	expected := `ETCD-MEMBER-ID     NAME       PEER-URLS                LEADER   DB-SIZE   RAFT-INDEX   HEALTH
+1655b8a6d6e4b255   master-0   https://10.0.0.10:2380   true     2.4 MiB   1302         healthy
+8e9e05c52164694d   master-1   https://10.0.0.11:2380   false    0 B       0            unhealthy: context deadline exceeded
+WARNING: etcd member 8e9e05c52164694d (master-1) has no matching node, it may be left over from a failed node removal
+`
+	if output.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, output.String())
+	}
+}
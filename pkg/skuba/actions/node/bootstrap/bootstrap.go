@@ -31,6 +31,7 @@ import (
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/firewall"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/node"
@@ -60,6 +61,11 @@ func Bootstrap(bootstrapConfiguration deployments.BootstrapConfiguration, target
 		}
 	}
 
+	if target.DryRun {
+		fmt.Printf("[bootstrap] dry run: node %q was not changed, secrets download and core add-ons deployment skipped\n", target.Target)
+		return nil
+	}
+
 	if err := downloadSecrets(target); err != nil {
 		return err
 	}
@@ -92,7 +98,7 @@ func Bootstrap(bootstrapConfiguration deployments.BootstrapConfiguration, target
 	}
 
 	fmt.Printf("[bootstrap] successfully bootstrapped core add-ons on node %q\n", target.Target)
-	return nil
+	return target.Complete()
 }
 
 // Takes care of bootstrapping the core components of the nodes, containerized add-ons are
@@ -119,9 +125,13 @@ func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapCon
 		return errors.Wrap(err, "could not marshal configuration")
 	}
 
-	fmt.Println("[bootstrap] writing init configuration for node")
-	if err := ioutil.WriteFile(skuba.KubeadmInitConfFile(), finalInitConfigurationContents, 0600); err != nil {
-		return errors.Wrap(err, "error writing init configuration")
+	if target.DryRun {
+		fmt.Printf("[dry-run] would write the init configuration for node %q to %s:\n%s", target.Target, skuba.KubeadmInitConfFile(), finalInitConfigurationContents)
+	} else {
+		fmt.Println("[bootstrap] writing init configuration for node")
+		if err := ioutil.WriteFile(skuba.KubeadmInitConfFile(), finalInitConfigurationContents, 0600); err != nil {
+			return errors.Wrap(err, "error writing init configuration")
+		}
 	}
 
 	var criSetup string
@@ -131,8 +141,17 @@ func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapCon
 		criSetup = "cri.sysconfig"
 	}
 
+	firewallConfiguration, err := firewall.Load(skuba.FirewallConfFile())
+	if err != nil {
+		return err
+	}
+
 	// bsc#1155810: generate cluster-wide kubelet root certificate
-	if err := kubernetes.GenerateKubeletRootCert(); err != nil {
+	if target.DryRun {
+		if !kubernetes.KubeletRootCertExists() {
+			fmt.Println("[dry-run] would generate the kubelet root certificate")
+		}
+	} else if err := kubernetes.GenerateKubeletRootCert(); err != nil {
 		return err
 	}
 
@@ -144,7 +163,7 @@ func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapCon
 		"kubernetes.bootstrap.upload-secrets",
 		"kernel.load-modules",
 		"kernel.configure-parameters",
-		"firewalld.disable",
+		firewallConfiguration.State(),
 		"apparmor.start",
 		criSetup,
 		"cri.start",
@@ -160,6 +179,9 @@ func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapCon
 	if err != nil {
 		return err
 	}
+	if target.DryRun {
+		return nil
+	}
 
 	fmt.Printf("[bootstrap] successfully bootstrapped core components on node %q with Kubernetes: %q\n", target.Target, versionToDeploy.String())
 	return nil
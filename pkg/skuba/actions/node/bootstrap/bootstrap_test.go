@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2019,2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	fakedeployments "github.com/SUSE/skuba/internal/pkg/skuba/deployments/fake"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+const initConfiguration = `apiVersion: kubeadm.k8s.io/v1beta2
+kind: InitConfiguration
+---
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+clusterName: my-cluster
+controlPlaneEndpoint: 10.0.0.1:6443
+kubernetesVersion: v1.18.10
+`
+
+// adminConf points to an API server that can not be reached, there is none to
+// deploy the core add-ons on
+const adminConf = `apiVersion: v1
+kind: Config
+clusters:
+- name: my-cluster
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: kubernetes-admin@my-cluster
+  context:
+    cluster: my-cluster
+    user: kubernetes-admin
+current-context: kubernetes-admin@my-cluster
+users:
+- name: kubernetes-admin
+  user:
+    token: secret
+`
+
+func TestBootstrap(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		responses     map[string]fakedeployments.Response
+		golden        string
+		errorExpected bool
+	}{
+		{
+			name:          "core components are bootstrapped and the secrets downloaded",
+			golden:        "bootstrap.golden",
+			errorExpected: true,
+		},
+		{
+			name: "failed kubeadm init stops the bootstrap",
+			responses: map[string]fakedeployments.Response{
+				"kubeadm init": {Stderr: "kubeadm init failed", ExitCode: 1},
+			},
+			golden:        "bootstrap-kubeadm-init-failed.golden",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-bootstrap")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			//nolint:errcheck
+			defer os.Chdir(wd)
+			if err := os.Chdir(dir); err != nil {
+				t.Fatalf("could not change directory: %v", err)
+			}
+			if err := ioutil.WriteFile(skuba.KubeadmInitConfFile(), []byte(initConfiguration), 0600); err != nil {
+				t.Fatalf("could not write init configuration: %v", err)
+			}
+
+			fakeNode := fakedeployments.NewNode()
+			fakeNode.Responses["hostname -I"] = fakedeployments.Response{Stdout: "10.0.0.1\n"}
+			fakeNode.Responses["mktemp -d"] = fakedeployments.Response{Stdout: "/tmp/tmp.skuba\n"}
+			for _, secret := range deployments.Secrets {
+				fakeNode.Files[filepath.Join("/etc/kubernetes", secret)] = secret
+			}
+			fakeNode.Files["/etc/kubernetes/admin.conf"] = adminConf
+			for command, response := range tt.responses {
+				fakeNode.Responses[command] = response
+			}
+			role := deployments.MasterRole
+			target := ssh.NewDeployment(fakeNode, "10.0.0.1", "master-0", &role)
+
+			err = Bootstrap(deployments.BootstrapConfiguration{}, target)
+			if tt.errorExpected && err == nil {
+				t.Error("expected an error but got none")
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+			fakeNode.AssertGolden(t, filepath.Join(wd, "testdata", tt.golden))
+		})
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -38,6 +37,7 @@ import (
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/cni"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/firewall"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/node"
@@ -62,13 +62,8 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		return err
 	}
 
-	// IsSUSEOS builds target Cache
-	if _, err := target.IsSUSEOS(); err != nil {
-		return err
-	}
-	if strings.Contains(target.Cache.OsRelease["VERSION_ID"], "15.1") {
-		err := fmt.Errorf("[join] SLES 15 SP1 nodes cannot join a CaaSP 4.5 (for SLES 15 SP2) cluster")
-		return err
+	if err := target.CheckOSVersion(); err != nil {
+		return fmt.Errorf("[join] %s", err)
 	}
 
 	if err := target.Apply(deployments.KubernetesBaseOSConfiguration{
@@ -77,6 +72,11 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		return err
 	}
 
+	firewallConfiguration, err := firewall.Load(skuba.FirewallConfFile())
+	if err != nil {
+		return err
+	}
+
 	var criSetup string
 	if _, err := os.Stat(skuba.CriDefaultsConfFile()); err == nil {
 		criSetup = "cri.configure"
@@ -84,8 +84,9 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		criSetup = "cri.sysconfig"
 	}
 
+	// a resumed join might have registered the node already
 	_, err = client.CoreV1().Nodes().Get(context.TODO(), target.Nodename, metav1.GetOptions{})
-	if err == nil {
+	if err == nil && !target.Resume {
 		fmt.Printf("[join] failed to join the node with name %q since a node with the same name already exists in the cluster\n", target.Nodename)
 		return err
 	}
@@ -101,7 +102,7 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		"kernel.check-modules",
 		"kernel.load-modules",
 		"kernel.configure-parameters",
-		"firewalld.disable",
+		firewallConfiguration.State(),
 		"apparmor.start",
 		criSetup,
 		"cri.start",
@@ -122,6 +123,11 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		return err
 	}
 
+	if target.DryRun {
+		fmt.Printf("[join] dry run: node %q was not changed\n", target.Target)
+		return nil
+	}
+
 	if joinConfiguration.Role == deployments.MasterRole {
 		ciliumVersion := kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, currentClusterVersion).Version
 		if err := cni.CiliumUpdateConfigMap(client, ciliumVersion); err != nil {
@@ -138,15 +144,15 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 	}
 
 	fmt.Printf("[join] node %q successfully joined the cluster\n", target.Target)
-	return nil
+	return target.Complete()
 }
 
 // ConfigPath returns the configuration path for a specific Target; if this file does
 // not exist, it will be created out of the template file
-func ConfigPath(client clientset.Interface, role deployments.Role, target *deployments.Target) (string, error) {
+func ConfigPath(client clientset.Interface, joinConfiguration deployments.JoinConfiguration, target *deployments.Target) (string, error) {
 	configPath := skuba.MachineConfFile(target.Target)
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		configPath = skuba.TemplatePathForRole(role)
+		configPath = skuba.TemplatePathForRole(joinConfiguration.Role)
 	}
 
 	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
@@ -154,17 +160,18 @@ func ConfigPath(client clientset.Interface, role deployments.Role, target *deplo
 		return "", errors.Wrap(err, "could not get current cluster version")
 	}
 
-	joinConfiguration, err := node.LoadJoinConfigurationFromFile(configPath)
+	kubeadmJoinConfiguration, err := node.LoadJoinConfigurationFromFile(configPath)
 	if err != nil {
 		return "", errors.Wrap(err, "error parsing configuration")
 	}
-	if err := addFreshTokenToJoinConfiguration(client, target.Target, joinConfiguration); err != nil {
+	if err := addFreshTokenToJoinConfiguration(client, target.Target, kubeadmJoinConfiguration); err != nil {
 		return "", errors.Wrap(err, "error adding Token to join configuration")
 	}
-	if err := addTargetInformationToJoinConfiguration(target, role, joinConfiguration, currentClusterVersion); err != nil {
+	if err := addTargetInformationToJoinConfiguration(target, joinConfiguration.Role, kubeadmJoinConfiguration, currentClusterVersion); err != nil {
 		return "", errors.Wrap(err, "error adding target information to join configuration")
 	}
-	finalJoinConfigurationContents, err := kubeadmutil.MarshalToYamlForCodecs(joinConfiguration, schema.GroupVersion{
+	addNodeRegistrationToJoinConfiguration(joinConfiguration, kubeadmJoinConfiguration)
+	finalJoinConfigurationContents, err := kubeadmutil.MarshalToYamlForCodecs(kubeadmJoinConfiguration, schema.GroupVersion{
 		Group:   "kubeadm.k8s.io",
 		Version: kubeadm.GetKubeadmApisVersion(currentClusterVersion),
 	}, kubeadmscheme.Codecs)
@@ -197,11 +204,11 @@ func addTargetInformationToJoinConfiguration(target *deployments.Target, role de
 	joinConfiguration.NodeRegistration.CRISocket = skuba.CRISocket
 	joinConfiguration.NodeRegistration.KubeletExtraArgs["hostname-override"] = target.Nodename
 	joinConfiguration.NodeRegistration.KubeletExtraArgs["pod-infra-container-image"] = kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, clusterVersion)
-	isSUSE, err := target.IsSUSEOS()
+	profile, err := target.OSProfile()
 	if err != nil {
 		return errors.Wrap(err, "unable to get os info")
 	}
-	if isSUSE {
+	if profile.SUSECNIDir {
 		joinConfiguration.NodeRegistration.KubeletExtraArgs["cni-bin-dir"] = skuba.SUSECNIDir
 	}
 	return nil
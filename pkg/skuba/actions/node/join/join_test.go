@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package join
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	fakedeployments "github.com/SUSE/skuba/internal/pkg/skuba/deployments/fake"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+func TestJoin(t *testing.T) {
+	kubeadmConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kubeadm-config",
+			Namespace: metav1.NamespaceSystem,
+		},
+		Data: map[string]string{"ClusterConfiguration": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: "v1.18.6"
+`},
+	}
+	master := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "master-0",
+			Labels: map[string]string{"node-role.kubernetes.io/master": ""},
+		},
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		osRelease     string
+		errors        map[string]error
+		golden        string
+		errorExpected bool
+	}{
+		{
+			name:   "worker joins the cluster",
+			golden: "join-worker.golden",
+		},
+		{
+			name:          "failed state stops the join",
+			errors:        map[string]error{"apply kubeadm.join": errors.New("kubeadm join failed")},
+			golden:        "join-worker-failed.golden",
+			errorExpected: true,
+		},
+		{
+			name:          "SLES 15 SP1 nodes are rejected",
+			osRelease:     strings.Replace(fakedeployments.OsReleaseSLES15SP2, "15.2", "15.1", 1),
+			golden:        "join-sles-15-sp1.golden",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-join")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			//nolint:errcheck
+			defer os.Chdir(wd)
+			if err := os.Chdir(dir); err != nil {
+				t.Fatalf("could not change directory: %v", err)
+			}
+			if err := os.MkdirAll(skuba.CriConfDir(), 0700); err != nil {
+				t.Fatalf("could not create cri configuration directory: %v", err)
+			}
+			if err := ioutil.WriteFile(skuba.CriDefaultsConfFile(), []byte{}, 0600); err != nil {
+				t.Fatalf("could not create cri configuration: %v", err)
+			}
+
+			role := deployments.WorkerRole
+			target, actionable := fakedeployments.NewTarget("10.0.0.2", "worker-0", &role)
+			if tt.osRelease != "" {
+				actionable.Files["/etc/os-release"] = tt.osRelease
+			}
+			for operation, err := range tt.errors {
+				actionable.Errors[operation] = err
+			}
+
+			clientSet := fake.NewSimpleClientset(kubeadmConfig, master)
+			err = Join(clientSet, deployments.JoinConfiguration{Role: role}, target)
+			if tt.errorExpected && err == nil {
+				t.Error("expected an error but got none")
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+			actionable.AssertGolden(t, filepath.Join(wd, "testdata", tt.golden))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package join
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+	"k8s.io/kubernetes/pkg/util/taints"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/pkg/skuba/actions/validate"
+)
+
+// reservedKubeletArgs are the kubelet arguments set by skuba, or through
+// dedicated flags
+var reservedKubeletArgs = map[string]string{
+	"hostname-override":    "it is set to the node name",
+	"node-labels":          "use --label instead",
+	"register-with-taints": "use --taint instead",
+}
+
+// ParseLabels parses labels given as key=value, checking that kubelet is
+// allowed to register its node with them
+func ParseLabels(specs []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid label %q, expected key=value", spec)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	if err := validate.NodeLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ParseTaints parses taints given as key[=value]:effect
+func ParseTaints(specs []string) ([]v1.Taint, error) {
+	toAdd, toRemove, err := taints.ParseTaints(specs)
+	if err != nil {
+		return nil, err
+	}
+	if len(toRemove) > 0 {
+		return nil, errors.New("invalid taints, taints cannot be removed when joining a node")
+	}
+	return toAdd, nil
+}
+
+// ParseKubeletArgs parses kubelet arguments given as key=value, with or
+// without the leading dashes
+func ParseKubeletArgs(specs []string) (map[string]string, error) {
+	args := map[string]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(strings.TrimLeft(spec, "-"), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid kubelet argument %q, expected key=value", spec)
+		}
+		if reason, reserved := reservedKubeletArgs[parts[0]]; reserved {
+			return nil, errors.Errorf("invalid kubelet argument %q, %s", spec, reason)
+		}
+		args[parts[0]] = parts[1]
+	}
+	return args, nil
+}
+
+// addNodeRegistrationToJoinConfiguration merges the labels, taints and kubelet
+// arguments of the join into the node registration of the kubeadm join
+// configuration
+func addNodeRegistrationToJoinConfiguration(joinConfiguration deployments.JoinConfiguration, kubeadmJoinConfiguration *kubeadmapi.JoinConfiguration) {
+	nodeRegistration := &kubeadmJoinConfiguration.NodeRegistration
+	if nodeRegistration.KubeletExtraArgs == nil {
+		nodeRegistration.KubeletExtraArgs = map[string]string{}
+	}
+
+	if len(joinConfiguration.Labels) > 0 {
+		labels := map[string]string{}
+		if current := nodeRegistration.KubeletExtraArgs["node-labels"]; current != "" {
+			for _, label := range strings.Split(current, ",") {
+				parts := strings.SplitN(label, "=", 2)
+				if len(parts) == 2 {
+					labels[parts[0]] = parts[1]
+				}
+			}
+		}
+		for key, value := range joinConfiguration.Labels {
+			labels[key] = value
+		}
+		var nodeLabels []string
+		for key, value := range labels {
+			nodeLabels = append(nodeLabels, fmt.Sprintf("%s=%s", key, value))
+		}
+		sort.Strings(nodeLabels)
+		nodeRegistration.KubeletExtraArgs["node-labels"] = strings.Join(nodeLabels, ",")
+	}
+
+	if len(joinConfiguration.Taints) > 0 {
+		// kubeadm only taints control planes when no taints are given
+		if nodeRegistration.Taints == nil && joinConfiguration.Role == deployments.MasterRole {
+			nodeRegistration.Taints = []v1.Taint{kubeadmconstants.ControlPlaneTaint}
+		}
+		for _, taint := range joinConfiguration.Taints {
+			replaced := false
+			for i, current := range nodeRegistration.Taints {
+				if current.Key == taint.Key && current.Effect == taint.Effect {
+					nodeRegistration.Taints[i] = taint
+					replaced = true
+				}
+			}
+			if !replaced {
+				nodeRegistration.Taints = append(nodeRegistration.Taints, taint)
+			}
+		}
+	}
+
+	for key, value := range joinConfiguration.KubeletExtraArgs {
+		nodeRegistration.KubeletExtraArgs[key] = value
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package join
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestParseNodeRegistrationFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		labels        []string
+		taints        []string
+		kubeletArgs   []string
+		errorExpected bool
+	}{
+		{
+			name:        "valid flags",
+			labels:      []string{"example.com/storage=true", "topology.kubernetes.io/zone=a"},
+			taints:      []string{"example.com/storage=true:NoSchedule", "example.com/gpu:NoExecute"},
+			kubeletArgs: []string{"max-pods=200", "--eviction-hard=memory.available<5%,nodefs.available<10%"},
+		},
+		{
+			name:          "label without value",
+			labels:        []string{"example.com/storage"},
+			errorExpected: true,
+		},
+		{
+			name:          "label kubelet cannot set",
+			labels:        []string{"node-role.kubernetes.io/storage="},
+			errorExpected: true,
+		},
+		{
+			name:          "invalid taint effect",
+			taints:        []string{"example.com/storage=true:Sometimes"},
+			errorExpected: true,
+		},
+		{
+			name:          "taint removal",
+			taints:        []string{"example.com/storage:NoSchedule-"},
+			errorExpected: true,
+		},
+		{
+			name:          "kubelet argument without value",
+			kubeletArgs:   []string{"max-pods"},
+			errorExpected: true,
+		},
+		{
+			name:          "reserved kubelet argument",
+			kubeletArgs:   []string{"--node-labels=example.com/storage=true"},
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, labelsErr := ParseLabels(tt.labels)
+			_, taintsErr := ParseTaints(tt.taints)
+			_, kubeletArgsErr := ParseKubeletArgs(tt.kubeletArgs)
+			gotError := labelsErr != nil || taintsErr != nil || kubeletArgsErr != nil
+			if gotError != tt.errorExpected {
+				t.Errorf("expected error %v, got %v, %v, %v", tt.errorExpected, labelsErr, taintsErr, kubeletArgsErr)
+			}
+		})
+	}
+}
+
+func TestAddNodeRegistrationToJoinConfiguration(t *testing.T) {
+	storageTaint := v1.Taint{Key: "example.com/storage", Value: "true", Effect: v1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name                     string
+		joinConfiguration        deployments.JoinConfiguration
+		nodeRegistration         kubeadmapi.NodeRegistrationOptions
+		expectedKubeletExtraArgs map[string]string
+		expectedTaints           []v1.Taint
+	}{
+		{
+			name:                     "nothing to add",
+			joinConfiguration:        deployments.JoinConfiguration{Role: deployments.WorkerRole},
+			expectedKubeletExtraArgs: map[string]string{},
+		},
+		{
+			name: "worker",
+			joinConfiguration: deployments.JoinConfiguration{
+				Role:             deployments.WorkerRole,
+				Labels:           map[string]string{"example.com/storage": "true"},
+				Taints:           []v1.Taint{storageTaint},
+				KubeletExtraArgs: map[string]string{"max-pods": "200"},
+			},
+			expectedKubeletExtraArgs: map[string]string{"node-labels": "example.com/storage=true", "max-pods": "200"},
+			expectedTaints:           []v1.Taint{storageTaint},
+		},
+		{
+			name: "master keeps the control plane taint",
+			joinConfiguration: deployments.JoinConfiguration{
+				Role:   deployments.MasterRole,
+				Taints: []v1.Taint{storageTaint},
+			},
+			expectedKubeletExtraArgs: map[string]string{},
+			expectedTaints:           []v1.Taint{kubeadmconstants.ControlPlaneTaint, storageTaint},
+		},
+		{
+			name: "template values are merged",
+			joinConfiguration: deployments.JoinConfiguration{
+				Role:             deployments.WorkerRole,
+				Labels:           map[string]string{"example.com/storage": "true"},
+				Taints:           []v1.Taint{storageTaint},
+				KubeletExtraArgs: map[string]string{"max-pods": "200"},
+			},
+			nodeRegistration: kubeadmapi.NodeRegistrationOptions{
+				KubeletExtraArgs: map[string]string{"node-labels": "example.com/zone=a,example.com/storage=false", "max-pods": "110", "cni-bin-dir": "/usr/lib/cni"},
+				Taints: []v1.Taint{
+					{Key: "example.com/storage", Value: "false", Effect: v1.TaintEffectNoSchedule},
+					{Key: "example.com/storage", Value: "false", Effect: v1.TaintEffectNoExecute},
+				},
+			},
+			expectedKubeletExtraArgs: map[string]string{"node-labels": "example.com/storage=true,example.com/zone=a", "max-pods": "200", "cni-bin-dir": "/usr/lib/cni"},
+			expectedTaints: []v1.Taint{
+				storageTaint,
+				{Key: "example.com/storage", Value: "false", Effect: v1.TaintEffectNoExecute},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			kubeadmJoinConfiguration := &kubeadmapi.JoinConfiguration{NodeRegistration: tt.nodeRegistration}
+			addNodeRegistrationToJoinConfiguration(tt.joinConfiguration, kubeadmJoinConfiguration)
+			nodeRegistration := kubeadmJoinConfiguration.NodeRegistration
+			if !reflect.DeepEqual(nodeRegistration.KubeletExtraArgs, tt.expectedKubeletExtraArgs) {
+				t.Errorf("expected kubelet extra args %v, got %v", tt.expectedKubeletExtraArgs, nodeRegistration.KubeletExtraArgs)
+			}
+			if !reflect.DeepEqual(nodeRegistration.Taints, tt.expectedTaints) {
+				t.Errorf("expected taints %v, got %v", tt.expectedTaints, nodeRegistration.Taints)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package preflight
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/node"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+// NewConfiguration returns the preflight configuration of a node with the
+// given role. When skuba runs from a cluster definition folder, the control
+// plane endpoint and the kubernetes version are the ones of the cluster,
+// else the latest version is used and the control plane checks only run
+// when an endpoint is given.
+func NewConfiguration(role *deployments.Role, controlPlaneEndpoint string) deployments.PreflightConfiguration {
+	preflightConfiguration := deployments.PreflightConfiguration{
+		Role:                 role,
+		ControlPlaneEndpoint: controlPlaneEndpoint,
+		ControlPlaneRunning:  controlPlaneEndpoint != "",
+		KubernetesVersion:    kubernetes.LatestVersion().String(),
+	}
+	if initConfiguration, err := node.LoadInitConfigurationFromFile(skuba.KubeadmInitConfFile()); err == nil {
+		if preflightConfiguration.ControlPlaneEndpoint == "" {
+			preflightConfiguration.ControlPlaneEndpoint = initConfiguration.ControlPlaneEndpoint
+		}
+		preflightConfiguration.KubernetesVersion = initConfiguration.KubernetesVersion
+	}
+	if _, err := os.Stat(skuba.KubeConfigAdminFile()); err == nil {
+		preflightConfiguration.ControlPlaneRunning = true
+		if clientSet, err := kubernetes.GetAdminClientSet(); err == nil {
+			if currentVersion, err := kubeadm.GetCurrentClusterVersion(clientSet); err == nil {
+				preflightConfiguration.KubernetesVersion = currentVersion.String()
+			}
+		}
+	}
+	return preflightConfiguration
+}
+
+// Preflight runs the preflight checks on a candidate node, without changing
+// it, and prints a report of their outcome. An error is returned when any
+// of the checks failed.
+func Preflight(preflightConfiguration deployments.PreflightConfiguration, target *deployments.Target) error {
+	fmt.Printf("[preflight] running preflight checks on node %q\n", target.Target)
+	results, err := target.Check(preflightConfiguration, deployments.PreflightChecks...)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	PrintReport(os.Stdout, results)
+	fmt.Println()
+
+	failed := 0
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d preflight checks failed on node %q", failed, len(results), target.Target)
+	}
+	fmt.Printf("[preflight] node %q passed the preflight checks\n", target.Target)
+	return nil
+}
+
+// PrintReport prints the outcome of each check
+func PrintReport(w io.Writer, results []deployments.CheckResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT")
+	for _, result := range results {
+		outcome := "pass"
+		if result.Skipped() {
+			outcome = fmt.Sprintf("skipped: %s", result.Err)
+		} else if result.Err != nil {
+			outcome = fmt.Sprintf("fail: %s", result.Err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", result.Name, outcome)
+	}
+	tw.Flush()
+}
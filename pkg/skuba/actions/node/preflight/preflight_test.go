@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package preflight
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/fake"
+)
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name          string
+		checkErrors   map[string]error
+		errorExpected bool
+	}{
+		{
+			name: "every check passes",
+		},
+		{
+			name: "skipped checks do not fail",
+			checkErrors: map[string]error{
+				"check dns":                    deployments.SkipCheck("the control plane endpoint is unknown"),
+				"check control-plane-endpoint": deployments.SkipCheck("the control plane endpoint is unknown"),
+			},
+		},
+		{
+			name: "failed check",
+			checkErrors: map[string]error{
+				"check swap": errors.New("swap is enabled on /dev/vda3, kubelet requires it to be disabled"),
+			},
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			target, actionable := fake.NewTarget("10.0.0.1", "", nil)
+			for operation, err := range tt.checkErrors {
+				actionable.Errors[operation] = err
+			}
+			err := Preflight(deployments.PreflightConfiguration{}, target)
+			if tt.errorExpected && err == nil {
+				t.Error("expected an error but got none")
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+			if operations := actionable.Operations(); len(operations) != len(deployments.PreflightChecks) {
+				t.Errorf("expected every check to run, got %v", operations)
+			}
+		})
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	output := bytes.Buffer{}
+	PrintReport(&output, []deployments.CheckResult{
+		{Name: "os"},
+		{Name: "swap", Err: errors.New("swap is enabled on /dev/vda3")},
+		{Name: "dns", Err: deployments.SkipCheck("the control plane endpoint is unknown")},
+	})
+	for _, expected := range []string{"pass", "fail: swap is enabled on /dev/vda3", "skipped: the control plane endpoint is unknown"} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected report to contain %q, got:\n%s", expected, output.String())
+		}
+	}
+}
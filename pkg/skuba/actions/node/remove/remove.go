@@ -33,8 +33,9 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/replica"
 )
 
-// Remove removes a node from the cluster
-func Remove(client clientset.Interface, target string, drainTimeout time.Duration) error {
+// Remove removes a node from the cluster. An etcd snapshot is taken before
+// removing a control plane node, unless skipEtcdBackup is set.
+func Remove(client clientset.Interface, target string, drainTimeout time.Duration, skipEtcdBackup bool) error {
 	node, err := client.CoreV1().Nodes().Get(context.TODO(), target, metav1.GetOptions{})
 	if err != nil {
 		return errors.Wrapf(err, "[remove-node] could not get node %s", target)
@@ -58,6 +59,24 @@ func Remove(client clientset.Interface, target string, drainTimeout time.Duratio
 		}
 
 		fmt.Printf("[remove-node] removing control plane node %s (drain timeout: %s)\n", targetName, drainTimeout.String())
+
+		members, err := etcd.Status(client, currentClusterVersion)
+		if err != nil {
+			return errors.Wrap(err, "[remove-node] could not get the etcd members")
+		}
+		if _, err := etcd.CheckRemoval(members, targetName); err != nil {
+			return errors.Wrap(err, "[remove-node] could not remove the etcd member")
+		}
+
+		if skipEtcdBackup {
+			fmt.Println("[remove-node] skipping the etcd snapshot")
+		} else {
+			snapshot, err := etcd.Backup(client, currentClusterVersion)
+			if err != nil {
+				return errors.Wrap(err, "[remove-node] could not take an etcd snapshot, use --skip-etcd-backup to remove the node anyway")
+			}
+			fmt.Printf("[remove-node] etcd snapshot saved to %s\n", snapshot)
+		}
 	} else {
 		fmt.Printf("[remove-node] removing worker node %s (drain timeout: %s)\n", targetName, drainTimeout.String())
 	}
@@ -76,9 +95,11 @@ func Remove(client clientset.Interface, target string, drainTimeout time.Duratio
 
 	if isControlPlane {
 		fmt.Printf("[remove-node] removing etcd from node %s\n", targetName)
-		if err := etcd.RemoveMember(client, node, currentClusterVersion); err != nil {
-			fmt.Printf("[remove-node] failed removing etcd from node %s, continuing with node removal...\n", targetName)
+		membersLeft, err := etcd.RemoveMember(client, node, currentClusterVersion)
+		if err != nil {
+			return errors.Wrapf(err, "[remove-node] could not remove etcd from node %s", targetName)
 		}
+		fmt.Printf("[remove-node] etcd member of node %s removed, %d members left\n", targetName, membersLeft)
 	}
 
 	if err := kubernetes.DisarmKubelet(client, node, currentClusterVersion); err != nil {
@@ -21,12 +21,19 @@ import (
 	"context"
 	"crypto/sha1"
 	"fmt"
+	"strings"
 	"testing"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	ktest "k8s.io/client-go/testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
 )
 
 func Test_RemoveNode(t *testing.T) {
@@ -95,20 +102,47 @@ apiServer:
 		},
 	}
 
+	// the etcd cluster seen by the status job: master-1 and master-2 are
+	// members, master-1 is unhealthy when the cluster is degraded
+	var members []etcd.Member
+	defer func(status func(clientset.Interface, *version.Version) ([]etcd.Member, error)) {
+		etcd.Status = status
+	}(etcd.Status)
+	etcd.Status = func(client clientset.Interface, clusterVersion *version.Version) ([]etcd.Member, error) {
+		return members, nil
+	}
+
 	test := []struct {
-		name          string
-		target        string
-		executer      []string
-		clientset     *fake.Clientset
-		errorExpected bool
-		errorMessage  string
+		name           string
+		target         string
+		skipEtcdBackup bool
+		degraded       bool
+		clientset      *fake.Clientset
+		errorExpected  bool
+		errorMessage   string
 	}{
 		{
-			name:          "should remove master from cluster",
+			name:           "should remove master from cluster",
+			target:         master2.Name,
+			skipEtcdBackup: true,
+			clientset:      fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, master2}}),
+			errorExpected:  false,
+		},
+		{
+			name:          "should fail when the etcd snapshot cannot be taken",
 			target:        master2.Name,
-			executer:      []string{master1.Name, master2.Name},
 			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, master2}}),
-			errorExpected: false,
+			errorExpected: true,
+			errorMessage:  "[remove-node] could not take an etcd snapshot, use --skip-etcd-backup to remove the node anyway: could not get the admin client configuration: stat admin.conf: no such file or directory",
+		},
+		{
+			name:           "should fail when removing the etcd member would lose quorum",
+			target:         master2.Name,
+			skipEtcdBackup: true,
+			degraded:       true,
+			clientset:      fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, master2}}),
+			errorExpected:  true,
+			errorMessage:   "[remove-node] could not remove the etcd member: removing etcd member 2 would lose quorum: 0 healthy members would be left out of 1, 1 are needed",
 		},
 		{
 			name:          "should fail when remove last master from cluster",
@@ -120,7 +154,6 @@ apiServer:
 		{
 			name:          "should fail when remove node does not exist",
 			target:        "not-exist",
-			executer:      []string{master1.Name},
 			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1}}),
 			errorExpected: true,
 			errorMessage:  "[remove-node] could not get node not-exist: nodes \"not-exist\" not found",
@@ -128,7 +161,6 @@ apiServer:
 		{
 			name:          "should remove worker from cluster",
 			target:        worker2.Name,
-			executer:      []string{master1.Name},
 			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, worker1, worker2}}),
 			errorExpected: false,
 		},
@@ -140,23 +172,27 @@ apiServer:
 			//nolint:errcheck
 			tt.clientset.CoreV1().ConfigMaps(metav1.NamespaceSystem).Create(context.TODO(), cm, metav1.CreateOptions{})
 
-			shaTarget := fmt.Sprintf("%x", sha1.Sum([]byte(tt.target)))
-			shaExecuter := ""
-			for _, executer := range tt.executer {
-				shaExecuter = fmt.Sprintf("%x", sha1.Sum([]byte(executer)))
-				//nolint:errcheck
-				tt.clientset.BatchV1().Jobs(metav1.NamespaceSystem).Create(
-					context.TODO(),
-					&batchv1.Job{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      fmt.Sprintf("caasp-remove-etcd-member-%.10s-from-%.10s", shaTarget, shaExecuter),
-							Namespace: metav1.NamespaceSystem,
-						},
-						Spec:   jobSpec,
-						Status: batchv1.JobStatus{Active: 1},
-					},
-					metav1.CreateOptions{})
+			members = []etcd.Member{
+				{ID: 1, Name: master1.Name, Healthy: !tt.degraded},
+				{ID: 2, Name: master2.Name, Healthy: true},
 			}
+			// the etcd member removal job succeeds and removes the member
+			tt.clientset.PrependReactor("create", "jobs", func(action ktest.Action) (bool, runtime.Object, error) {
+				job := action.(ktest.CreateAction).GetObject().(*batchv1.Job)
+				if strings.HasPrefix(job.ObjectMeta.Name, "caasp-remove-etcd-member-") {
+					job.Status.Succeeded = 1
+					left := []etcd.Member{}
+					for _, member := range members {
+						if member.Name != tt.target {
+							left = append(left, member)
+						}
+					}
+					members = left
+				}
+				return false, nil, nil
+			})
+
+			shaTarget := fmt.Sprintf("%x", sha1.Sum([]byte(tt.target)))
 			//nolint:errcheck
 			tt.clientset.BatchV1().Jobs(metav1.NamespaceSystem).Create(
 				context.TODO(),
@@ -169,7 +205,7 @@ apiServer:
 				},
 				metav1.CreateOptions{})
 
-			err := Remove(tt.clientset, tt.target, 0)
+			err := Remove(tt.clientset, tt.target, 0, tt.skipEtcdBackup)
 			if tt.errorExpected && err == nil {
 				t.Errorf("error expected on %s, but no error reported", tt.name)
 				return
@@ -180,6 +216,9 @@ apiServer:
 				t.Errorf("returned error (%v) does not match the expected one (%v)", err.Error(), tt.errorMessage)
 				return
 			}
+			if !tt.errorExpected && tt.target == master2.Name && len(members) != 1 {
+				t.Errorf("expected the etcd member of %s to be removed, members left: %v", tt.target, members)
+			}
 		})
 	}
 }
@@ -29,6 +29,8 @@ import (
 	kubeadmconfigutil "k8s.io/kubernetes/cmd/kubeadm/app/util/config"
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/firewall"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kured"
@@ -38,7 +40,9 @@ import (
 	"github.com/pkg/errors"
 )
 
-func Apply(client clientset.Interface, target *deployments.Target) error {
+// Apply upgrades the target node. An etcd snapshot is taken before upgrading
+// the first control plane node, unless skipEtcdBackup is set.
+func Apply(client clientset.Interface, target *deployments.Target, skipEtcdBackup bool) error {
 	if err := fillTargetWithNodeNameAndRole(client, target); err != nil {
 		return err
 	}
@@ -65,12 +69,12 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 	}
 
 	// Refreshing cache info about target OS
-	isSUSE, err := target.IsSUSEOS()
+	profile, err := target.OSProfile()
 	if err != nil {
 		return err
 	}
 	// Check if target node OS is valid
-	if !isSUSE {
+	if profile.PackageManager == "" {
 		return fmt.Errorf("Invalid target node OS: %s", nodeVersionInfoUpdate.Current.Node.Status.NodeInfo.OSImage)
 	}
 	// Check if the node is upgradeable (matches preconditions)
@@ -79,6 +83,29 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		return err
 	}
 
+	// Check if it's the first control plane node to be upgraded
+	isFirstControlPlaneNodeToBeUpgraded, err := nodeVersionInfoUpdate.IsFirstControlPlaneNodeToBeUpgraded(client)
+	if err != nil {
+		return err
+	}
+
+	// Take an etcd snapshot before kubeadm upgrades the control plane
+	if isFirstControlPlaneNodeToBeUpgraded {
+		switch {
+		case skipEtcdBackup:
+			fmt.Println("Skipping the etcd snapshot")
+		case target.DryRun:
+			fmt.Println("[dry-run] would take an etcd snapshot")
+		default:
+			fmt.Println("Taking an etcd snapshot...")
+			snapshot, err := etcd.Backup(client, currentClusterVersion)
+			if err != nil {
+				return errors.Wrap(err, "could not take an etcd snapshot, use --skip-etcd-backup to upgrade anyway")
+			}
+			fmt.Printf("etcd snapshot saved to %s\n", snapshot)
+		}
+	}
+
 	// Check if skuba-update.timer is already disabled
 	skubaUpdateWasEnabled, err := target.IsServiceEnabled("skuba-update.timer")
 	if err != nil {
@@ -95,7 +122,7 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 
 	// Check if a kured reboot file already exists
 	kuredRebootFilePresent := kured.RebootFileExists()
-	if kuredRebootFilePresent {
+	if kuredRebootFilePresent && !target.DryRun {
 		err := kured.RebootFileRemove()
 		if err != nil {
 			return err
@@ -109,7 +136,7 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 	}
 
 	// Lock kured before upgrade
-	if !kuredWasLocked {
+	if !kuredWasLocked && !target.DryRun {
 		if err := kured.Lock(client); err != nil {
 			return err
 		}
@@ -117,11 +144,6 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 
 	var initCfgContents []byte
 
-	// Check if it's the first control plane node to be upgraded
-	isFirstControlPlaneNodeToBeUpgraded, err := nodeVersionInfoUpdate.IsFirstControlPlaneNodeToBeUpgraded(client)
-	if err != nil {
-		return err
-	}
 	if isFirstControlPlaneNodeToBeUpgraded {
 		fmt.Println("Fetching the cluster configuration...")
 
@@ -153,19 +175,32 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 
 	const drainTimeout = 15 * time.Minute
 	node := nodeVersionInfoUpdate.Current.Node
-	fmt.Printf("Draining node %s (timeout %dmin)\n", target.Nodename, drainTimeout)
-	if err := kubernetes.DrainNode(client, node, drainTimeout); err != nil {
-		return errors.Wrapf(err, "draining node %s", target.Nodename)
+	if target.DryRun {
+		fmt.Printf("[dry-run] would drain node %s (timeout %dmin)\n", target.Nodename, drainTimeout)
+	} else {
+		fmt.Printf("Draining node %s (timeout %dmin)\n", target.Nodename, drainTimeout)
+		if err := kubernetes.DrainNode(client, node, drainTimeout); err != nil {
+			return errors.Wrapf(err, "draining node %s", target.Nodename)
+		}
 	}
 
 	fmt.Printf("Performing node %s (%s) upgrade, please wait...\n", target.Nodename, target.Target)
 
-	// Always upload crio files, regardless of the version (allows to enforce
-	// user behavior during patch updates).
+	// Always upload crio files and configure the kernel and the firewall,
+	// regardless of the version (allows to enforce user behavior during
+	// patch updates). A disabled firewall is left as is.
 	if _, err := os.Stat(skuba.CriDefaultsConfFile()); err != nil {
 		return errors.Wrap(err, "you need to migrate the local configuration of the cluster. Run: `skuba cluster upgrade localconfig`")
 	}
-	err = target.Apply(nil, "cri.configure", "cri.sysconfig")
+	firewallConfiguration, err := firewall.Load(skuba.FirewallConfFile())
+	if err != nil {
+		return err
+	}
+	var firewallSetup string
+	if firewallConfiguration.Mode == firewall.ConfigureMode {
+		firewallSetup = firewallConfiguration.State()
+	}
+	err = target.Apply(nil, "cri.configure", "cri.sysconfig", "kernel.load-modules", "kernel.configure-parameters", firewallSetup)
 	if err != nil {
 		return err
 	}
@@ -186,9 +221,11 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		if err != nil {
 			return err
 		}
-		err = downloadAdminConf(target)
-		if err != nil {
-			return err
+		if !target.DryRun {
+			err = downloadAdminConf(target)
+			if err != nil {
+				return err
+			}
 		}
 	} else if err := target.Apply(nil, "kubeadm.upgrade.node"); err != nil {
 		return err
@@ -202,7 +239,11 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 	}
 
 	// bsc#1155810: generate cluster-wide kubelet root certificate, and generate/rotate kuberlet server certificate
-	if err := kubernetes.GenerateKubeletRootCert(); err != nil {
+	if target.DryRun {
+		if !kubernetes.KubeletRootCertExists() {
+			fmt.Println("[dry-run] would generate the kubelet root certificate")
+		}
+	} else if err := kubernetes.GenerateKubeletRootCert(); err != nil {
 		return err
 	}
 	err = target.Apply(nil,
@@ -224,6 +265,11 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 			return err
 		}
 	}
+	if target.DryRun {
+		fmt.Printf("[dry-run] would uncordon node %s\n", target.Nodename)
+		fmt.Printf("Node %s (%s) was not changed\n", target.Nodename, target.Target)
+		return nil
+	}
 	if !kuredWasLocked {
 		if err := kured.Unlock(client); err != nil {
 			return err
@@ -243,7 +289,7 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 
 	fmt.Printf("Node %s (%s) successfully upgraded\n", target.Nodename, target.Target)
 
-	return nil
+	return target.Complete()
 }
 
 func fillTargetWithNodeNameAndRole(client clientset.Interface, target *deployments.Target) error {
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2019,2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package upgrade
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	fakedeployments "github.com/SUSE/skuba/internal/pkg/skuba/deployments/fake"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+func clusterNode(name, machineID, kubeletVersion string, controlPlane bool) *corev1.Node {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{},
+		},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{
+				MachineID:               machineID,
+				KubeletVersion:          kubeletVersion,
+				ContainerRuntimeVersion: "cri-o://1.18.4",
+				OSImage:                 "SUSE Linux Enterprise Server 15 SP2",
+			},
+		},
+	}
+	if controlPlane {
+		node.ObjectMeta.Labels["node-role.kubernetes.io/master"] = ""
+	}
+	return node
+}
+
+func staticPod(component, nodeName, tag string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", component, nodeName),
+			Namespace: metav1.NamespaceSystem,
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   nodeName,
+			Containers: []corev1.Container{{Name: component, Image: fmt.Sprintf("registry.suse.com/caasp/v4.5/%s:%s", component, tag)}},
+		},
+	}
+}
+
+func clusterObjects() []runtime.Object {
+	return []runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kubeadm-config",
+				Namespace: metav1.NamespaceSystem,
+			},
+			Data: map[string]string{"ClusterConfiguration": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: "v1.18.20"
+`},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kured",
+				Namespace: metav1.NamespaceSystem,
+			},
+		},
+		clusterNode("master-0", "master-0-machine-id", "v1.18.20", true),
+		staticPod("kube-apiserver", "master-0", "v1.18.20"),
+		staticPod("kube-controller-manager", "master-0", "v1.18.20"),
+		staticPod("kube-scheduler", "master-0", "v1.18.20"),
+		staticPod("etcd", "master-0", "3.4.13"),
+		clusterNode("worker-0", "worker-0-machine-id", "v1.18.10", false),
+	}
+}
+
+func TestApply(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		responses     map[string]fakedeployments.Response
+		golden        string
+		errorExpected bool
+	}{
+		{
+			name:   "worker node is upgraded",
+			golden: "apply-worker.golden",
+		},
+		{
+			name: "failed kubeadm upgrade stops the upgrade",
+			responses: map[string]fakedeployments.Response{
+				"kubeadm upgrade node": {Stderr: "kubeadm upgrade failed", ExitCode: 1},
+			},
+			golden:        "apply-worker-kubeadm-upgrade-failed.golden",
+			errorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "skuba-upgrade-apply")
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			//nolint:errcheck
+			defer os.Chdir(wd)
+			if err := os.Chdir(dir); err != nil {
+				t.Fatalf("could not change directory: %v", err)
+			}
+			if err := os.MkdirAll(skuba.CriConfDir(), 0700); err != nil {
+				t.Fatalf("could not create cri configuration directory: %v", err)
+			}
+			if err := ioutil.WriteFile(skuba.CriDefaultsConfFile(), []byte{}, 0600); err != nil {
+				t.Fatalf("could not create cri configuration: %v", err)
+			}
+
+			fakeNode := fakedeployments.NewNode()
+			fakeNode.Files["/etc/machine-id"] = "worker-0-machine-id\n"
+			fakeNode.Responses["hostname -I"] = fakedeployments.Response{Stdout: "10.0.0.2\n"}
+			for command, response := range tt.responses {
+				fakeNode.Responses[command] = response
+			}
+			target := ssh.NewDeployment(fakeNode, "10.0.0.2", "", nil)
+
+			clientSet := fake.NewSimpleClientset(clusterObjects()...)
+			err = Apply(clientSet, target, false)
+			if tt.errorExpected && err == nil {
+				t.Error("expected an error but got none")
+			} else if !tt.errorExpected && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+			fakeNode.AssertGolden(t, filepath.Join(wd, "testdata", tt.golden))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package upgrade
+
+import (
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/inventory"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kured"
+)
+
+// ApplyInventory upgrades the nodes of the inventory, control planes first,
+// one at a time, and then the workers concurrently. kured is locked once for
+// the whole run, so that nodes finishing early do not let kured reboot the
+// nodes still being upgraded.
+func ApplyInventory(client clientset.Interface, nodes []inventory.Node, parallel int, skipEtcdBackup bool, deployment func(inventory.Node) *deployments.Target) ([]inventory.Result, error) {
+	targets := map[string]*deployments.Target{}
+	dryRun := false
+	for _, node := range nodes {
+		targets[node.Name] = deployment(node)
+		dryRun = dryRun || targets[node.Name].DryRun
+	}
+	if dryRun {
+		return inventory.Run(nodes, parallel, func(node inventory.Node) error {
+			return Apply(client, targets[node.Name], skipEtcdBackup)
+		}), nil
+	}
+
+	kuredRebootFilePresent := kured.RebootFileExists()
+	if kuredRebootFilePresent {
+		if err := kured.RebootFileRemove(); err != nil {
+			return nil, err
+		}
+	}
+	kuredWasLocked, err := kured.LockExists(client)
+	if err != nil {
+		return nil, err
+	}
+	if !kuredWasLocked {
+		if err := kured.Lock(client); err != nil {
+			return nil, err
+		}
+	}
+
+	results := inventory.Run(nodes, parallel, func(node inventory.Node) error {
+		return Apply(client, targets[node.Name], skipEtcdBackup)
+	})
+
+	if !kuredWasLocked {
+		if err := kured.Unlock(client); err != nil {
+			return results, err
+		}
+	}
+	if kuredRebootFilePresent {
+		if err := kured.RebootFileCreate(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
@@ -20,6 +20,10 @@ package validate
 import (
 	"fmt"
 	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	kubeletapis "k8s.io/kubernetes/pkg/kubelet/apis"
 
 	"github.com/SUSE/skuba/pkg/skuba"
 )
@@ -41,3 +45,36 @@ func NodeName(nodename string) error {
 	}
 	return nil
 }
+
+// NodeLabels checks whether the labels are valid and can be set by kubelet
+// when registering its node.
+func NodeLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key \"%s\": %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid label value \"%s\": %s", value, strings.Join(errs, ", "))
+		}
+		if !IsKubeletLabel(key) {
+			return fmt.Errorf("invalid label key \"%s\": kubelet is not allowed to set labels in the kubernetes.io and k8s.io namespaces, besides %s",
+				key, strings.Join(kubeletapis.KubeletLabels(), ", "))
+		}
+	}
+	return nil
+}
+
+// IsKubeletLabel returns whether kubelet is allowed to set the label on its
+// node, which is restricted for the kubernetes.io and k8s.io namespaces.
+func IsKubeletLabel(key string) bool {
+	namespace := ""
+	if parts := strings.SplitN(key, "/", 2); len(parts) == 2 {
+		namespace = parts[0]
+	}
+	for _, restricted := range []string{"kubernetes.io", "k8s.io"} {
+		if namespace == restricted || strings.HasSuffix(namespace, "."+restricted) {
+			return kubeletapis.IsKubeletLabel(key)
+		}
+	}
+	return true
+}
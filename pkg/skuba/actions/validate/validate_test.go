@@ -54,3 +54,44 @@ func TestNodeName(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeLabels(t *testing.T) {
+	testCases := []struct {
+		labels    map[string]string
+		expectErr bool
+	}{
+		{
+			labels:    map[string]string{"example.com/storage": "true", "zone": ""},
+			expectErr: false,
+		},
+		{
+			labels:    map[string]string{"topology.kubernetes.io/zone": "a", "node.kubernetes.io/instance-type": "large"},
+			expectErr: false,
+		},
+		{
+			labels:    map[string]string{"node-role.kubernetes.io/storage": ""},
+			expectErr: true,
+		},
+		{
+			labels:    map[string]string{"example.k8s.io/storage": "true"},
+			expectErr: true,
+		},
+		{
+			labels:    map[string]string{"example.com/storage": "not a value"},
+			expectErr: true,
+		},
+		{
+			labels:    map[string]string{"-storage": "true"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := NodeLabels(tc.labels)
+		if tc.expectErr && (err == nil) {
+			t.Errorf("expected error for labels %v, but no error returned", tc.labels)
+		} else if !tc.expectErr && (err != nil) {
+			t.Error(err)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/kubernetes/cmd/kubeadm/app/constants"
@@ -63,6 +64,47 @@ func MachineConfFile(target string) string {
 	return filepath.Join(JoinConfDir(), fmt.Sprintf("%s.conf", target))
 }
 
+// CheckpointDir returns the location of the records of the states applied
+// on each node
+func CheckpointDir() string {
+	return "checkpoints"
+}
+
+// CheckpointFile returns the location of the record of the states applied on
+// the target
+func CheckpointFile(target string) string {
+	return filepath.Join(CheckpointDir(), fmt.Sprintf("%s.json", target))
+}
+
+// ClusterInventoryFile returns the location of the inventory describing the
+// nodes of the cluster
+func ClusterInventoryFile() string {
+	return "cluster.yaml"
+}
+
+// KernelConfFile returns the location of the kernel modules and parameters
+// configured on the nodes
+func KernelConfFile() string {
+	return "kernel.yaml"
+}
+
+// FirewallConfFile returns the location of the description of how the host
+// firewall of the nodes is handled
+func FirewallConfFile() string {
+	return "firewall.yaml"
+}
+
+// EtcdSnapshotDir returns the location of the etcd snapshots taken by skuba
+func EtcdSnapshotDir() string {
+	return "etcd-snapshots"
+}
+
+// EtcdSnapshotFile returns the location of the etcd snapshot taken at the
+// given time
+func EtcdSnapshotFile(takenAt time.Time) string {
+	return filepath.Join(EtcdSnapshotDir(), fmt.Sprintf("etcd-snapshot-%s.db", takenAt.UTC().Format("20060102-150405")))
+}
+
 func TemplatePathForRole(role deployments.Role) string {
 	switch role {
 	case deployments.MasterRole: